@@ -0,0 +1,26 @@
+package buffer
+
+import (
+	"mydb/file"
+	"unsafe"
+)
+
+// hugePageSize is the size of a transparent huge page on the platforms that have them.
+const hugePageSize = 2 << 20
+
+// newArena returns size bytes of contiguous memory for the pages of a buffer pool. Allocating every page of the pool
+// at once keeps them together in memory and saves the garbage collector from tracking each one. With hugePages,
+// the arena is aligned to hugePageSize and the operating system is asked to back it with huge pages, which cuts TLB
+// misses on large pools; where that is not supported, the request is ignored. Otherwise it is aligned for direct
+// I/O, see file.WithDirectIO.
+func newArena(size int, hugePages bool) []byte {
+	if !hugePages || size < hugePageSize {
+		return file.AlignedBytes(size)
+	}
+	raw := make([]byte, size+hugePageSize)
+	pad := (hugePageSize - int(uintptr(unsafe.Pointer(&raw[0]))%hugePageSize)) % hugePageSize
+	arena := raw[pad : pad+size : pad+size]
+	// The advice is only a hint, so failing to give it changes nothing but performance.
+	_ = adviseHugePages(arena[:size/hugePageSize*hugePageSize])
+	return arena
+}
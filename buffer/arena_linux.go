@@ -0,0 +1,8 @@
+package buffer
+
+import "syscall"
+
+// adviseHugePages asks the kernel to back b with transparent huge pages.
+func adviseHugePages(b []byte) error {
+	return syscall.Madvise(b, syscall.MADV_HUGEPAGE)
+}
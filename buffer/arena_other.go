@@ -0,0 +1,8 @@
+//go:build !linux
+
+package buffer
+
+// adviseHugePages does nothing: huge pages are only requested on Linux.
+func adviseHugePages(b []byte) error {
+	return nil
+}
@@ -20,13 +20,27 @@ type Buffer struct {
 	pins        int
 	txnNum      int
 	lsn         int
+	priority    Priority
+	noSteal     bool
+	// pageType is the type the block was pinned with when the buffer was assigned to it, see Manager.PinWithType.
+	pageType file.PageType
+	// While loading, the buffer is being assigned to block outside the manager's lock: the dirty contents of
+	// evicted, if any, are written out and block is read in. Only the assigning goroutine touches it meanwhile.
+	loading    bool
+	evicted    *file.BlockId
+	evictedTxn int
 }
 
 func NewBuffer(fileManager *file.Manager, logManager *log.Manager) *Buffer {
+	return newBufferWithPage(fileManager, logManager, file.NewPage(fileManager.BlockSize()))
+}
+
+// newBufferWithPage returns a buffer holding its blocks in page, which must be one block long.
+func newBufferWithPage(fileManager *file.Manager, logManager *log.Manager, page *file.Page) *Buffer {
 	return &Buffer{
 		fileManager: fileManager,
 		logManager:  logManager,
-		contents:    file.NewPage(fileManager.BlockSize()),
+		contents:    page,
 		block:       nil,
 		pins:        0,
 		txnNum:      -1,
@@ -56,34 +70,81 @@ func (b *Buffer) isPinned() bool {
 	return b.pins > 0
 }
 
+// Priority returns the highest priority the buffer has been pinned with since it was assigned to its block.
+func (b *Buffer) Priority() Priority {
+	return b.priority
+}
+
+// replaceable returns true if the buffer may be assigned to another block: it must be unpinned, and under the
+// no-steal policy it must not hold changes of an unfinished transaction.
+func (b *Buffer) replaceable() bool {
+	return !b.isPinned() && !(b.noSteal && b.txnNum >= 0)
+}
+
 func (b *Buffer) modifyingTxn() int {
 	return b.txnNum
 }
 
-/*
-AssignToBlock reads the contents of the specified block into the contents of the buffer.
-If the buffer was dirty, then its previous contents are first written to disk.
-*/
-func (b *Buffer) assignToBlock(block *file.BlockId) error {
-	if err := b.flush(); err != nil {
-		return fmt.Errorf("failed to flush buffer for block %s: %v", b.block.String(), err)
+// beginAssign starts assigning the buffer to the specified block and pins it for the caller, which must hold the
+// manager's lock. The caller must then call load without the lock, and finally endAssign with the lock held again.
+// Unless pageType is file.PageTypeAny, load checks that the block has a page header of that type.
+func (b *Buffer) beginAssign(block *file.BlockId, pageType file.PageType) {
+	b.evicted, b.evictedTxn = nil, -1
+	if b.txnNum >= 0 {
+		b.evicted, b.evictedTxn = b.block, b.txnNum
 	}
 	b.block = block
-	if err := b.fileManager.Read(block, b.contents); err != nil {
-		return fmt.Errorf("failed to read block %s to buffer: %v", block.String(), err)
-	}
+	b.pageType = pageType
+	b.loading = true
+	b.priority = PriorityData
+	b.pins = 1
+}
 
-	b.pins = 0
+// load writes the dirty contents of the evicted block, if any, to disk and reads the contents of the new block into
+// the buffer. It does not need the manager's lock.
+func (b *Buffer) load() error {
+	if b.evicted != nil {
+		if err := b.flushTo(b.evicted); err != nil {
+			return fmt.Errorf("failed to flush buffer for block %s: %v", b.evicted.String(), err)
+		}
+	}
+	if err := b.fileManager.Read(b.block, b.contents); err != nil {
+		return fmt.Errorf("failed to read block %s to buffer: %v", b.block.String(), err)
+	}
+	if err := b.contents.CheckHeader(b.pageType); err != nil {
+		return fmt.Errorf("block %s: %w", b.block.String(), err)
+	}
 	return nil
 }
 
+// endAssign completes the assignment started by beginAssign; the caller must hold the manager's lock. If load failed,
+// the buffer is unpinned and returns to the evicted block if its contents could not be written, and to no block
+// otherwise.
+func (b *Buffer) endAssign(err error) {
+	if err != nil {
+		if b.txnNum >= 0 {
+			b.block = b.evicted
+		} else {
+			b.block = nil
+		}
+		b.pins = 0
+	}
+	b.loading = false
+	b.evicted, b.evictedTxn = nil, -1
+}
+
 // flush writes the buffer to its disk block if it is dirty
 func (b *Buffer) flush() error {
+	return b.flushTo(b.block)
+}
+
+// flushTo writes the buffer to the specified block if it is dirty.
+func (b *Buffer) flushTo(block *file.BlockId) error {
 	if b.txnNum >= 0 {
 		if err := b.logManager.Flush(b.lsn); err != nil {
 			return fmt.Errorf("failed to flush log record for txn %d :%v", b.txnNum, err)
 		}
-		if err := b.fileManager.Write(b.block, b.contents); err != nil {
+		if err := b.fileManager.Write(block, b.contents); err != nil {
 			return fmt.Errorf("failed to write block :%v", err)
 		}
 		b.txnNum = -1
@@ -94,5 +155,5 @@ func (b *Buffer) flush() error {
 // pin incresses the buffer's pin count
 func (b *Buffer) pin() { b.pins++ }
 
-// pin incresses the buffer's pin count
+// unpin decreases the buffer's pin count
 func (b *Buffer) unpin() { b.pins-- }
@@ -0,0 +1,39 @@
+package buffer
+
+import "mydb/file"
+
+// BufferState describes a buffer that is assigned to a block.
+type BufferState struct {
+	Block file.BlockId
+	Pins  int
+	// ModifiedBy is the number of the transaction whose changes the buffer holds, or -1 if it is clean.
+	ModifiedBy int
+	Priority   Priority
+	// Loading is true while the block is being read into the buffer.
+	Loading bool
+}
+
+// Buffers returns a snapshot of the buffers that are assigned to a block, those of the temporary pool included,
+// in pool order.
+func (m *Manager) Buffers() []BufferState {
+	m.mu.Lock()
+	states := make([]BufferState, 0, len(m.bufferPool))
+	for _, buff := range m.bufferPool {
+		if buff.block == nil {
+			continue
+		}
+		states = append(states, BufferState{
+			Block:      *buff.block,
+			Pins:       buff.pins,
+			ModifiedBy: buff.modifyingTxn(),
+			Priority:   buff.priority,
+			Loading:    buff.loading,
+		})
+	}
+	m.mu.Unlock()
+
+	if m.temp != nil {
+		states = append(states, m.temp.Buffers()...)
+	}
+	return states
+}
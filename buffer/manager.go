@@ -10,9 +10,8 @@ import (
 	"time"
 )
 
-const (
-	maxWaitTime = 10 * time.Second
-)
+// DefaultMaxWaitTime is how long Pin waits for a buffer to become available unless configured otherwise.
+const DefaultMaxWaitTime = 10 * time.Second
 
 // Manager manages the pinning and unpinning of buffers to blocks. It also handles the flushing of dirty buffers.
 // It maintains a pool of buffers and uses a replacement strategy to choose which buffer to replace when a new block
@@ -23,29 +22,82 @@ type Manager struct {
 	mu           sync.Mutex
 	cond         *sync.Cond
 	strategy     ReplacementStrategy
+	maxWaitTime  time.Duration
+	tempBuffers  int
+	temp         *Manager
+	noSteal      bool
+	hugePages    bool
+}
+
+// Option configures optional behaviour of a Manager.
+type Option func(*Manager)
+
+// WithMaxWaitTime sets how long Pin waits for a buffer to become available before returning an error.
+func WithMaxWaitTime(d time.Duration) Option {
+	return func(m *Manager) {
+		m.maxWaitTime = d
+	}
+}
+
+// WithTempPool gives the blocks of temporary files (see file.IsTempFile) a separate pool of numBuffers buffers
+// with MRU replacement, so that spilling a large sort or materialization cannot evict the working set of the main
+// pool. Temporary blocks are scratch data: FlushAll skips them, and they are written out only when evicted.
+func WithTempPool(numBuffers int) Option {
+	return func(m *Manager) {
+		m.tempBuffers = numBuffers
+	}
+}
+
+// WithNoSteal forbids replacing a buffer that holds changes of an unfinished transaction, so uncommitted data never
+// reaches disk before commit. Pin then waits while every unpinned buffer is dirty. By default such buffers are stolen:
+// their log records are flushed and the page is written out, leaving recovery to undo it after a crash.
+func WithNoSteal() Option {
+	return func(m *Manager) {
+		m.noSteal = true
+	}
+}
+
+// WithHugePages asks the operating system to back the pages of the pool with huge pages, see newArena. It only
+// makes a difference for pools of several megabytes.
+func WithHugePages() Option {
+	return func(m *Manager) {
+		m.hugePages = true
+	}
 }
 
 // It depends on a file.Manager and log.Manager instance. Uses the Naive replacement strategy by default.
-func NewManager(fileManager *file.Manager, logManager *log.Manager, numBuffers int) *Manager {
-	return NewManagerWithReplacementStrategy(fileManager, logManager, numBuffers, NewNaiveStrategy())
+func NewManager(fileManager *file.Manager, logManager *log.Manager, numBuffers int, opts ...Option) *Manager {
+	return NewManagerWithReplacementStrategy(fileManager, logManager, numBuffers, NewNaiveStrategy(), opts...)
 }
 
-func NewManagerWithReplacementStrategy(fileManager *file.Manager, logManager *log.Manager, numBuffers int, strategy ReplacementStrategy) *Manager {
+func NewManagerWithReplacementStrategy(fileManager *file.Manager, logManager *log.Manager, numBuffers int, strategy ReplacementStrategy, opts ...Option) *Manager {
 	bm := &Manager{
 		bufferPool:   make([]*Buffer, numBuffers),
 		numAvailable: numBuffers,
 		strategy:     strategy,
+		maxWaitTime:  DefaultMaxWaitTime,
+	}
+	for _, opt := range opts {
+		opt(bm)
 	}
 	bm.cond = sync.NewCond(&bm.mu)
+	blockSize := fileManager.BlockSize()
+	arena := newArena(numBuffers*blockSize, bm.hugePages)
 	for i := 0; i < numBuffers; i++ {
-		bm.bufferPool[i] = NewBuffer(fileManager, logManager)
+		page := file.NewPageFromBytes(arena[i*blockSize : (i+1)*blockSize : (i+1)*blockSize])
+		bm.bufferPool[i] = newBufferWithPage(fileManager, logManager, page)
+		bm.bufferPool[i].noSteal = bm.noSteal
 	}
 	// initialize the strategy with the buffer pool
 	strategy.initialize(bm.bufferPool)
+	if bm.tempBuffers > 0 {
+		bm.temp = NewManagerWithReplacementStrategy(fileManager, logManager, bm.tempBuffers, NewMRUStrategy(),
+			WithMaxWaitTime(bm.maxWaitTime))
+	}
 	return bm
 }
 
-// Available returns the number of available (i.e., unpinned) buffers
+// Available returns the number of available (i.e., unpinned) buffers in the main pool
 func (m *Manager) Available() int {
 	m.mu.Lock()
 	defer m.mu.Unlock()
@@ -53,25 +105,115 @@ func (m *Manager) Available() int {
 	return m.numAvailable
 }
 
-// FlushAll flushes the dirty buffers modified by the specified transaction
+// FlushAll flushes the dirty buffers modified by the specified transaction. They are written with one call to
+// file.Manager.WriteAll, so each file they belong to is synced once.
 func (m *Manager) FlushAll(txnNum int) error {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	for _, buff := range m.bufferPool {
-		if buff.modifyingTxn() == txnNum {
-			if err := buff.flush(); err != nil {
-				return fmt.Errorf("failed to flush buffer for txn %d: %v", txnNum, err)
+	flushed := false
+	for {
+		evicting := false
+		var dirty []*Buffer
+		for _, buff := range m.bufferPool {
+			if buff.loading {
+				// Only the assigning goroutine may touch the buffer. It may be writing out changes of the transaction.
+				evicting = evicting || buff.evictedTxn == txnNum
+				continue
 			}
+			if buff.modifyingTxn() == txnNum {
+				dirty = append(dirty, buff)
+			}
+		}
+		if len(dirty) > 0 {
+			if err := flushBuffers(dirty); err != nil {
+				return fmt.Errorf("failed to flush buffers for txn %d: %v", txnNum, err)
+			}
+			flushed = true
+		}
+		if !evicting {
+			break
+		}
+		// Those changes are only on disk once the assignment ends.
+		m.cond.Wait()
+	}
+	if flushed && m.noSteal {
+		// Clean buffers may have become replaceable for a waiting Pin.
+		m.cond.Broadcast()
+	}
+	return nil
+}
 
+// flushBuffers writes the dirty buffers buffs to disk, after the log records of their changes.
+func flushBuffers(buffs []*Buffer) error {
+	lsn := -1
+	blocks := make([]file.BlockId, len(buffs))
+	pages := make([]*file.Page, len(buffs))
+	for i, buff := range buffs {
+		lsn = max(lsn, buff.lsn)
+		blocks[i] = *buff.block
+		pages[i] = buff.contents
+	}
+	if lsn >= 0 {
+		if err := buffs[0].logManager.Flush(lsn); err != nil {
+			return fmt.Errorf("failed to flush log records :%v", err)
 		}
 	}
+	if err := buffs[0].fileManager.WriteAll(blocks, pages); err != nil {
+		return fmt.Errorf("failed to write blocks :%v", err)
+	}
+	for _, buff := range buffs {
+		buff.txnNum = -1
+	}
 	return nil
 }
 
+// Discard drops the contents of the buffers holding blocks of filename numbered from or higher, once the file has
+// been truncated to from blocks or, when from is 0, deleted. Their changes are lost, even if not written yet.
+// Unpinned buffers are unassigned. Pinned ones keep their block but are zeroed, which is what the block holds
+// should the file grow back to it. Discard waits for buffers that are being assigned to or from such a block.
+func (m *Manager) Discard(filename string, from int) {
+	if m.temp != nil && file.IsTempFile(filename) {
+		m.temp.Discard(filename, from)
+		return
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	discarded := func(b *file.BlockId) bool {
+		return b != nil && b.Filename() == filename && b.Number() >= from
+	}
+	for {
+		loading := false
+		for _, buff := range m.bufferPool {
+			if buff.loading {
+				loading = loading || discarded(buff.block) || discarded(buff.evicted)
+				continue
+			}
+			if !discarded(buff.block) {
+				continue
+			}
+			buff.txnNum = -1
+			if buff.isPinned() {
+				clear(buff.contents.Contents())
+			} else {
+				buff.block = nil
+			}
+		}
+		if !loading {
+			return
+		}
+		m.cond.Wait()
+	}
+}
+
 // Unpin unpins the specified buffer. If its pin count goes to zero, it increases the number of available
 // buffers and notifes any waiting goroutines
 func (m *Manager) Unpin(buffer *Buffer) {
+	if m.isTemp(buffer.Block()) {
+		m.temp.Unpin(buffer)
+		return
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
@@ -86,15 +228,36 @@ func (m *Manager) Unpin(buffer *Buffer) {
 
 /*
 Pin pins a buffer to the specified block, potentially waiting until a buffer becomes available
-If no buffer becomes avaialble within a fixed time period, it returns an error.
+If no buffer becomes avaialble within the configured wait time, it returns an error.
 This function uses conditional with wait pattern, it can be found detailed here:
 https://pkg.go.dev/context#example-AfterFunc-Cond
 */
 func (m *Manager) Pin(block *file.BlockId) (*Buffer, error) {
+	return m.PinWithPriority(block, PriorityData)
+}
+
+// PinWithPriority pins a buffer to the specified block like Pin, and raises the priority of the buffer to at least
+// priority until the buffer is assigned to another block.
+func (m *Manager) PinWithPriority(block *file.BlockId, priority Priority) (*Buffer, error) {
+	return m.pin(block, priority, file.PageTypeAny)
+}
+
+// PinWithType pins a buffer to the specified block like Pin. If the block has to be read into a buffer, Pin fails
+// with an error wrapping file.ErrPageHeader unless the block has a page header of type pageType, or no header yet.
+// A block that is already in a buffer is not checked again.
+func (m *Manager) PinWithType(block *file.BlockId, pageType file.PageType) (*Buffer, error) {
+	return m.pin(block, PriorityData, pageType)
+}
+
+// pin pins a buffer to the block, see PinWithPriority and PinWithType.
+func (m *Manager) pin(block *file.BlockId, priority Priority, pageType file.PageType) (*Buffer, error) {
+	if m.isTemp(block) {
+		return m.temp.pin(block, priority, pageType)
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(context.Background(), maxWaitTime)
+	ctx, cancel := context.WithTimeout(context.Background(), m.maxWaitTime)
 	defer cancel()
 
 	// This function will run afte the context expires
@@ -132,7 +295,7 @@ func (m *Manager) Pin(block *file.BlockId) (*Buffer, error) {
 	defer stop()
 
 	for {
-		if buff, err := m.tryToPin(block); err != nil {
+		if buff, err := m.tryToPin(block, priority, pageType); err != nil {
 			return nil, err
 		} else if buff != nil {
 			return buff, nil
@@ -151,33 +314,64 @@ func (m *Manager) Pin(block *file.BlockId) (*Buffer, error) {
 
 }
 
-func (m *Manager) tryToPin(block *file.BlockId) (*Buffer, error) {
-	buffer := m.findExistingBuffer(block)
+// tryToPin pins a buffer to the block if it can. It returns nil and no error if the caller has to wait, either for a
+// buffer to become replaceable or for another goroutine to finish moving the block in or out of a buffer.
+// The caller must hold m.mu. It is released while a buffer is assigned to the block, so that the disk reads and
+// writes involved do not hold up the pins and unpins of other goroutines.
+func (m *Manager) tryToPin(block *file.BlockId, priority Priority, pageType file.PageType) (*Buffer, error) {
+	buffer, busy := m.findExistingBuffer(block)
+	if busy {
+		return nil, nil
+	}
 	if buffer == nil {
 		buffer = m.strategy.chooseUnpinnedBuffer()
 		if buffer == nil {
 			return nil, nil
 		}
-		if err := buffer.assignToBlock(block); err != nil {
+		buffer.beginAssign(block, pageType)
+		m.numAvailable--
+
+		m.mu.Unlock()
+		err := buffer.load()
+		m.mu.Lock()
+
+		buffer.endAssign(err)
+		// Goroutines waiting for the block, or for the evicted one, can proceed.
+		m.cond.Broadcast()
+		if err != nil {
+			m.numAvailable++
+			m.strategy.unpinBuffer(buffer)
 			return nil, err
 		}
-
+	} else {
+		if !buffer.isPinned() {
+			m.numAvailable--
+		}
+		buffer.pin()
 	}
-	if !buffer.isPinned() {
-		m.numAvailable--
+	if priority > buffer.priority {
+		buffer.priority = priority
 	}
-	buffer.pin()
 	m.strategy.pinBuffer(buffer)
 	return buffer, nil
 }
 
-// findExistingBuffer searches for a buffer assigned to the specified block.
-func (m *Manager) findExistingBuffer(block *file.BlockId) *Buffer {
-	for _, buffer := range m.bufferPool {
-		b := buffer.Block()
+// findExistingBuffer searches for a buffer assigned to the specified block. It reports the block as busy if a buffer
+// is being assigned to it, or is writing it out before being assigned to another block.
+func (m *Manager) findExistingBuffer(block *file.BlockId) (buffer *Buffer, busy bool) {
+	for _, buff := range m.bufferPool {
+		b := buff.Block()
+		if buff.loading && ((b != nil && b.Equals(block)) || (buff.evicted != nil && buff.evicted.Equals(block))) {
+			return nil, true
+		}
 		if b != nil && b.Equals(block) {
-			return buffer
+			return buff, false
 		}
 	}
-	return nil
+	return nil, false
+}
+
+// isTemp returns true if block belongs in the temp pool.
+func (m *Manager) isTemp(block *file.BlockId) bool {
+	return m.temp != nil && block != nil && file.IsTempFile(block.Filename())
 }
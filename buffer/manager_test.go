@@ -8,6 +8,7 @@ import (
 	"sync"
 	"testing"
 	"time"
+	"unsafe"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -201,3 +202,227 @@ func TestConcurrentBufferAccess(t *testing.T) {
 
 	assert.Equal(t, 2, env.bm.Available(), "all buffers should be available after completion")
 }
+
+func TestTempPool(t *testing.T) {
+	env := setupTest(t, 2)
+	defer env.cleanup()
+	bm := NewManager(env.fm, env.lm, 2, WithTempPool(2))
+
+	// Fill the main pool with the working set
+	working := make([]*Buffer, 2)
+	for i := range working {
+		blk := createBlock("testfile", i)
+		buff, err := bm.Pin(&blk)
+		require.NoError(t, err)
+		working[i] = buff
+	}
+	for _, buff := range working {
+		bm.Unpin(buff)
+	}
+
+	// Scanning many temp blocks only cycles through the temp pool, and MRU keeps reusing one buffer
+	var previous *Buffer
+	for i := 0; i < 5; i++ {
+		blk := createBlock("temp1", i)
+		buff, err := bm.Pin(&blk)
+		require.NoError(t, err)
+		assert.NotContains(t, working, buff)
+		if i >= 2 {
+			assert.Same(t, previous, buff, "MRU should replace the most recently unpinned buffer")
+		}
+		bm.Unpin(buff)
+		previous = buff
+	}
+	assert.Equal(t, 2, bm.Available())
+
+	for i, buff := range working {
+		blk := createBlock("testfile", i)
+		assert.Equal(t, &blk, buff.Block(), "working set must stay in the main pool")
+	}
+}
+
+func TestPinPriority(t *testing.T) {
+	for _, strategy := range []string{"naive", "mru"} {
+		t.Run(strategy, func(t *testing.T) {
+			env := setupTest(t, 2)
+			defer env.cleanup()
+			s, err := NewStrategy(strategy)
+			require.NoError(t, err)
+			bm := NewManagerWithReplacementStrategy(env.fm, env.lm, 2, s)
+
+			catalog := createBlock("catalog", 0)
+			catalogBuff, err := bm.PinWithPriority(&catalog, PriorityCatalog)
+			require.NoError(t, err)
+			bm.Unpin(catalogBuff)
+
+			// A scan larger than the pool must not evict the catalog page
+			for i := 0; i < 5; i++ {
+				blk := createBlock("testfile", i)
+				buff, err := bm.Pin(&blk)
+				require.NoError(t, err)
+				assert.NotSame(t, catalogBuff, buff)
+				bm.Unpin(buff)
+			}
+			assert.Equal(t, &catalog, catalogBuff.Block())
+			assert.Equal(t, PriorityCatalog, catalogBuff.Priority())
+
+			// Once every other buffer is pinned, the catalog page is still replaceable
+			blk := createBlock("testfile", 9)
+			other, err := bm.Pin(&blk)
+			require.NoError(t, err)
+			blk2 := createBlock("testfile", 10)
+			buff, err := bm.Pin(&blk2)
+			require.NoError(t, err)
+			assert.Same(t, catalogBuff, buff)
+			assert.Equal(t, PriorityData, buff.Priority())
+			bm.Unpin(other)
+			bm.Unpin(buff)
+		})
+	}
+}
+
+func TestNoSteal(t *testing.T) {
+	env := setupTest(t, 1)
+	defer env.cleanup()
+	bm := NewManager(env.fm, env.lm, 1, WithNoSteal(), WithMaxWaitTime(2*time.Second))
+
+	blk1 := createBlock("testfile", 1)
+	buff, err := bm.Pin(&blk1)
+	require.NoError(t, err)
+	buff.Contents().SetInt(0, 42)
+	buff.SetModified(7, -1)
+	bm.Unpin(buff)
+
+	// The only buffer holds uncommitted changes, so pinning another block must wait for the commit to flush it
+	pinned := make(chan *Buffer)
+	go func() {
+		blk2 := createBlock("testfile", 2)
+		buff, err := bm.Pin(&blk2)
+		assert.NoError(t, err)
+		pinned <- buff
+	}()
+	select {
+	case <-pinned:
+		t.Fatal("dirty buffer of an unfinished transaction was stolen")
+	case <-time.After(100 * time.Millisecond):
+	}
+
+	require.NoError(t, bm.FlushAll(7))
+	select {
+	case buff := <-pinned:
+		bm.Unpin(buff)
+	case <-time.After(time.Second):
+		t.Fatal("pin did not proceed after the buffer was flushed")
+	}
+}
+
+// gatedBackend blocks reads of the file named slow until the gate is opened.
+type gatedBackend struct {
+	file.Backend
+	reading chan struct{}
+	gate    chan struct{}
+}
+
+type gatedFile struct {
+	file.File
+	backend *gatedBackend
+}
+
+func (b *gatedBackend) Open(name string) (file.File, error) {
+	f, err := b.Backend.Open(name)
+	if err != nil || name != "slow" {
+		return f, err
+	}
+	return &gatedFile{File: f, backend: b}, nil
+}
+
+func (f *gatedFile) ReadAt(p []byte, off int64) (int, error) {
+	select {
+	case f.backend.reading <- struct{}{}:
+	default:
+	}
+	<-f.backend.gate
+	return f.File.ReadAt(p, off)
+}
+
+func TestPinReadsOutsideLock(t *testing.T) {
+	backend := &gatedBackend{Backend: file.NewMemoryBackend(), reading: make(chan struct{}, 1), gate: make(chan struct{})}
+	fm, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "testlog")
+	require.NoError(t, err)
+	bm := NewManager(fm, lm, 3, WithMaxWaitTime(2*time.Second))
+
+	fast := createBlock("fast", 0)
+	slow := createBlock("slow", 0)
+	_, err = fm.Append("fast")
+	require.NoError(t, err)
+	_, err = fm.Append("slow")
+	require.NoError(t, err)
+
+	pinned := make(chan *Buffer, 2)
+	for i := 0; i < 2; i++ {
+		go func() {
+			buff, err := bm.Pin(&slow)
+			assert.NoError(t, err)
+			pinned <- buff
+		}()
+	}
+	select {
+	case <-backend.reading:
+	case <-time.After(5 * time.Second):
+		t.Fatal("the slow block was never read")
+	}
+
+	// The pool is usable while the slow block is being read.
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		buff, err := bm.Pin(&fast)
+		if assert.NoError(t, err) {
+			bm.Unpin(buff)
+		}
+	}()
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		close(backend.gate)
+		t.Fatal("pinning another block waited for the slow read")
+	}
+	assert.Equal(t, 2, bm.Available())
+
+	close(backend.gate)
+	var buffers [2]*Buffer
+	for i := range buffers {
+		select {
+		case buffers[i] = <-pinned:
+		case <-time.After(5 * time.Second):
+			t.Fatal("pinning the slow block did not finish")
+		}
+	}
+	first, second := buffers[0], buffers[1]
+	assert.Same(t, first, second, "the block is read into a single buffer")
+	bm.Unpin(first)
+	bm.Unpin(second)
+	assert.Equal(t, 3, bm.Available())
+}
+
+func TestHugePages(t *testing.T) {
+	env := setupTest(t, 1)
+	defer env.cleanup()
+	numBuffers := hugePageSize/env.fm.BlockSize() + 1
+	bm := NewManager(env.fm, env.lm, numBuffers, WithHugePages())
+
+	first := bm.bufferPool[0].Contents().Contents()
+	assert.Zero(t, uintptr(unsafe.Pointer(&first[0]))%hugePageSize, "the arena must be aligned to huge pages")
+	for _, buff := range bm.bufferPool[:2] {
+		contents := buff.Contents().Contents()
+		assert.Len(t, contents, env.fm.BlockSize())
+		assert.Equal(t, env.fm.BlockSize(), cap(contents), "appending to a page must not overwrite the next one")
+	}
+
+	// Pages share the arena without overlapping.
+	bm.bufferPool[0].Contents().SetInt(0, 42)
+	assert.Equal(t, 0, bm.bufferPool[1].Contents().GetInt(0))
+	assert.Equal(t, 42, bm.bufferPool[0].Contents().GetInt(0))
+}
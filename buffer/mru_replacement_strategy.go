@@ -0,0 +1,63 @@
+package buffer
+
+import "sync"
+
+// MRUStrategy replaces the most recently unpinned buffer. It suits data that is read once and not revisited, such
+// as the runs of an external sort: a scan through more blocks than the pool holds keeps recycling a single buffer
+// instead of cycling through, and evicting, all of them. Buffers that were never assigned a block are used first.
+type MRUStrategy struct {
+	buffers      []*Buffer
+	lastUnpinned map[*Buffer]int
+	clock        int
+	mu           sync.Mutex
+}
+
+// NewMRUStrategy creates a new MRUStrategy.
+func NewMRUStrategy() *MRUStrategy {
+	return &MRUStrategy{lastUnpinned: make(map[*Buffer]int)}
+}
+
+// initialize initializes the strategy with the buffer pool.
+func (s *MRUStrategy) initialize(buffers []*Buffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.buffers = buffers
+}
+
+// pinBuffer notifies the strategy that a buffer has been pinned.
+// No action needed: only the time a buffer was last unpinned matters.
+func (s *MRUStrategy) pinBuffer(buff *Buffer) {
+	// No action needed
+}
+
+// unpinBuffer records the time at which the buffer became unpinned.
+func (s *MRUStrategy) unpinBuffer(buff *Buffer) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if !buff.isPinned() {
+		s.clock++
+		s.lastUnpinned[buff] = s.clock
+	}
+}
+
+// chooseUnpinnedBuffer selects an unused buffer if there is one, and otherwise the most recently unpinned buffer
+// of the lowest priority.
+func (s *MRUStrategy) chooseUnpinnedBuffer() *Buffer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var chosen *Buffer
+	for _, buff := range s.buffers {
+		if !buff.replaceable() {
+			continue
+		}
+		if buff.Block() == nil {
+			return buff
+		}
+		if chosen == nil || buff.priority < chosen.priority ||
+			(buff.priority == chosen.priority && s.lastUnpinned[buff] > s.lastUnpinned[chosen]) {
+			chosen = buff
+		}
+	}
+	return chosen
+}
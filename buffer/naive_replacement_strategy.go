@@ -2,7 +2,7 @@ package buffer
 
 import "sync"
 
-// NaiveStrategy is a simple buffer replacement strategy that selects the first unpinned buffer.
+// NaiveStrategy is a simple buffer replacement strategy that selects the first unpinned buffer of the lowest priority.
 type NaiveStrategy struct {
 	ReplacementStrategy
 	buffers []*Buffer
@@ -33,14 +33,15 @@ func (ns *NaiveStrategy) unpinBuffer(buff *Buffer) {
 	// No action needed.
 }
 
-// ChooseUnpinnedBuffer selects an unpinned buffer to replace.
+// ChooseUnpinnedBuffer selects the first unpinned buffer of the lowest priority.
 func (ns *NaiveStrategy) chooseUnpinnedBuffer() *Buffer {
 	ns.mu.Lock()
 	defer ns.mu.Unlock()
+	var chosen *Buffer
 	for _, buff := range ns.buffers {
-		if !buff.isPinned() {
-			return buff
+		if buff.replaceable() && (chosen == nil || buff.priority < chosen.priority) {
+			chosen = buff
 		}
 	}
-	return nil
+	return chosen
 }
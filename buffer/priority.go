@@ -0,0 +1,12 @@
+package buffer
+
+// Priority tells the replacement strategy how costly a buffer is to lose. When a buffer has to be replaced,
+// unpinned buffers of a lower priority are always chosen before those of a higher one, so a large scan of data
+// blocks cannot evict the catalog or the root pages of an index.
+type Priority int
+
+const (
+	PriorityData Priority = iota
+	PriorityIndex
+	PriorityCatalog
+)
@@ -1,5 +1,7 @@
 package buffer
 
+import "fmt"
+
 // ReplacementStrategy defines the interface for buffer replacement strategies.
 type ReplacementStrategy interface {
 	// initialize initializes the strategy with the buffer pool.
@@ -11,3 +13,16 @@ type ReplacementStrategy interface {
 	// chooseUnpinnedBuffer selects an unpinned buffer to replace
 	chooseUnpinnedBuffer() *Buffer
 }
+
+// NewStrategy returns a new replacement strategy identified by name, as used in configuration files.
+// The known names are "naive" and "mru".
+func NewStrategy(name string) (ReplacementStrategy, error) {
+	switch name {
+	case "naive":
+		return NewNaiveStrategy(), nil
+	case "mru":
+		return NewMRUStrategy(), nil
+	default:
+		return nil, fmt.Errorf("unknown replacement strategy %q", name)
+	}
+}
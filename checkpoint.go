@@ -0,0 +1,130 @@
+package mydb
+
+import (
+	"errors"
+	"mydb/tx"
+	"sync"
+	"time"
+)
+
+// ErrActiveTransactions is returned by Checkpoint if transactions are still unfinished after
+// Options.CheckpointWaitTime.
+var ErrActiveTransactions = tx.ErrActiveTransactions
+
+const (
+	// checkpointPollInterval is how often the checkpointer checks whether a checkpoint is due.
+	checkpointPollInterval = time.Second
+	// checkpointMaxBackoff bounds how long the checkpointer waits before retrying a checkpoint that found
+	// transactions active.
+	checkpointMaxBackoff = time.Minute
+)
+
+// Checkpoint writes a quiescent checkpoint record to the log. Recovery stops reading the log at the most recent
+// checkpoint, so checkpointing regularly bounds the time recovery takes.
+// Every transaction that has written to the log must be finished: their changes are then on disk, because commit
+// and rollback force them there. Checkpoint waits up to Options.CheckpointWaitTime for them, while holding back
+// transactions that are about to write for the first time, and those created by NewTx. It returns
+// ErrActiveTransactions if some are still unfinished by then. See tx.QuiescentCheckpoint.
+func (db *DB) Checkpoint() error {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+	return db.checkpoint()
+}
+
+// checkpoint writes a checkpoint record. The caller must hold db.mu.
+func (db *DB) checkpoint() error {
+	if db.closed {
+		return ErrClosed
+	}
+	return tx.QuiescentCheckpoint(db.logManager, db.checkpointWait)
+}
+
+// checkpointer checkpoints a database in the background, when the log has grown by logSize bytes or interval has
+// passed since the last checkpoint. A zero interval or logSize disables that trigger.
+// Under a load that always keeps a transaction active for longer than the checkpoint wait time, checkpoints keep
+// failing. Each attempt holds back new writers, so after every failure the checkpointer waits twice as long as
+// before retrying, up to checkpointMaxBackoff.
+type checkpointer struct {
+	db       *DB
+	interval time.Duration
+	logSize  int
+	last     time.Time
+	lastSize int
+	backoff  time.Duration
+	retryAt  time.Time
+	err      error
+	quit     chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+func startCheckpointer(db *DB, interval time.Duration, logSize int) *checkpointer {
+	c := &checkpointer{
+		db:       db,
+		interval: interval,
+		logSize:  logSize,
+		last:     time.Now(),
+		lastSize: db.logManager.Size(),
+		quit:     make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	poll := checkpointPollInterval
+	if interval > 0 && interval < poll {
+		poll = interval
+	}
+	go c.run(poll)
+	return c
+}
+
+func (c *checkpointer) run(poll time.Duration) {
+	defer close(c.done)
+	ticker := time.NewTicker(poll)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-c.quit:
+			return
+		case <-ticker.C:
+			if time.Now().Before(c.retryAt) {
+				continue
+			}
+			err := c.checkpointIfDue()
+			switch {
+			case errors.Is(err, ErrActiveTransactions):
+				c.backoff = min(max(2*c.backoff, poll), checkpointMaxBackoff)
+				c.retryAt = time.Now().Add(c.backoff)
+			case err != nil:
+				// The database is likely unusable; report the error on Close instead of retrying.
+				c.err = err
+				return
+			default:
+				c.backoff = 0
+			}
+		}
+	}
+}
+
+// checkpointIfDue checkpoints the database if a trigger fired.
+func (c *checkpointer) checkpointIfDue() error {
+	c.db.mu.Lock()
+	defer c.db.mu.Unlock()
+
+	size := c.db.logManager.Size()
+	due := (c.interval > 0 && time.Since(c.last) >= c.interval) ||
+		(c.logSize > 0 && (size-c.lastSize)*c.db.fileManager.BlockSize() >= c.logSize)
+	if !due {
+		return nil
+	}
+	if err := c.db.checkpoint(); err != nil {
+		return err
+	}
+	c.last, c.lastSize = time.Now(), size
+	return nil
+}
+
+// stop stops the checkpointer and returns the error that made it give up, if any.
+func (c *checkpointer) stop() error {
+	c.stopOnce.Do(func() { close(c.quit) })
+	<-c.done
+	return c.err
+}
@@ -0,0 +1,97 @@
+package mydb
+
+import (
+	"mydb/tx"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// lastLogRecord returns the most recent record of the database log.
+func lastLogRecord(t *testing.T, db *DB) string {
+	t.Helper()
+	iter, err := db.LogManager().Iterator()
+	require.NoError(t, err)
+	bytes, err := iter.Next()
+	require.NoError(t, err)
+	record, err := tx.CreateLogRecord(bytes)
+	require.NoError(t, err)
+	return record.String()
+}
+
+func TestCheckpoint(t *testing.T) {
+	t.Run("waits for active transactions", func(t *testing.T) {
+		db, err := Open("", WithInMemory(), WithCheckpointWaitTime(10*time.Millisecond))
+		require.NoError(t, err)
+		defer db.Close()
+		block, err := db.FileManager().Append("testfile")
+		require.NoError(t, err)
+
+		// A transaction that has not written anything does not hold up a checkpoint.
+		reader, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, db.Checkpoint())
+
+		active, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, active.Pin(block))
+		require.NoError(t, active.SetInt(block, 0, 1, true))
+		assert.ErrorIs(t, db.Checkpoint(), ErrActiveTransactions)
+
+		require.NoError(t, active.Commit())
+		require.NoError(t, reader.Commit())
+		require.NoError(t, db.Checkpoint())
+		assert.Equal(t, "<CHECKPOINT>", lastLogRecord(t, db))
+	})
+
+	t.Run("counts transactions created outside NewTx", func(t *testing.T) {
+		db, err := Open("", WithInMemory(), WithCheckpointWaitTime(10*time.Millisecond))
+		require.NoError(t, err)
+		defer db.Close()
+		block, err := db.FileManager().Append("testfile")
+		require.NoError(t, err)
+
+		outside := tx.NewTransaction(db.FileManager(), db.LogManager(), db.BufferManager(), db.LockTable())
+		require.NoError(t, outside.Pin(block))
+		require.NoError(t, outside.SetInt(block, 0, 1, true))
+		assert.ErrorIs(t, db.Checkpoint(), ErrActiveTransactions)
+		require.NoError(t, outside.Rollback())
+		require.NoError(t, db.Checkpoint())
+	})
+
+	t.Run("scheduled by interval", func(t *testing.T) {
+		db, err := Open("", WithInMemory(), WithCheckpointInterval(10*time.Millisecond))
+		require.NoError(t, err)
+
+		transaction, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, transaction.Commit())
+		require.Eventually(t, func() bool { return lastLogRecord(t, db) == "<CHECKPOINT>" }, time.Second, 5*time.Millisecond)
+		require.NoError(t, db.Close())
+	})
+
+	t.Run("scheduled by log size", func(t *testing.T) {
+		db, err := Open("", WithInMemory(), WithCheckpointLogSize(2*DefaultBlockSize),
+			WithCheckpointWaitTime(10*time.Millisecond))
+		require.NoError(t, err)
+		defer db.Close()
+
+		checkpointer := db.checkpointer
+		block, err := db.FileManager().Append("testfile")
+		require.NoError(t, err)
+		transaction, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, transaction.Pin(block))
+		for i := 0; db.LogManager().Size() < 3; i++ {
+			require.NoError(t, transaction.SetInt(block, 0, i, true))
+		}
+		assert.ErrorIs(t, checkpointer.checkpointIfDue(), ErrActiveTransactions)
+		assert.NotEqual(t, "<CHECKPOINT>", lastLogRecord(t, db), "no checkpoint while a transaction is active")
+
+		require.NoError(t, transaction.Commit())
+		require.NoError(t, checkpointer.checkpointIfDue())
+		assert.Equal(t, "<CHECKPOINT>", lastLogRecord(t, db))
+	})
+}
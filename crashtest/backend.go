@@ -0,0 +1,213 @@
+package crashtest
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"mydb/file"
+	"sync"
+)
+
+// ErrPowerLoss is returned by every operation of a Backend once it has lost power.
+var ErrPowerLoss = errors.New("crashtest: power loss")
+
+// Backend is an in-memory file.Backend that models the difference between written and durable data.
+// It keeps two file.MemoryBackends: one with the contents seen by readers, and one with the contents last made
+// durable by Sync.
+// A Backend can be told to lose power before a given write: that write and every operation after it fail
+// with ErrPowerLoss, and Durable returns what would be found on disk after a restart.
+// File creation and removal are durable immediately. The Backend is thread-safe.
+type Backend struct {
+	mu      sync.Mutex
+	live    *file.MemoryBackend
+	durable *file.MemoryBackend
+	writes  int
+	crashAt int
+	crashed bool
+}
+
+// NewBackend returns an empty Backend that never loses power.
+func NewBackend() *Backend {
+	return &Backend{live: file.NewMemoryBackend(), durable: file.NewMemoryBackend(), crashAt: -1}
+}
+
+// CrashBefore makes the Backend lose power just before its n-th write, counting from zero.
+// Both WriteAt and Sync count as writes. A negative n disables the crash.
+func (b *Backend) CrashBefore(n int) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.crashAt = n
+}
+
+// Writes returns the number of writes performed so far.
+func (b *Backend) Writes() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.writes
+}
+
+// Crashed returns true if the Backend has lost power.
+func (b *Backend) Crashed() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.crashed
+}
+
+// Durable returns a new Backend holding only the durable contents of this one, as a restarted machine would see them.
+func (b *Backend) Durable() (*Backend, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	durable := NewBackend()
+	names, err := b.durable.List()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range names {
+		if err := copyFile(durable.live, b.durable, name); err != nil {
+			return nil, err
+		}
+		if err := copyFile(durable.durable, b.durable, name); err != nil {
+			return nil, err
+		}
+	}
+	return durable, nil
+}
+
+// write counts a write and reports whether it may go ahead. It must be called with b.mu held.
+func (b *Backend) write() error {
+	if b.crashed {
+		return ErrPowerLoss
+	}
+	if b.writes == b.crashAt {
+		b.crashed = true
+		return ErrPowerLoss
+	}
+	b.writes++
+	return nil
+}
+
+func (b *Backend) Open(name string) (file.File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashed {
+		return nil, ErrPowerLoss
+	}
+	f, err := b.live.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	// Creating the file is durable immediately.
+	if _, err := b.durable.Open(name); err != nil {
+		return nil, err
+	}
+	return &crashFile{File: f, backend: b, name: name}, nil
+}
+
+func (b *Backend) Remove(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashed {
+		return ErrPowerLoss
+	}
+	if err := b.live.Remove(name); err != nil {
+		return err
+	}
+	return b.durable.Remove(name)
+}
+
+func (b *Backend) List() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.crashed {
+		return nil, ErrPowerLoss
+	}
+	return b.live.List()
+}
+
+// crashFile is a file of a Backend. Reads and writes go to the live copy of the file; Sync copies it to the durable
+// one.
+type crashFile struct {
+	file.File
+	backend *Backend
+	name    string
+}
+
+func (f *crashFile) ReadAt(p []byte, off int64) (int, error) {
+	f.backend.mu.Lock()
+	defer f.backend.mu.Unlock()
+
+	if f.backend.crashed {
+		return 0, ErrPowerLoss
+	}
+	return f.File.ReadAt(p, off)
+}
+
+func (f *crashFile) WriteAt(p []byte, off int64) (int, error) {
+	f.backend.mu.Lock()
+	defer f.backend.mu.Unlock()
+
+	if err := f.backend.write(); err != nil {
+		return 0, err
+	}
+	return f.File.WriteAt(p, off)
+}
+
+func (f *crashFile) Size() (int64, error) {
+	f.backend.mu.Lock()
+	defer f.backend.mu.Unlock()
+
+	if f.backend.crashed {
+		return 0, ErrPowerLoss
+	}
+	return f.File.Size()
+}
+
+// Truncate counts as a write. Like the data written, the new size only becomes durable with Sync.
+func (f *crashFile) Truncate(size int64) error {
+	f.backend.mu.Lock()
+	defer f.backend.mu.Unlock()
+
+	if err := f.backend.write(); err != nil {
+		return err
+	}
+	return f.File.Truncate(size)
+}
+
+func (f *crashFile) Sync() error {
+	f.backend.mu.Lock()
+	defer f.backend.mu.Unlock()
+
+	if err := f.backend.write(); err != nil {
+		return err
+	}
+	return copyFile(f.backend.durable, f.backend.live, f.name)
+}
+
+// copyFile copies the contents of file name from src to dst, truncating dst to the size of src.
+func copyFile(dst, src file.Backend, name string) error {
+	from, err := src.Open(name)
+	if err != nil {
+		return err
+	}
+	to, err := dst.Open(name)
+	if err != nil {
+		return err
+	}
+	size, err := from.Size()
+	if err != nil {
+		return err
+	}
+	data := make([]byte, size)
+	if _, err := from.ReadAt(data, 0); err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot copy %s: %v", name, err)
+	}
+	if err := to.Truncate(size); err != nil {
+		return err
+	}
+	_, err = to.WriteAt(data, 0)
+	return err
+}
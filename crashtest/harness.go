@@ -0,0 +1,182 @@
+// Package crashtest checks that a database survives a power loss at any point of a workload.
+//
+// A Workload is a scripted sequence of transactions. Explore runs it against an in-memory Backend, once for every
+// write the workload causes, losing power just before that write. After each crash the database is reopened from
+// the durable contents of the backend, which runs recovery, and the Ledger kept by the workload checks that every
+// committed change is present and no uncommitted change is.
+package crashtest
+
+import (
+	"fmt"
+	"mydb"
+	"mydb/file"
+	"mydb/tx"
+	"sort"
+)
+
+// Workload is run against a freshly created database. It must make its changes through the Ledger,
+// and return the first error it encounters.
+type Workload func(db *mydb.DB, ledger *Ledger) error
+
+// Explore runs workload once without a crash to count its writes, and then once for every write with power lost
+// just before it, plus once with power lost after the last write. Each run starts from an empty database opened
+// with opts. Explore returns the number of crash points checked, and an error describing the first crash point
+// at which recovery left the database in a state that contradicts the ledger.
+func Explore(workload Workload, opts ...mydb.Option) (int, error) {
+	backend := NewBackend()
+	if _, err := run(backend, workload, opts); err != nil {
+		return 0, fmt.Errorf("workload failed without a crash: %v", err)
+	}
+	writes := backend.Writes()
+
+	for crashPoint := 0; crashPoint <= writes; crashPoint++ {
+		backend := NewBackend()
+		backend.CrashBefore(crashPoint)
+		ledger, err := run(backend, workload, opts)
+		if crashPoint < writes && !backend.Crashed() {
+			return crashPoint, fmt.Errorf("crash before write %d: workload finished without reaching it, err: %v",
+				crashPoint, err)
+		}
+
+		durable, err := backend.Durable()
+		if err != nil {
+			return crashPoint, fmt.Errorf("crash before write %d: cannot restart backend: %v", crashPoint, err)
+		}
+		db, err := mydb.Open("", append(opts, mydb.WithBackend(durable))...)
+		if err != nil {
+			return crashPoint, fmt.Errorf("crash before write %d: cannot reopen database: %v", crashPoint, err)
+		}
+		if err := ledger.Verify(db); err != nil {
+			return crashPoint, fmt.Errorf("crash before write %d: %v", crashPoint, err)
+		}
+	}
+	return writes + 1, nil
+}
+
+// run opens a new database on backend and runs workload against it.
+func run(backend *Backend, workload Workload, opts []mydb.Option) (*Ledger, error) {
+	ledger := NewLedger()
+	db, err := mydb.Open("", append(opts, mydb.WithBackend(backend))...)
+	if err != nil {
+		return ledger, err
+	}
+	return ledger, workload(db, ledger)
+}
+
+// cell identifies an integer stored in a block.
+type cell struct {
+	block  file.BlockId
+	offset int
+}
+
+func (c cell) String() string {
+	return fmt.Sprintf("%s offset %d", c.block.String(), c.offset)
+}
+
+// Ledger records the integers written by a workload and the outcome of every transaction,
+// which tells what the database must hold after a crash. Cells that were never written by a committed
+// transaction must hold zero.
+type Ledger struct {
+	cells     map[cell]bool
+	committed map[cell]int
+	pending   map[*tx.Transaction]map[cell]int
+	// inDoubt holds the changes of a transaction whose commit was interrupted. After recovery,
+	// either all or none of them may be present.
+	inDoubt map[cell]int
+}
+
+// NewLedger returns an empty Ledger.
+func NewLedger() *Ledger {
+	return &Ledger{
+		cells:     make(map[cell]bool),
+		committed: make(map[cell]int),
+		pending:   make(map[*tx.Transaction]map[cell]int),
+	}
+}
+
+// SetInt writes val at offset of block in transaction t and records the change. The block must be pinned by t.
+func (l *Ledger) SetInt(t *tx.Transaction, block *file.BlockId, offset int, val int) error {
+	if err := t.SetInt(block, offset, val, true); err != nil {
+		return err
+	}
+	c := cell{block: *block, offset: offset}
+	l.cells[c] = true
+	if l.pending[t] == nil {
+		l.pending[t] = make(map[cell]int)
+	}
+	l.pending[t][c] = val
+	return nil
+}
+
+// Commit commits t. If the commit fails, its changes are in doubt: after recovery they must be either all present
+// or all absent.
+func (l *Ledger) Commit(t *tx.Transaction) error {
+	changes := l.pending[t]
+	delete(l.pending, t)
+	if err := t.Commit(); err != nil {
+		l.inDoubt = changes
+		return err
+	}
+	for c, val := range changes {
+		l.committed[c] = val
+	}
+	return nil
+}
+
+// Rollback rolls back t. Its changes must be absent after recovery whether or not the rollback completes.
+func (l *Ledger) Rollback(t *tx.Transaction) error {
+	delete(l.pending, t)
+	return t.Rollback()
+}
+
+// Verify reads every cell written by the workload from db and checks it against the ledger.
+func (l *Ledger) Verify(db *mydb.DB) error {
+	t, err := db.NewTx()
+	if err != nil {
+		return err
+	}
+
+	cells := make([]cell, 0, len(l.cells))
+	for c := range l.cells {
+		cells = append(cells, c)
+	}
+	sort.Slice(cells, func(i, j int) bool {
+		if cells[i].block != cells[j].block {
+			return cells[i].block.String() < cells[j].block.String()
+		}
+		return cells[i].offset < cells[j].offset
+	})
+
+	applied, discarded := 0, 0
+	for _, c := range cells {
+		if err := t.Pin(&c.block); err != nil {
+			return err
+		}
+		val, err := t.GetInt(&c.block, c.offset)
+		if err != nil {
+			return err
+		}
+		t.Unpin(&c.block)
+
+		expected := l.committed[c]
+		if newVal, ok := l.inDoubt[c]; ok && newVal != expected {
+			switch val {
+			case newVal:
+				applied++
+				continue
+			case expected:
+				discarded++
+				continue
+			}
+			return fmt.Errorf("%s holds %d, expected %d from the interrupted commit or %d from before it",
+				c, val, newVal, expected)
+		}
+		if val != expected {
+			return fmt.Errorf("%s holds %d, expected %d", c, val, expected)
+		}
+	}
+	if applied > 0 && discarded > 0 {
+		return fmt.Errorf("interrupted commit is partially applied: %d changes present, %d absent", applied, discarded)
+	}
+	return t.Commit()
+}
@@ -0,0 +1,197 @@
+package crashtest
+
+import (
+	"mydb"
+	"mydb/file"
+	"mydb/tx"
+	"mydb/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// bankWorkload commits, overwrites, rolls back and finally leaves a transaction unfinished. The buffer pool is
+// small enough that the unfinished transaction has its pages stolen and written to disk before the end.
+func bankWorkload(db *mydb.DB, ledger *Ledger) error {
+	blocks := make([]*file.BlockId, 4)
+	for i := range blocks {
+		block, err := db.FileManager().Append("accounts")
+		if err != nil {
+			return err
+		}
+		blocks[i] = block
+	}
+
+	// setAll writes val into the first two slots of every block, unpinning each block once written.
+	setAll := func(val int) (*tx.Transaction, error) {
+		t, err := db.NewTx()
+		if err != nil {
+			return nil, err
+		}
+		for _, block := range blocks {
+			if err := t.Pin(block); err != nil {
+				return nil, err
+			}
+			for slot := 0; slot < 2; slot++ {
+				if err := ledger.SetInt(t, block, slot*utils.IntSize, val+slot); err != nil {
+					return nil, err
+				}
+			}
+			t.Unpin(block)
+		}
+		return t, nil
+	}
+
+	for _, step := range []struct {
+		val    int
+		commit bool
+	}{{100, true}, {200, true}, {300, false}} {
+		t, err := setAll(step.val)
+		if err != nil {
+			return err
+		}
+		if step.commit {
+			err = ledger.Commit(t)
+		} else {
+			err = ledger.Rollback(t)
+		}
+		if err != nil {
+			return err
+		}
+	}
+
+	// Left unfinished: recovery must undo it.
+	_, err := setAll(400)
+	return err
+}
+
+func TestExplore(t *testing.T) {
+	crashPoints, err := Explore(bankWorkload, mydb.WithBufferCount(2), mydb.WithBlockSize(128))
+	require.NoError(t, err)
+	assert.Greater(t, crashPoints, 50)
+
+	t.Run("no steal", func(t *testing.T) {
+		_, err := Explore(bankWorkload, mydb.WithNoSteal(), mydb.WithBufferCount(8), mydb.WithBlockSize(128))
+		require.NoError(t, err)
+	})
+}
+
+func TestLedgerDetectsLostCommit(t *testing.T) {
+	backend := NewBackend()
+	db, err := mydb.Open("", mydb.WithBackend(backend))
+	require.NoError(t, err)
+
+	ledger := NewLedger()
+	block, err := db.FileManager().Append("accounts")
+	require.NoError(t, err)
+	tx1, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx1.Pin(block))
+	require.NoError(t, ledger.SetInt(tx1, block, 0, 7))
+	require.NoError(t, ledger.Commit(tx1))
+
+	// Erase the committed value behind the database's back
+	require.NoError(t, db.FileManager().Write(block, file.NewPage(db.FileManager().BlockSize())))
+	durable, err := backend.Durable()
+	require.NoError(t, err)
+	restarted, err := mydb.Open("", mydb.WithBackend(durable))
+	require.NoError(t, err)
+	assert.ErrorContains(t, ledger.Verify(restarted), "holds 0, expected 7")
+}
+
+func TestBackendLosesUnsyncedWrites(t *testing.T) {
+	backend := NewBackend()
+	f, err := backend.Open("data")
+	require.NoError(t, err)
+
+	_, err = f.WriteAt([]byte("durable"), 0)
+	require.NoError(t, err)
+	require.NoError(t, f.Sync())
+	_, err = f.WriteAt([]byte("VOLATILE"), 0)
+	require.NoError(t, err)
+	assert.Equal(t, 3, backend.Writes())
+
+	backend.CrashBefore(3)
+	assert.ErrorIs(t, f.Sync(), ErrPowerLoss)
+	assert.True(t, backend.Crashed())
+	_, err = f.ReadAt(make([]byte, 1), 0)
+	assert.ErrorIs(t, err, ErrPowerLoss)
+
+	durable, err := backend.Durable()
+	require.NoError(t, err)
+	restarted, err := durable.Open("data")
+	require.NoError(t, err)
+	buf := make([]byte, 7)
+	_, err = restarted.ReadAt(buf, 0)
+	require.NoError(t, err)
+	assert.Equal(t, "durable", string(buf))
+}
+
+// lostTruncateBackend is a Backend on which truncating the file named lost calls beforeLoss, if set, and then
+// does nothing, as if the machine crashed before the truncation reached the disk.
+type lostTruncateBackend struct {
+	*Backend
+	lost       string
+	beforeLoss func()
+}
+
+func (b *lostTruncateBackend) Open(name string) (file.File, error) {
+	f, err := b.Backend.Open(name)
+	if err != nil || name != b.lost {
+		return f, err
+	}
+	return &lostTruncateFile{File: f, backend: b}, nil
+}
+
+type lostTruncateFile struct {
+	file.File
+	backend *lostTruncateBackend
+}
+
+func (f *lostTruncateFile) Truncate(size int64) error {
+	if f.backend.beforeLoss == nil {
+		return f.File.Truncate(size)
+	}
+	f.backend.beforeLoss()
+	return nil
+}
+
+func TestCheckpointWaitsForCommittedTruncate(t *testing.T) {
+	backend := &lostTruncateBackend{Backend: NewBackend(), lost: "accounts"}
+	db, err := mydb.Open("", mydb.WithBackend(backend), mydb.WithCheckpointWaitTime(10*time.Millisecond))
+	require.NoError(t, err)
+
+	for range 3 {
+		_, err := db.FileManager().Append("accounts")
+		require.NoError(t, err)
+	}
+	transaction, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, transaction.Truncate("accounts", 1))
+
+	// A checkpoint between the commit record and the truncation would keep recovery from redoing it. The file
+	// manager is busy truncating, so a checkpoint that is let through cannot write its record before the truncation
+	// returns.
+	checkpointed := make(chan error, 1)
+	backend.beforeLoss = func() {
+		go func() { checkpointed <- db.Checkpoint() }()
+		select {
+		case err := <-checkpointed:
+			checkpointed <- err
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+	require.NoError(t, transaction.Commit())
+	assert.ErrorIs(t, <-checkpointed, tx.ErrActiveTransactions, "the committed transaction is still active")
+
+	durable, err := backend.Durable()
+	require.NoError(t, err)
+	restarted, err := mydb.Open("", mydb.WithBackend(durable))
+	require.NoError(t, err)
+	defer restarted.Close()
+	length, err := restarted.FileManager().Length("accounts")
+	require.NoError(t, err)
+	assert.Equal(t, 1, length, "recovery redoes the truncation")
+}
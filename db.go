@@ -0,0 +1,265 @@
+package mydb
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"sync"
+	"time"
+)
+
+// ErrClosed is returned when an operation is attempted on a closed DB.
+var ErrClosed = errors.New("database is closed")
+
+// DB wires together the file, log, buffer and lock managers of a single database directory.
+// It is the entry point for embedders: open a DB, create transactions from it, and close it when done.
+// The DB is thread-safe.
+type DB struct {
+	fileManager   *file.Manager
+	logManager    *log.Manager
+	bufferManager *buffer.Manager
+	lockTable     *concurrency.LockTable
+	epoch         int64
+	mu            sync.Mutex
+	closed        bool
+	checkpointer  *checkpointer
+	// checkpointWait bounds how long a checkpoint or snapshot waits for active transactions.
+	checkpointWait time.Duration
+	logFile        string
+	progress       func(tx.RecoveryProgress)
+	location       *time.Location
+}
+
+// Open opens (or creates) the database in directory, using the default options adjusted by opts.
+func Open(directory string, opts ...Option) (*DB, error) {
+	options := DefaultOptions()
+	options.Directory = directory
+	for _, opt := range opts {
+		opt(&options)
+	}
+	return OpenWithOptions(options)
+}
+
+// OpenWithOptions opens (or creates) the database described by opts.
+// Either a directory, a backend or in-memory mode must be given.
+// The managers are created in dependency order, and if the directory already held a database that was not shut down
+// cleanly, recovery is run before OpenWithOptions returns, so every transaction created afterward sees a consistent
+// state. Databases written in an older format are upgraded in place; those written in a newer format are refused.
+func OpenWithOptions(opts Options) (*DB, error) {
+	if opts.Directory == "" && opts.Backend == nil && !opts.InMemory {
+		return nil, errors.New("database directory must be specified")
+	}
+	opts = opts.withDefaults()
+
+	strategy, err := buffer.NewStrategy(opts.ReplacementStrategy)
+	if err != nil {
+		return nil, err
+	}
+	location, err := time.LoadLocation(opts.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid time zone %q: %v", opts.TimeZone, err)
+	}
+
+	fileOpts := []file.Option{file.WithSyncPolicy(opts.SyncPolicy), file.WithMaxOpenFiles(opts.MaxOpenFiles),
+		file.WithExtentSize(opts.ExtentSize)}
+	if opts.DirectIO {
+		fileOpts = append(fileOpts, file.WithDirectIO())
+	}
+	if opts.MmapReads {
+		fileOpts = append(fileOpts, file.WithMmapReads())
+	}
+	if opts.DoubleWrite {
+		fileOpts = append(fileOpts, file.WithDoubleWrite())
+	}
+	if opts.ReadOnly {
+		fileOpts = append(fileOpts, file.WithReadOnly())
+	}
+	var fileManager *file.Manager
+	if opts.Backend != nil {
+		fileManager, err = file.NewManagerWithBackend(opts.Backend, opts.BlockSize, fileOpts...)
+	} else {
+		fileManager, err = file.NewManager(opts.Directory, opts.BlockSize, fileOpts...)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create file manager: %v", err)
+	}
+	// The superblock is read first, so that a database opened with the wrong block size is refused before the log
+	// manager writes to it.
+	sb, err := readSuperblock(fileManager)
+	switch {
+	case errors.Is(err, errNoSuperblock):
+		// A new database, or one created before superblocks existed: its log decides whether recovery is needed.
+		sb = superblock{version: FormatVersion}
+	case err != nil:
+		return nil, err
+	}
+	logManager, err := log.NewManager(fileManager, opts.LogFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create log manager: %v", err)
+	}
+	bufferOpts := []buffer.Option{buffer.WithMaxWaitTime(opts.BufferWaitTime), buffer.WithTempPool(opts.TempBufferCount)}
+	if opts.NoSteal {
+		bufferOpts = append(bufferOpts, buffer.WithNoSteal())
+	}
+	if opts.HugePages {
+		bufferOpts = append(bufferOpts, buffer.WithHugePages())
+	}
+	bufferManager := buffer.NewManagerWithReplacementStrategy(fileManager, logManager, opts.BufferCount, strategy,
+		bufferOpts...)
+
+	db := &DB{
+		fileManager:    fileManager,
+		logManager:     logManager,
+		bufferManager:  bufferManager,
+		lockTable:      concurrency.NewLockTable(lockTableOptions(opts)...),
+		checkpointWait: opts.CheckpointWaitTime,
+		logFile:        opts.LogFile,
+		progress:       opts.RecoveryProgress,
+		location:       location,
+	}
+
+	if err := db.start(sb); err != nil {
+		return nil, err
+	}
+	if !opts.ReadOnly && (opts.CheckpointInterval > 0 || opts.CheckpointLogSize > 0) {
+		db.checkpointer = startCheckpointer(db, opts.CheckpointInterval, opts.CheckpointLogSize)
+	}
+	return db, nil
+}
+
+// lockTableOptions translates the lock settings of opts into lock table options.
+func lockTableOptions(opts Options) []concurrency.Option {
+	lockOpts := []concurrency.Option{
+		concurrency.WithMaxWaitTime(opts.LockWaitTime),
+		concurrency.WithSlowWaitThreshold(opts.SlowLockWaitTime),
+	}
+	if opts.LockWaitMinTime > 0 {
+		lockOpts = append(lockOpts, concurrency.WithAdaptiveWaitTime(opts.LockWaitMinTime))
+	}
+	return lockOpts
+}
+
+// start checks the superblock sb and, unless the previous run shut down cleanly, removes the unlogged files and runs
+// recovery. It then marks the database as in use, so that a crash before Close is detected by the next start.
+func (db *DB) start(sb superblock) error {
+	if sb.version > FormatVersion {
+		return fmt.Errorf("database format version %d is newer than supported version %d", sb.version, FormatVersion)
+	}
+
+	db.epoch = sb.epoch
+	if db.fileManager.ReadOnly() {
+		if sb.version < FormatVersion {
+			return fmt.Errorf("database format version %d must be upgraded before it can be opened read-only", sb.version)
+		}
+		return nil
+	}
+	sb, err := migrate(db.fileManager, sb, FormatVersion, migrations)
+	if err != nil {
+		return err
+	}
+	if err := tx.ResumeTxNumbers(db.logManager); err != nil {
+		return fmt.Errorf("failed to read transaction numbers from the log: %v", err)
+	}
+	if !db.fileManager.IsNew() && !sb.clean {
+		// Unlogged files may hold changes of unfinished transactions that nothing can undo.
+		if err := db.fileManager.RemoveUnloggedFiles(); err != nil {
+			return fmt.Errorf("failed to remove unlogged files: %v", err)
+		}
+		if err := db.recover(); err != nil {
+			return fmt.Errorf("failed to recover database: %v", err)
+		}
+	}
+	db.epoch++
+	return writeSuperblock(db.fileManager, superblock{version: FormatVersion, epoch: db.epoch, clean: false})
+}
+
+// recover rolls back the transactions left unfinished by the previous run.
+func (db *DB) recover() error {
+	recoveryTx := tx.NewTransaction(db.fileManager, db.logManager, db.bufferManager, db.lockTable)
+	if err := recoveryTx.RecoverWithProgress(db.progress); err != nil {
+		return err
+	}
+	// The recovery transaction pins and locks the blocks it restores; committing releases them.
+	return recoveryTx.Commit()
+}
+
+// NewTx starts a new transaction on the database.
+func (db *DB) NewTx() (*tx.Transaction, error) {
+	return db.NewTxWithContext(context.Background())
+}
+
+// NewTxWithContext starts a new transaction whose trace span is a child of the span carried by ctx, and which is
+// rolled back once ctx is done. See tx.NewTransactionWithContext.
+func (db *DB) NewTxWithContext(ctx context.Context) (*tx.Transaction, error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil, ErrClosed
+	}
+	t := tx.NewTransactionWithContext(ctx, db.fileManager, db.logManager, db.bufferManager, db.lockTable)
+	t.SetLocation(db.location)
+	return t, nil
+}
+
+// FileManager returns the file manager of the database.
+func (db *DB) FileManager() *file.Manager {
+	return db.fileManager
+}
+
+// LogManager returns the log manager of the database.
+func (db *DB) LogManager() *log.Manager {
+	return db.logManager
+}
+
+// BufferManager returns the buffer manager of the database.
+func (db *DB) BufferManager() *buffer.Manager {
+	return db.bufferManager
+}
+
+// LockTable returns the lock table shared by all transactions of the database.
+func (db *DB) LockTable() *concurrency.LockTable {
+	return db.lockTable
+}
+
+// Epoch returns the number of times the database has been opened for writing, this time included.
+func (db *DB) Epoch() int64 {
+	return db.epoch
+}
+
+// Close closes the database: the log is flushed and the database files are closed. Transactions can no longer be
+// created once Close has been called, and unfinished ones fail when they next access a block on disk.
+// If every transaction that wrote to the log has been committed or rolled back, whether or not it was created by
+// NewTx, Close records a clean shutdown in the superblock and the next Open skips recovery; otherwise the next Open
+// rolls back the unfinished transactions.
+// Closing an already closed database is a no-op. An error of the background checkpointer that was not reported
+// before is returned here.
+func (db *DB) Close() error {
+	var checkpointErr error
+	if db.checkpointer != nil {
+		// The checkpointer takes db.mu itself, so it must be stopped first.
+		checkpointErr = db.checkpointer.stop()
+	}
+
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return nil
+	}
+	db.closed = true
+	errs := []error{checkpointErr}
+	if !db.fileManager.ReadOnly() {
+		errs = append(errs, db.logManager.Close())
+		if tx.ActiveTransactions(db.logManager) == 0 {
+			errs = append(errs, writeSuperblock(db.fileManager, superblock{version: FormatVersion, epoch: db.epoch, clean: true}))
+		}
+	}
+	errs = append(errs, db.fileManager.Close())
+	return errors.Join(errs...)
+}
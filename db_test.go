@@ -0,0 +1,207 @@
+package mydb
+
+import (
+	"mydb/file"
+	"mydb/tx"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOpenAndRecover(t *testing.T) {
+	dir, err := os.MkdirTemp("", "mydb_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+	require.NoError(t, os.Remove(dir)) // let Open create a brand-new database
+
+	db, err := Open(dir)
+	require.NoError(t, err)
+	assert.True(t, db.FileManager().IsNew())
+
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+
+	// Commit a value
+	tx1, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx1.Pin(block))
+	require.NoError(t, tx1.SetInt(block, 0, 42, true))
+	require.NoError(t, tx1.Commit())
+
+	// Overwrite it without committing, and force the dirty page to disk as if the buffer had been evicted
+	tx2, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx2.Pin(block))
+	require.NoError(t, tx2.SetInt(block, 0, 99, true))
+	require.NoError(t, db.BufferManager().FlushAll(tx2.TxNum()))
+	require.NoError(t, db.Close())
+
+	_, err = db.NewTx()
+	assert.ErrorIs(t, err, ErrClosed)
+	assert.ErrorContains(t, db.FileManager().Read(block, file.NewPage(db.FileManager().BlockSize())), file.ErrClosed.Error())
+
+	// Reopening runs recovery, which must undo tx2's change
+	var reports []tx.RecoveryProgress
+	db, err = Open(dir, WithRecoveryProgress(func(p tx.RecoveryProgress) { reports = append(reports, p) }))
+	require.NoError(t, err)
+	defer db.Close()
+	assert.False(t, db.FileManager().IsNew())
+
+	require.NotEmpty(t, reports)
+	last := reports[len(reports)-1]
+	assert.Equal(t, tx.RecoveryDone, last.Phase)
+	assert.Equal(t, 1, last.RecordsUndone)
+	assert.Equal(t, 1, last.TransactionsUndone)
+	assert.Equal(t, 100.0, last.Percent())
+	assert.Equal(t, tx.RecoveryUndo, reports[0].Phase)
+
+	page := file.NewPage(db.FileManager().BlockSize())
+	require.NoError(t, db.FileManager().Read(block, page))
+	assert.Equal(t, 42, page.GetInt(0))
+
+	tx3, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx3.Pin(block))
+	val, err := tx3.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, val)
+	require.NoError(t, tx3.Commit())
+}
+
+func TestOpenRequiresDirectory(t *testing.T) {
+	_, err := Open("")
+	assert.Error(t, err)
+}
+
+func TestOpenReadOnly(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	db, err := Open(dir)
+	require.NoError(t, err)
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+	tx1, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx1.Pin(block))
+	require.NoError(t, tx1.SetInt(block, 0, 42, true))
+	require.NoError(t, tx1.Commit())
+	require.NoError(t, db.Close())
+
+	snapshot := func() map[string][]byte {
+		contents := make(map[string][]byte)
+		for _, name := range []string{"testfile", DefaultLogFile} {
+			data, err := os.ReadFile(filepath.Join(dir, name))
+			require.NoError(t, err)
+			contents[name] = data
+		}
+		return contents
+	}
+	before := snapshot()
+
+	db, err = Open(dir, WithReadOnly())
+	require.NoError(t, err)
+	defer db.Close()
+
+	reader, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, reader.Pin(block))
+	val, err := reader.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, val)
+	assert.ErrorIs(t, reader.SetInt(block, 0, 7, true), file.ErrReadOnly)
+	_, err = reader.Append("testfile")
+	assert.ErrorIs(t, err, file.ErrReadOnly)
+	require.NoError(t, reader.Commit())
+
+	assert.Equal(t, before, snapshot())
+
+	_, err = Open(filepath.Join(t.TempDir(), "missing"), WithReadOnly())
+	assert.Error(t, err)
+}
+
+func TestOpenInMemory(t *testing.T) {
+	db, err := Open("", WithInMemory())
+	require.NoError(t, err)
+	defer db.Close()
+	assert.True(t, db.FileManager().IsNew())
+	assert.Equal(t, file.SyncNever, db.FileManager().SyncPolicy())
+
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+
+	tx1, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx1.Pin(block))
+	require.NoError(t, tx1.SetInt(block, 0, 42, true))
+	require.NoError(t, tx1.Commit())
+
+	tx2, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx2.Pin(block))
+	require.NoError(t, tx2.SetInt(block, 0, 99, true))
+	require.NoError(t, tx2.Rollback())
+
+	tx3, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx3.Pin(block))
+	val, err := tx3.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, val)
+	require.NoError(t, tx3.Commit())
+}
+
+func TestUnloggedFiles(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	db, err := Open(dir)
+	require.NoError(t, err)
+	block, err := db.FileManager().Append("unlogged_scratch")
+	require.NoError(t, err)
+
+	tx1, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx1.Pin(block))
+	require.NoError(t, tx1.SetInt(block, 0, 42, true))
+	require.NoError(t, tx1.Commit())
+
+	tx2, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx2.Pin(block))
+	require.NoError(t, tx2.SetInt(block, 0, 99, true))
+	require.NoError(t, tx2.Rollback(), "rolling back uses the undo records kept in memory")
+
+	iter, err := db.LogManager().Iterator()
+	require.NoError(t, err)
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		assert.NotContains(t, record.String(), "unlogged_scratch")
+	}
+	require.NoError(t, db.Close())
+
+	// A clean shutdown keeps the file.
+	db, err = Open(dir)
+	require.NoError(t, err)
+	tx3, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, tx3.Pin(block))
+	val, err := tx3.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, val)
+	require.NoError(t, tx3.SetInt(block, 0, 7, true))
+	require.NoError(t, db.Close())
+
+	// tx3 never finished, so the file is removed.
+	db, err = Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+	files, err := db.FileManager().Files()
+	require.NoError(t, err)
+	assert.NotContains(t, files, "unlogged_scratch")
+	length, err := db.FileManager().Length("unlogged_scratch")
+	require.NoError(t, err)
+	assert.Equal(t, 0, length)
+}
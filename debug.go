@@ -0,0 +1,154 @@
+package mydb
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"mydb/buffer"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"net/http"
+	"text/tabwriter"
+	"time"
+)
+
+// DebugPath is the path under which embedders conventionally serve DebugHandler.
+const DebugPath = "/debug/mydb"
+
+// DebugState is a snapshot of the live state of a database, meant for finding out why it is stuck or slow.
+// Each part is taken separately, so the parts are not necessarily consistent with each other.
+type DebugState struct {
+	Time   time.Time `json:"time"`
+	Epoch  int64     `json:"epoch"`
+	Closed bool      `json:"closed"`
+	// BlocksRead and BlocksWritten count the block I/O of the file manager since the database was opened.
+	BlocksRead    int `json:"blocks_read"`
+	BlocksWritten int `json:"blocks_written"`
+	// LogBlocks is the size of the log file in blocks. LatestLSN is the LSN of the last appended record, FlushedLSN
+	// that of the last record on disk.
+	LogBlocks  int `json:"log_blocks"`
+	LatestLSN  int `json:"latest_lsn"`
+	FlushedLSN int `json:"flushed_lsn"`
+	// AvailableBuffers is the number of unpinned buffers in the main pool.
+	AvailableBuffers   int                    `json:"available_buffers"`
+	Buffers            []buffer.BufferState   `json:"buffers"`
+	ActiveTransactions []tx.ActiveTransaction `json:"active_transactions"`
+	Locks              []concurrency.Lock     `json:"locks"`
+	LockWaits          []concurrency.Wait     `json:"lock_waits"`
+	SlowLockWaits      []concurrency.SlowWait `json:"slow_lock_waits"`
+}
+
+// DebugState returns a snapshot of the buffer pool, the lock table, the active transactions and the log.
+func (db *DB) DebugState() DebugState {
+	db.mu.Lock()
+	closed := db.closed
+	db.mu.Unlock()
+
+	return DebugState{
+		Time:               time.Now(),
+		Epoch:              db.epoch,
+		Closed:             closed,
+		BlocksRead:         db.fileManager.GetBlocksRead(),
+		BlocksWritten:      db.fileManager.GetBlocksWritten(),
+		LogBlocks:          db.logManager.Size(),
+		LatestLSN:          db.logManager.LatestLSN(),
+		FlushedLSN:         db.logManager.FlushedLSN(),
+		AvailableBuffers:   db.bufferManager.Available(),
+		Buffers:            db.bufferManager.Buffers(),
+		ActiveTransactions: tx.ListActiveTransactions(db.logManager),
+		Locks:              db.lockTable.Locks(),
+		LockWaits:          db.lockTable.Waits(),
+		SlowLockWaits:      db.lockTable.SlowWaits(),
+	}
+}
+
+// DebugHandler returns an HTTP handler rendering the DebugState of the database as plain text, or as JSON when the
+// request has the query parameter format=json. It is usually served under DebugPath:
+//
+//	http.Handle(mydb.DebugPath, db.DebugHandler())
+//
+// The handler exposes file names and transaction numbers, so it should not be reachable by untrusted clients.
+func (db *DB) DebugHandler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			w.Header().Set("Allow", "GET, HEAD")
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		state := db.DebugState()
+		if r.URL.Query().Get("format") == "json" {
+			w.Header().Set("Content-Type", "application/json")
+			if err := json.NewEncoder(w).Encode(state); err != nil {
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			}
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		state.writeText(w)
+	})
+}
+
+// writeText renders the state as human-readable text.
+func (s DebugState) writeText(out io.Writer) {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	defer w.Flush()
+
+	fmt.Fprintf(w, "time\t%s\n", s.Time.Format(time.RFC3339))
+	fmt.Fprintf(w, "epoch\t%d\n", s.Epoch)
+	fmt.Fprintf(w, "closed\t%t\n", s.Closed)
+	fmt.Fprintf(w, "blocks read\t%d\n", s.BlocksRead)
+	fmt.Fprintf(w, "blocks written\t%d\n", s.BlocksWritten)
+
+	fmt.Fprintf(w, "\nlog\n")
+	fmt.Fprintf(w, "blocks\t%d\n", s.LogBlocks)
+	fmt.Fprintf(w, "latest lsn\t%d\n", s.LatestLSN)
+	fmt.Fprintf(w, "flushed lsn\t%d\n", s.FlushedLSN)
+
+	fmt.Fprintf(w, "\nbuffers (%d available)\n", s.AvailableBuffers)
+	fmt.Fprintf(w, "block\tpins\tmodified by\tpriority\tloading\n")
+	for _, b := range s.Buffers {
+		modifiedBy := "-"
+		if b.ModifiedBy >= 0 {
+			modifiedBy = fmt.Sprint(b.ModifiedBy)
+		}
+		fmt.Fprintf(w, "%s\t%d\t%s\t%d\t%t\n", b.Block.String(), b.Pins, modifiedBy, b.Priority, b.Loading)
+	}
+
+	fmt.Fprintf(w, "\nactive transactions\n")
+	fmt.Fprintf(w, "tx\tage\n")
+	for _, t := range s.ActiveTransactions {
+		fmt.Fprintf(w, "%d\t%v\n", t.TxNum, s.Time.Sub(t.Since).Round(time.Millisecond))
+	}
+
+	fmt.Fprintf(w, "\nlocks\n")
+	fmt.Fprintf(w, "block\tholders\n")
+	for _, l := range s.Locks {
+		fmt.Fprintf(w, "%s\t%s\n", l.Block.String(), formatHolders(l.Holders))
+	}
+
+	fmt.Fprintf(w, "\nlock waits\n")
+	fmt.Fprintf(w, "tx\tblock\tmode\twaited\tholders\n")
+	for _, wait := range s.LockWaits {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%v\t%s\n", wait.TxNum, wait.Block.String(), wait.Mode,
+			wait.Waited.Round(time.Millisecond), formatHolders(wait.Holders))
+	}
+
+	fmt.Fprintf(w, "\nslow lock waits\n")
+	fmt.Fprintf(w, "tx\tblock\tmode\tat\twaited\tgranted\n")
+	for _, wait := range s.SlowLockWaits {
+		fmt.Fprintf(w, "%d\t%s\t%s\t%s\t%v\t%t\n", wait.TxNum, wait.Block.String(), wait.Mode,
+			wait.Since.Format(time.RFC3339), wait.Waited.Round(time.Millisecond), wait.Granted)
+	}
+}
+
+// formatHolders renders lock holders as a list of transaction numbers with their lock modes.
+func formatHolders(holders []concurrency.Holder) string {
+	text := ""
+	for i, h := range holders {
+		if i > 0 {
+			text += " "
+		}
+		text += fmt.Sprintf("%d:%s", h.TxNum, h.Mode)
+	}
+	return text
+}
@@ -0,0 +1,75 @@
+package mydb
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDebugHandler(t *testing.T) {
+	db, err := Open("", WithInMemory(), WithSlowLockWaitTime(10*time.Millisecond))
+	require.NoError(t, err)
+	defer db.Close()
+
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+	writer, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetInt(block, 0, 42, true))
+
+	reader, err := db.NewTx()
+	require.NoError(t, err)
+	reader.SetLockWaitTime(20 * time.Millisecond)
+	require.NoError(t, reader.Pin(block))
+	_, err = reader.GetInt(block, 0)
+	require.Error(t, err, "the writer holds an exclusive lock")
+
+	state := db.DebugState()
+	require.Len(t, state.ActiveTransactions, 1)
+	assert.Equal(t, writer.TxNum(), state.ActiveTransactions[0].TxNum)
+	require.Len(t, state.Locks, 2, "the file holds intention locks")
+	assert.Equal(t, *block, state.Locks[1].Block)
+	require.Len(t, state.SlowLockWaits, 1)
+	assert.Equal(t, reader.TxNum(), state.SlowLockWaits[0].TxNum)
+	assert.False(t, state.SlowLockWaits[0].Granted)
+	require.Len(t, state.Buffers, 1)
+	assert.Equal(t, 2, state.Buffers[0].Pins)
+	assert.Equal(t, writer.TxNum(), state.Buffers[0].ModifiedBy)
+	assert.Equal(t, state.LatestLSN, state.FlushedLSN+2, "the start and update records are not flushed yet")
+
+	server := httptest.NewServer(db.DebugHandler())
+	defer server.Close()
+
+	resp, err := http.Get(server.URL)
+	require.NoError(t, err)
+	body, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	require.NoError(t, err)
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Contains(t, string(body), "active transactions")
+	assert.Contains(t, string(body), block.String())
+
+	resp, err = http.Get(server.URL + "?format=json")
+	require.NoError(t, err)
+	var decoded DebugState
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(&decoded))
+	resp.Body.Close()
+	assert.Len(t, decoded.ActiveTransactions, 1)
+
+	resp, err = http.Post(server.URL, "text/plain", strings.NewReader(""))
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusMethodNotAllowed, resp.StatusCode)
+
+	require.NoError(t, reader.Rollback())
+	require.NoError(t, writer.Rollback())
+	assert.Empty(t, db.DebugState().ActiveTransactions)
+}
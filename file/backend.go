@@ -0,0 +1,160 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// Backend is the storage that holds the files of a database. The Manager performs all of its I/O through a Backend,
+// which makes it possible to keep a database somewhere other than a directory on the local file system, or to
+// intercept I/O in tests.
+type Backend interface {
+	// Open opens the named file for reading and writing, creating it if it does not exist.
+	Open(name string) (File, error)
+	// Remove deletes the named file.
+	Remove(name string) error
+	// List returns the names of all files held by the backend.
+	List() ([]string, error)
+}
+
+// File is a file opened through a Backend. Like os.File, ReadAt returns io.EOF when it reads past the end of the file.
+type File interface {
+	io.ReaderAt
+	io.WriterAt
+	// Size returns the size of the file in bytes.
+	Size() (int64, error)
+	// Sync commits the contents of the file to stable storage.
+	Sync() error
+	// Truncate changes the size of the file to size bytes.
+	Truncate(size int64) error
+	Close() error
+}
+
+// dirBackend stores files in a directory of the local file system.
+type dirBackend struct {
+	dir      string
+	readOnly bool
+	// direct opens files for direct I/O, see directFile.
+	direct bool
+	// mmap serves reads from memory mappings, see mmapFile.
+	mmap bool
+}
+
+// newDirBackend returns a backend for the directory dir, creating the directory if necessary.
+// It also reports whether the directory had to be created. A read-only backend requires the directory to exist,
+// and opens existing files only.
+func newDirBackend(dir string, readOnly bool) (*dirBackend, bool, error) {
+	isNew := false
+	if _, err := os.Stat(dir); os.IsNotExist(err) {
+		if readOnly {
+			return nil, false, fmt.Errorf("cannot open directory %s read-only: %v", dir, err)
+		}
+		isNew = true
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, false, fmt.Errorf("cannot create directory %s: %v", dir, err)
+		}
+		if err := syncDir(filepath.Dir(dir)); err != nil {
+			return nil, false, fmt.Errorf("cannot sync directory %s: %v", filepath.Dir(dir), err)
+		}
+	} else if err != nil {
+		return nil, false, fmt.Errorf("cannot access directory %s: %v", dir, err)
+	}
+	return &dirBackend{dir: dir, readOnly: readOnly}, isNew, nil
+}
+
+// NewDirBackend returns a Backend that stores files in the directory dir, creating the directory if necessary.
+func NewDirBackend(dir string) (Backend, error) {
+	backend, _, err := newDirBackend(dir, false)
+	if err != nil {
+		return nil, err
+	}
+	return backend, nil
+}
+
+func (b *dirBackend) Open(name string) (File, error) {
+	path := filepath.Join(b.dir, name)
+	flags := os.O_RDWR
+	if b.readOnly {
+		flags = os.O_RDONLY
+	}
+	if b.direct {
+		flags |= directIOFlag
+	}
+	if !b.readOnly {
+		// A new file is only durable once the directory entry pointing to it is.
+		f, err := os.OpenFile(path, flags|os.O_CREATE|os.O_EXCL, 0666)
+		if err == nil {
+			if err := syncDir(b.dir); err != nil {
+				f.Close()
+				return nil, fmt.Errorf("cannot sync directory %s: %v", b.dir, err)
+			}
+			return b.wrap(f), nil
+		}
+		if !errors.Is(err, fs.ErrExist) {
+			return nil, fmt.Errorf("cannot open file %s: %v", path, err)
+		}
+	}
+	f, err := os.OpenFile(path, flags, 0666)
+	if err != nil {
+		return nil, fmt.Errorf("cannot open file %s: %v", path, err)
+	}
+	return b.wrap(f), nil
+}
+
+// wrap adapts an opened os.File to the File interface.
+func (b *dirBackend) wrap(f *os.File) File {
+	switch {
+	case b.direct:
+		return &directFile{osFile: &osFile{File: f}}
+	case b.mmap:
+		return &mmapFile{osFile: &osFile{File: f}}
+	default:
+		return &osFile{File: f}
+	}
+}
+
+func (b *dirBackend) Remove(name string) error {
+	if err := os.Remove(filepath.Join(b.dir, name)); err != nil {
+		return err
+	}
+	if err := syncDir(b.dir); err != nil {
+		return fmt.Errorf("cannot sync directory %s: %v", b.dir, err)
+	}
+	return nil
+}
+
+func (b *dirBackend) List() ([]string, error) {
+	entries, err := os.ReadDir(b.dir)
+	if err != nil {
+		return nil, fmt.Errorf("cannot read directory %s : %v", b.dir, err)
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			names = append(names, entry.Name())
+		}
+	}
+	return names, nil
+}
+
+// osFile adapts an os.File to the File interface.
+type osFile struct {
+	*os.File
+}
+
+// Sync commits the contents of the file to stable storage with the primitive that guarantees it on the platform.
+func (f *osFile) Sync() error {
+	return syncFile(f.File)
+}
+
+func (f *osFile) Size() (int64, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return 0, err
+	}
+	return info.Size(), nil
+}
@@ -0,0 +1,83 @@
+package file
+
+import (
+	"fmt"
+	"strings"
+)
+
+// DecimalSize is the number of bytes a decimal occupies in a page.
+const DecimalSize = 10
+
+// MaxDecimalPrecision is the largest number of digits a Decimal can have.
+const MaxDecimalPrecision = 18
+
+// Decimal is an exact fixed-point number, such as an amount of money: Unscaled times ten to the power of -Scale.
+// Precision is the number of digits the value may have in total, Scale the number of them after the decimal point,
+// so a DECIMAL(10, 2) column holds Decimals of precision 10 and scale 2. The zero Decimal is zero, with no digits.
+type Decimal struct {
+	Unscaled  int64
+	Precision uint8
+	Scale     uint8
+}
+
+// NewDecimal returns the decimal unscaled times ten to the power of -scale, with the given precision.
+func NewDecimal(unscaled int64, precision, scale int) (Decimal, error) {
+	if precision < 0 || precision > MaxDecimalPrecision || scale < 0 || scale > precision {
+		return Decimal{}, fmt.Errorf("invalid decimal precision %d and scale %d", precision, scale)
+	}
+	d := Decimal{Unscaled: unscaled, Precision: uint8(precision), Scale: uint8(scale)}
+	return d, d.Validate()
+}
+
+// ParseDecimal parses s, such as "-12.5", as a decimal of the given precision and scale. s may have fewer digits
+// after the decimal point than scale, but not more: rounding is left to the caller.
+func ParseDecimal(s string, precision, scale int) (Decimal, error) {
+	digits, negative := strings.CutPrefix(s, "-")
+	whole, fraction, _ := strings.Cut(digits, ".")
+	if whole == "" && fraction == "" || len(fraction) > scale {
+		return Decimal{}, fmt.Errorf("cannot parse %q as a decimal of scale %d", s, scale)
+	}
+	var unscaled int64
+	for _, c := range whole + fraction + strings.Repeat("0", scale-len(fraction)) {
+		if c < '0' || c > '9' {
+			return Decimal{}, fmt.Errorf("cannot parse %q as a decimal", s)
+		}
+		if unscaled > (1<<63-1)/10 {
+			return Decimal{}, fmt.Errorf("decimal %q has too many digits", s)
+		}
+		unscaled = unscaled*10 + int64(c-'0')
+	}
+	if negative {
+		unscaled = -unscaled
+	}
+	return NewDecimal(unscaled, precision, scale)
+}
+
+// Validate returns an error if the decimal has more digits than its precision, or its scale or precision is out of
+// range.
+func (d Decimal) Validate() error {
+	if d.Precision > MaxDecimalPrecision || d.Scale > d.Precision {
+		return fmt.Errorf("invalid decimal precision %d and scale %d", d.Precision, d.Scale)
+	}
+	limit := int64(1)
+	for range d.Precision {
+		limit *= 10
+	}
+	if d.Unscaled <= -limit || d.Unscaled >= limit {
+		return fmt.Errorf("decimal %s does not fit precision %d", d, d.Precision)
+	}
+	return nil
+}
+
+func (d Decimal) String() string {
+	sign, unscaled := "", uint64(d.Unscaled)
+	if d.Unscaled < 0 {
+		sign, unscaled = "-", uint64(-d.Unscaled)
+	}
+	digits := fmt.Sprintf("%0*d", int(d.Scale)+1, unscaled)
+	if d.Scale == 0 {
+		return sign + digits
+	}
+	point := len(digits) - int(d.Scale)
+	return sign + digits[:point] + "." + digits[point:]
+}
@@ -0,0 +1,61 @@
+package file
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// DirectIOAlignment is the alignment direct I/O requires of file offsets, lengths and memory. With WithDirectIO,
+// the block size must be a multiple of it.
+const DirectIOAlignment = 4096
+
+// AlignedBytes returns size bytes of memory aligned to DirectIOAlignment.
+func AlignedBytes(size int) []byte {
+	raw := make([]byte, size+DirectIOAlignment)
+	pad := (DirectIOAlignment - int(uintptr(unsafe.Pointer(&raw[0]))%DirectIOAlignment)) % DirectIOAlignment
+	return raw[pad : pad+size : pad+size]
+}
+
+func isAligned(b []byte) bool {
+	return len(b) == 0 || uintptr(unsafe.Pointer(&b[0]))%DirectIOAlignment == 0
+}
+
+// directFile is a file opened for direct I/O. The operating system transfers data straight between its memory and
+// the disk, which requires aligned offsets, lengths and memory. Blocks always have aligned offsets and lengths, and
+// pages are allocated aligned, but a page wrapping arbitrary bytes is copied through an aligned buffer.
+type directFile struct {
+	*osFile
+}
+
+func (f *directFile) ReadAt(b []byte, off int64) (int, error) {
+	if err := checkDirectRange(len(b), off); err != nil {
+		return 0, err
+	}
+	if isAligned(b) {
+		return f.osFile.ReadAt(b, off)
+	}
+	buf := AlignedBytes(len(b))
+	n, err := f.osFile.ReadAt(buf, off)
+	copy(b, buf[:n])
+	return n, err
+}
+
+func (f *directFile) WriteAt(b []byte, off int64) (int, error) {
+	if err := checkDirectRange(len(b), off); err != nil {
+		return 0, err
+	}
+	if isAligned(b) {
+		return f.osFile.WriteAt(b, off)
+	}
+	buf := AlignedBytes(len(b))
+	copy(buf, b)
+	return f.osFile.WriteAt(buf, off)
+}
+
+func checkDirectRange(length int, off int64) error {
+	if length%DirectIOAlignment != 0 || off%DirectIOAlignment != 0 {
+		return fmt.Errorf("direct I/O of %d bytes at offset %d is not aligned to %d bytes", length, off,
+			DirectIOAlignment)
+	}
+	return nil
+}
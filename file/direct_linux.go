@@ -0,0 +1,6 @@
+package file
+
+import "syscall"
+
+// directIOFlag is the open flag that bypasses the page cache.
+const directIOFlag = syscall.O_DIRECT
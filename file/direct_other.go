@@ -0,0 +1,6 @@
+//go:build !linux
+
+package file
+
+// directIOFlag is zero where direct I/O is not supported; WithDirectIO then makes NewManager fail.
+const directIOFlag = 0
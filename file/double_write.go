@@ -0,0 +1,166 @@
+package file
+
+import (
+	"fmt"
+	"hash/crc32"
+	"mydb/utils"
+)
+
+// DoubleWriteFile is the name of the file holding the double-write area, see WithDoubleWrite.
+const DoubleWriteFile = "mydb.dblwr"
+
+const doubleWriteMagic = 0x4d59444244424c57 // "MYDBDBLW"
+
+// Layout of block 0 of DoubleWriteFile, the header of the area: a checksum covering every byte after it, the magic
+// number, the number of pages in the area, and for each page the name of its file, its block number and the checksum
+// of its contents. The pages themselves follow in blocks 1 and up.
+const (
+	doubleWriteChecksumPos = 0
+	doubleWriteMagicPos    = 8
+	doubleWriteCountPos    = 16
+	doubleWriteEntriesPos  = doubleWriteCountPos + utils.IntSize
+)
+
+// WithDoubleWrite protects blocks against torn writes, which leave a block half old and half new when the machine
+// crashes in the middle of writing it, and which undo-only recovery cannot repair. Every block is first written to a
+// double-write area in DoubleWriteFile, which is synced, and only then written in place. When the Manager is created,
+// the blocks still in the area are written in place again. This costs two extra syncs per Write or WriteAll, and
+// writes are serialized; it only protects anything under SyncAlways. Temporary files are not protected.
+func WithDoubleWrite() Option {
+	return func(m *Manager) {
+		m.doubleWrite = true
+	}
+}
+
+// doublesWrites returns true if the writes to the named file go through the double-write area.
+func (m *Manager) doublesWrites(filename string) bool {
+	return m.doubleWrite && filename != DoubleWriteFile && !IsTempFile(filename)
+}
+
+// writeDoubled writes each page of pages to the block at the same index of blocks through the double-write area.
+// As many pages as the header of the area can describe are written at a time.
+func (m *Manager) writeDoubled(blocks []BlockId, pages []*Page) error {
+	m.doubleWriteMu.Lock()
+	defer m.doubleWriteMu.Unlock()
+
+	for len(blocks) > 0 {
+		header := NewPage(m.blockSize)
+		n, err := encodeDoubleWrite(header, blocks, pages)
+		if err != nil {
+			return err
+		}
+		area := make([]BlockId, n+1)
+		for i := range area {
+			area[i] = BlockId{File: DoubleWriteFile, BlockNumber: i}
+		}
+		if err := m.writeAll(area, append([]*Page{header}, pages[:n]...)); err != nil {
+			return fmt.Errorf("cannot write double-write area: %v", err)
+		}
+		if err := m.writeAll(blocks[:n], pages[:n]); err != nil {
+			return err
+		}
+		if err := m.Truncate(DoubleWriteFile, 0); err != nil {
+			return fmt.Errorf("cannot clear double-write area: %v", err)
+		}
+		blocks, pages = blocks[n:], pages[n:]
+	}
+	return nil
+}
+
+// restoreDoubleWrite writes the blocks left in the double-write area by a crash in place again, in case the crash
+// tore them, and then clears the area. An area that was torn itself was still being written when the crash came,
+// before any of its blocks were written in place, so it is simply cleared.
+func (m *Manager) restoreDoubleWrite() error {
+	length, err := m.Length(DoubleWriteFile)
+	if err != nil {
+		return fmt.Errorf("cannot read double-write area: %v", err)
+	}
+	if length == 0 {
+		return nil
+	}
+	header := NewPage(m.blockSize)
+	if err := m.Read(&BlockId{File: DoubleWriteFile, BlockNumber: 0}, header); err != nil {
+		return fmt.Errorf("cannot read double-write area: %v", err)
+	}
+	blocks, checksums, ok := decodeDoubleWrite(header)
+	if ok && len(blocks) < length {
+		area := make([]BlockId, len(blocks))
+		pages := make([]*Page, len(blocks))
+		for i := range area {
+			area[i] = BlockId{File: DoubleWriteFile, BlockNumber: i + 1}
+			pages[i] = NewPage(m.blockSize)
+		}
+		if err := m.ReadMany(area, pages); err != nil {
+			return fmt.Errorf("cannot read double-write area: %v", err)
+		}
+		for i, page := range pages {
+			ok = ok && pageChecksum(page) == checksums[i]
+		}
+		if ok {
+			if err := m.writeAll(blocks, pages); err != nil {
+				return fmt.Errorf("cannot restore blocks from double-write area: %v", err)
+			}
+		}
+	}
+	return m.Truncate(DoubleWriteFile, 0)
+}
+
+// encodeDoubleWrite writes the header describing as many of pages as fit to header, and returns how many that is.
+func encodeDoubleWrite(header *Page, blocks []BlockId, pages []*Page) (int, error) {
+	pos := doubleWriteEntriesPos
+	n := 0
+	for ; n < len(blocks); n++ {
+		size := MaxLength(len(blocks[n].Filename())) + utils.IntSize + 8
+		if pos+size > len(header.Contents()) {
+			break
+		}
+		if err := header.SetString(pos, blocks[n].Filename()); err != nil {
+			return 0, err
+		}
+		pos += MaxLength(len(blocks[n].Filename()))
+		header.SetInt(pos, blocks[n].Number())
+		pos += utils.IntSize
+		header.SetLong(pos, pageChecksum(pages[n]))
+		pos += 8
+	}
+	if n == 0 {
+		return 0, fmt.Errorf("cannot describe block %s in the double-write area", blocks[0].String())
+	}
+	header.SetInt(doubleWriteCountPos, n)
+	header.SetLong(doubleWriteMagicPos, doubleWriteMagic)
+	header.SetLong(doubleWriteChecksumPos, doubleWriteChecksum(header))
+	return n, nil
+}
+
+// decodeDoubleWrite returns the blocks described by header and the checksums of their pages. It returns false if
+// header is not a completely written header.
+func decodeDoubleWrite(header *Page) ([]BlockId, []int64, bool) {
+	if header.GetLong(doubleWriteMagicPos) != doubleWriteMagic ||
+		header.GetLong(doubleWriteChecksumPos) != doubleWriteChecksum(header) {
+		return nil, nil, false
+	}
+	count := header.GetInt(doubleWriteCountPos)
+	blocks := make([]BlockId, 0, count)
+	checksums := make([]int64, 0, count)
+	pos := doubleWriteEntriesPos
+	for range count {
+		name, err := header.GetString(pos)
+		if err != nil {
+			return nil, nil, false
+		}
+		pos += MaxLength(len(name))
+		blocks = append(blocks, BlockId{File: name, BlockNumber: header.GetInt(pos)})
+		pos += utils.IntSize
+		checksums = append(checksums, header.GetLong(pos))
+		pos += 8
+	}
+	return blocks, checksums, true
+}
+
+func doubleWriteChecksum(header *Page) int64 {
+	return int64(crc32.ChecksumIEEE(header.Contents()[doubleWriteMagicPos:]))
+}
+
+func pageChecksum(page *Page) int64 {
+	return int64(crc32.ChecksumIEEE(page.Contents()))
+}
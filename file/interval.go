@@ -0,0 +1,34 @@
+package file
+
+import (
+	"fmt"
+	"time"
+)
+
+// IntervalSize is the number of bytes an interval occupies in a page.
+const IntervalSize = 16
+
+// Interval is an amount of calendar time. Months and days are kept apart from the exact Duration because their
+// length depends on the date they are added to: a month is 28 to 31 days, and a day is 23 to 25 hours across a
+// daylight saving change.
+type Interval struct {
+	Months   int32
+	Days     int32
+	Duration time.Duration
+}
+
+// AddTo returns t moved forward by the interval: first by its months and days, in the location of t, as
+// time.AddDate does, then by its duration. Like AddDate, it normalizes instead of clamping to the end of the month:
+// January 31 plus one month is March 2 or 3.
+func (i Interval) AddTo(t time.Time) time.Time {
+	return t.AddDate(0, int(i.Months), int(i.Days)).Add(i.Duration)
+}
+
+// Negate returns the interval with every component negated, which AddTo moves a time backward by.
+func (i Interval) Negate() Interval {
+	return Interval{Months: -i.Months, Days: -i.Days, Duration: -i.Duration}
+}
+
+func (i Interval) String() string {
+	return fmt.Sprintf("%d months %d days %v", i.Months, i.Days, i.Duration)
+}
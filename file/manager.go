@@ -1,83 +1,298 @@
 package file
 
 import (
+	"container/list"
 	"errors"
 	"fmt"
 	"io"
-	"os"
-	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
 	"sync"
+	"sync/atomic"
 )
 
+// ErrReadOnly is returned when a write is attempted through a Manager opened read-only.
+var ErrReadOnly = errors.New("database is read-only")
+
+// ErrClosed is returned when a file is accessed through a Manager that has been closed.
+var ErrClosed = errors.New("file manager is closed")
+
 // Manager is the File Manager used by the database. It provides methods to read, write, and append blocks to disk.
 // The Manager is thread-safe.
 type Manager struct {
-	dbDirectory   string
+	backend       Backend
 	blockSize     int
+	syncPolicy    SyncPolicy
+	readOnly      bool
 	isNew         bool
+	maxOpenFiles  int
+	extentSize    int
+	directIO      bool
+	mmapReads     bool
+	doubleWrite   bool
+	doubleWriteMu sync.Mutex
 	mu            sync.Mutex
-	openFiles     map[string]*os.File
-	blocksRead    int
-	blocksWritten int
+	openFiles     map[string]*openFile
+	lru           *list.List
+	closed        bool
+	operating     bool
+	tempFiles     int
+	blocksRead    atomic.Int64
+	blocksWritten atomic.Int64
+}
+
+// Option configures optional behaviour of a Manager.
+type Option func(*Manager)
+
+// WithSyncPolicy sets how the Manager makes writes durable. The default is SyncAlways.
+func WithSyncPolicy(policy SyncPolicy) Option {
+	return func(m *Manager) {
+		m.syncPolicy = policy
+	}
+}
+
+// WithMaxOpenFiles caps the number of files the Manager keeps open at n. When opening another file would exceed the
+// cap, the least recently used file that no read or write is using is closed; it is reopened transparently on its
+// next access. If every open file is in use, the cap is exceeded until one is released. By default there is no cap,
+// and every file stays open until the Manager is closed.
+func WithMaxOpenFiles(n int) Option {
+	return func(m *Manager) {
+		m.maxOpenFiles = n
+	}
+}
+
+// WithExtentSize makes Append grow files by extents of n blocks instead of one block at a time. Appending the first
+// block of an extent writes and syncs all n blocks at once, and appending the others writes nothing, so that heavy
+// insert workloads do not sync once per block appended. The blocks preallocated past the end of a file are cut off
+// again when the file is closed; after a crash they remain, and the file then ends with up to n-1 blocks of zeros,
+// as if they had been appended and never written. By default, or when n is less than 2, nothing is preallocated.
+func WithExtentSize(n int) Option {
+	return func(m *Manager) {
+		m.extentSize = n
+	}
+}
+
+// WithDirectIO makes NewManager open files for direct I/O, bypassing the operating system's page cache so that
+// blocks are not cached twice, once by the buffer pool and once by the kernel. It is only supported on Linux, and
+// the block size must be a multiple of DirectIOAlignment. It has no effect on NewManagerWithBackend, whose backend
+// decides how files are opened.
+func WithDirectIO() Option {
+	return func(m *Manager) {
+		m.directIO = true
+	}
+}
+
+// WithMmapReads makes NewManager serve reads from memory mappings of the files instead of read system calls, which
+// speeds up scanning large files. Writes are unchanged. It is supported on Linux, macOS and the BSDs, and cannot be
+// combined with WithDirectIO. Like WithDirectIO, it has no effect on NewManagerWithBackend.
+func WithMmapReads() Option {
+	return func(m *Manager) {
+		m.mmapReads = true
+	}
 }
 
-func NewManager(dbDirectory string, blockSize int) (*Manager, error) {
-	isNew := false
-	if _, err := os.Stat(dbDirectory); os.IsNotExist(err) {
-		isNew = true
-		if err := os.MkdirAll(dbDirectory, 0755); err != nil {
-			return nil, fmt.Errorf("cannot create directory %s: %v", dbDirectory, err)
+// WithReadOnly makes the Manager reject every write with ErrReadOnly. The database directory must already exist,
+// its files are opened O_RDONLY, and leftover temporary files are not removed.
+func WithReadOnly() Option {
+	return func(m *Manager) {
+		m.readOnly = true
+	}
+}
+
+func NewManager(dbDirectory string, blockSize int, opts ...Option) (*Manager, error) {
+	m := newManager(blockSize, opts)
+	if m.directIO {
+		if directIOFlag == 0 {
+			return nil, errors.New("direct I/O is not supported on this platform")
+		}
+		if blockSize%DirectIOAlignment != 0 {
+			return nil, fmt.Errorf("direct I/O needs a block size that is a multiple of %d, not %d", DirectIOAlignment,
+				blockSize)
 		}
-	} else if err != nil {
-		return nil, fmt.Errorf("cannot access directory %s: %v", dbDirectory, err)
 	}
+	if m.mmapReads {
+		if !mmapSupported {
+			return nil, errors.New("memory-mapped reads are not supported on this platform")
+		}
+		if m.directIO {
+			return nil, errors.New("memory-mapped reads cannot be combined with direct I/O")
+		}
+	}
+	backend, isNew, err := newDirBackend(dbDirectory, m.readOnly)
+	if err != nil {
+		return nil, err
+	}
+	backend.direct = m.directIO
+	backend.mmap = m.mmapReads
+	if err := m.attach(backend, isNew); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// NewManagerWithBackend creates a Manager that keeps its files in backend instead of a directory.
+// The database is considered new if the backend holds no files.
+func NewManagerWithBackend(backend Backend, blockSize int, opts ...Option) (*Manager, error) {
+	names, err := backend.List()
+	if err != nil {
+		return nil, err
+	}
+	m := newManager(blockSize, opts)
+	if err := m.attach(backend, len(names) == 0); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func newManager(blockSize int, opts []Option) *Manager {
+	m := &Manager{
+		blockSize:  blockSize,
+		syncPolicy: SyncAlways,
+		openFiles:  make(map[string]*openFile),
+		lru:        list.New(),
+	}
+	for _, opt := range opts {
+		opt(m)
+	}
+	return m
+}
 
-	entries, err := os.ReadDir(dbDirectory)
+// attach makes backend the storage of the Manager, finishes the multi-file operation interrupted by a crash, if any,
+// restores the blocks left in the double-write area, and removes the temporary files left in it by a previous run.
+func (m *Manager) attach(backend Backend, isNew bool) error {
+	m.backend = backend
+	m.isNew = isNew
+	names, err := backend.List()
 	if err != nil {
-		return nil, fmt.Errorf("cannot read directory %s : %v", dbDirectory, err)
+		return err
+	}
+	if m.readOnly {
+		if slices.Contains(names, ManifestFile) {
+			return errors.New("an interrupted multi-file operation must be finished by opening the database for writing")
+		}
+		if slices.Contains(names, DoubleWriteFile) {
+			if length, err := m.Length(DoubleWriteFile); err != nil || length > 0 {
+				return errors.New("the double-write area must be restored by opening the database for writing")
+			}
+		}
+		return nil
 	}
 
-	for _, entry := range entries {
-		if !entry.IsDir() {
-			name := entry.Name()
-			if len(name) >= 4 && name[:4] == "temp" {
-				tempFilePath := filepath.Join(dbDirectory, name)
-				if err := os.Remove(tempFilePath); err != nil {
-					return nil, fmt.Errorf("cannot remove file %s: %v", tempFilePath, err)
-				}
+	if slices.Contains(names, ManifestFile) {
+		if err := m.resolveOperation(); err != nil {
+			return err
+		}
+	}
+	if slices.Contains(names, DoubleWriteFile) {
+		if err := m.restoreDoubleWrite(); err != nil {
+			return err
+		}
+	}
+	for _, name := range names {
+		if IsTempFile(name) {
+			if err := backend.Remove(name); err != nil {
+				return fmt.Errorf("cannot remove file %s: %v", name, err)
 			}
 		}
 	}
+	return nil
+}
 
-	return &Manager{
-		dbDirectory:   dbDirectory,
-		blockSize:     blockSize,
-		isNew:         isNew,
-		openFiles:     make(map[string]*os.File),
-		blocksRead:    0,
-		blocksWritten: 0,
-	}, nil
+// IsTempFile returns true if filename names a temporary file. Temporary files hold scratch data and are deleted
+// when the database is opened.
+func IsTempFile(filename string) bool {
+	return strings.HasPrefix(filename, "temp")
 }
 
-func (m *Manager) Read(block *BlockId, page *Page) error {
+// NewTempFile creates an empty temporary file with a name no other file has, and returns the name.
+func (m *Manager) NewTempFile() (string, error) {
+	if m.readOnly {
+		return "", ErrReadOnly
+	}
 	m.mu.Lock()
 	defer m.mu.Unlock()
+	existing, err := m.backend.List()
+	if err != nil {
+		return "", fmt.Errorf("cannot create temporary file: %v", err)
+	}
+	for {
+		m.tempFiles++
+		name := fmt.Sprintf("temp%d", m.tempFiles)
+		if slices.Contains(existing, name) {
+			continue
+		}
+		if _, err := m.getFile(name); err != nil {
+			return "", fmt.Errorf("cannot create temporary file %s: %v", name, err)
+		}
+		return name, nil
+	}
+}
+
+// IsUnloggedFile returns true if filename names an unlogged file. Changes to unlogged files are not written to the
+// log, which makes them cheaper, but their contents do not survive a crash: RemoveUnloggedFiles deletes them when
+// the database is recovered.
+func IsUnloggedFile(filename string) bool {
+	return strings.HasPrefix(filename, "unlogged")
+}
 
-	f, err := m.getFile(block.Filename())
+// RemoveUnloggedFiles closes and deletes every unlogged file. It is meant to be called before crash recovery, while
+// no block of those files is in use.
+func (m *Manager) RemoveUnloggedFiles() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if m.readOnly {
+		return ErrReadOnly
+	}
+
+	names, err := m.backend.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range names {
+		if !IsUnloggedFile(name) {
+			continue
+		}
+		if err := m.remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// remove closes the named file if it is open and deletes it. The caller must hold m.mu.
+func (m *Manager) remove(name string) error {
+	if h, ok := m.openFiles[name]; ok {
+		if err := m.closeFile(name, h); err != nil {
+			return err
+		}
+	}
+	if err := m.backend.Remove(name); err != nil {
+		return fmt.Errorf("cannot remove file %s: %v", name, err)
+	}
+	return nil
+}
+
+// Read reads the specified block into page. Only looking up the open file holds the Manager's lock, so reads and
+// writes of different blocks proceed in parallel.
+func (m *Manager) Read(block *BlockId, page *Page) error {
+	h, err := m.acquire(block.Filename())
 	if err != nil {
 		return fmt.Errorf("cannot read block %s : %v", block.String(), err)
 	}
+	defer m.release(h)
+	f := h.file
 	offset := int64(block.Number()) * int64(m.blockSize)
-	if _, err := f.Seek(offset, io.SeekStart); err != nil {
-		return fmt.Errorf("cannot seek to offset %d : %v", offset, err)
-	}
 
 	buf := page.Contents()
-	n, err := io.ReadFull(f, buf)
+	n, err := f.ReadAt(buf, offset)
 
 	//Handle successful read
-	if err == nil && n == len(buf) {
-		m.blocksRead++
+	if n == len(buf) {
+		m.blocksRead.Add(1)
 		return nil
 	}
 
@@ -85,7 +300,7 @@ func (m *Manager) Read(block *BlockId, page *Page) error {
 	if errors.Is(err, io.EOF) {
 		//File was empty
 		if n == 0 {
-			m.blocksRead++
+			m.blocksRead.Add(1)
 			return nil
 		}
 
@@ -102,20 +317,74 @@ func (m *Manager) Read(block *BlockId, page *Page) error {
 
 }
 
-func (m *Manager) Write(block *BlockId, page *Page) error {
-	m.mu.Lock()
-	defer m.mu.Unlock()
+// ReadMany reads each of blocks into the page at the same index of pages. Runs of consecutive blocks of the same
+// file are read with a single read, which makes reading many blocks, such as while scanning a file or the log, much
+// cheaper than calling Read for each. As with Read, a page is left unchanged if its block lies past the end of the
+// file.
+func (m *Manager) ReadMany(blocks []BlockId, pages []*Page) error {
+	if len(blocks) != len(pages) {
+		return fmt.Errorf("cannot read %d blocks into %d pages", len(blocks), len(pages))
+	}
+	for start := 0; start < len(blocks); {
+		end := start + 1
+		for end < len(blocks) && blocks[end].File == blocks[start].File &&
+			blocks[end].BlockNumber == blocks[end-1].BlockNumber+1 {
+			end++
+		}
+		if err := m.readRun(blocks[start:end], pages[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	return nil
+}
 
-	f, err := m.getFile(block.Filename())
+// readRun reads a run of consecutive blocks of one file into pages with a single read.
+func (m *Manager) readRun(blocks []BlockId, pages []*Page) error {
+	if len(blocks) == 1 {
+		return m.Read(&blocks[0], pages[0])
+	}
+	h, err := m.acquire(blocks[0].Filename())
+	if err != nil {
+		return fmt.Errorf("cannot read block %s : %v", blocks[0].String(), err)
+	}
+	defer m.release(h)
+
+	buf := NewPage(len(blocks) * m.blockSize).Contents()
+	n, err := h.file.ReadAt(buf, int64(blocks[0].Number())*int64(m.blockSize))
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("cannot read data :%v", err)
+	}
+	for i, page := range pages {
+		start := i * m.blockSize
+		switch {
+		case n >= start+m.blockSize:
+			copy(page.Contents(), buf[start:start+m.blockSize])
+		case n > start:
+			return fmt.Errorf("partial read at EOF: expected %d bytes, got %d", m.blockSize, n-start)
+		}
+	}
+	m.blocksRead.Add(int64(len(blocks)))
+	return nil
+}
+
+// Write writes page to the specified block. Like Read, it only holds the Manager's lock to look up the open file.
+func (m *Manager) Write(block *BlockId, page *Page) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if m.doublesWrites(block.Filename()) {
+		return m.writeDoubled([]BlockId{*block}, []*Page{page})
+	}
+	h, err := m.acquire(block.Filename())
 	if err != nil {
 		return fmt.Errorf("cannot write block %s : %v", block.String(), err)
 	}
+	defer m.release(h)
+	f := h.file
 	offset := int64(block.Number()) * int64(m.blockSize)
-	if _, err := f.Seek(offset, io.SeekStart); err != nil {
-		return fmt.Errorf("cannot seek to offset %d: %v", offset, err)
-	}
 	buf := page.Contents()
-	n, err := f.Write(buf)
+	n, err := f.WriteAt(buf, offset)
 	if err != nil {
 		if n != len(buf) {
 			return fmt.Errorf("short write : expected %d bytes, wrote %d, %v", len(buf), n, err)
@@ -124,10 +393,98 @@ func (m *Manager) Write(block *BlockId, page *Page) error {
 	}
 
 	//Ensure the data is flushed to disk.
-	if err := f.Sync(); err != nil {
+	if err := m.sync(f); err != nil {
 		return fmt.Errorf("cannot flush file %s to disk : %v", block.Filename(), err)
 	}
-	m.blocksWritten++
+	m.wrote(h, block.Number()+1)
+	m.blocksWritten.Add(1)
+	return nil
+}
+
+// WriteAll writes each page of pages to the block at the same index of blocks, and then syncs every file written
+// once, if the sync policy requires it. Writing many blocks this way costs one sync per file instead of one per
+// block. Runs of consecutive blocks of the same file are written with a single write.
+func (m *Manager) WriteAll(blocks []BlockId, pages []*Page) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	if len(blocks) != len(pages) {
+		return fmt.Errorf("cannot write %d pages to %d blocks", len(pages), len(blocks))
+	}
+	if !m.doubleWrite {
+		return m.writeAll(blocks, pages)
+	}
+	var doubled, direct []BlockId
+	var doubledPages, directPages []*Page
+	for i := range blocks {
+		if m.doublesWrites(blocks[i].Filename()) {
+			doubled, doubledPages = append(doubled, blocks[i]), append(doubledPages, pages[i])
+		} else {
+			direct, directPages = append(direct, blocks[i]), append(directPages, pages[i])
+		}
+	}
+	if err := m.writeAll(direct, directPages); err != nil {
+		return err
+	}
+	return m.writeDoubled(doubled, doubledPages)
+}
+
+// writeAll is WriteAll without the double-write area.
+func (m *Manager) writeAll(blocks []BlockId, pages []*Page) error {
+	var written []*openFile
+	defer func() {
+		for _, h := range written {
+			m.release(h)
+		}
+	}()
+	files := make(map[string]*openFile)
+	for start := 0; start < len(blocks); {
+		end := start + 1
+		for end < len(blocks) && blocks[end].File == blocks[start].File &&
+			blocks[end].BlockNumber == blocks[end-1].BlockNumber+1 {
+			end++
+		}
+		name := blocks[start].Filename()
+		h, ok := files[name]
+		if !ok {
+			var err error
+			if h, err = m.acquire(name); err != nil {
+				return fmt.Errorf("cannot write block %s : %v", blocks[start].String(), err)
+			}
+			files[name] = h
+			written = append(written, h)
+		}
+		if err := m.writeRun(h, &blocks[start], pages[start:end]); err != nil {
+			return err
+		}
+		start = end
+	}
+	for name, h := range files {
+		if err := m.sync(h.file); err != nil {
+			return fmt.Errorf("cannot flush file %s to disk : %v", name, err)
+		}
+	}
+	return nil
+}
+
+// writeRun writes pages to the file h as consecutive blocks starting at first, with a single write.
+func (m *Manager) writeRun(h *openFile, first *BlockId, pages []*Page) error {
+	buf := pages[0].Contents()
+	if len(pages) > 1 {
+		buf = NewPage(len(pages) * m.blockSize).Contents()
+		for i, page := range pages {
+			copy(buf[i*m.blockSize:], page.Contents())
+		}
+	}
+	n, err := h.file.WriteAt(buf, int64(first.Number())*int64(m.blockSize))
+	if err != nil {
+		if n != len(buf) {
+			return fmt.Errorf("short write : expected %d bytes, wrote %d, %v", len(buf), n, err)
+		}
+		return fmt.Errorf("cannot write data :%v", err)
+	}
+	m.wrote(h, first.Number()+len(pages))
+	m.blocksWritten.Add(int64(len(pages)))
 	return nil
 }
 
@@ -136,7 +493,10 @@ func (m *Manager) Append(filename string) (*BlockId, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
-	newBlockNumber, err := m.Length(filename)
+	if m.readOnly {
+		return &BlockId{}, ErrReadOnly
+	}
+	newBlockNumber, err := m.length(filename)
 	if err != nil {
 		return &BlockId{}, fmt.Errorf("cannot get length of %s :%v", filename, err)
 	}
@@ -145,15 +505,17 @@ func (m *Manager) Append(filename string) (*BlockId, error) {
 	if err != nil {
 		return &BlockId{}, fmt.Errorf("cannot append block %s: %v", block.String(), err)
 	}
+	h := m.openFiles[filename]
+	if h.allocated > 0 {
+		// The block is already on disk, zeroed, in the extent preallocated last time.
+		h.extend(newBlockNumber + 1)
+		return &block, nil
+	}
 
 	offset := int64(block.Number()) * int64(m.blockSize)
 
-	if _, err := f.Seek(offset, io.SeekStart); err != nil {
-		return &BlockId{}, fmt.Errorf("cannot seek to offset %d: %v", offset, err)
-	}
-
-	b := make([]byte, m.blockSize)
-	n, err := f.Write(b)
+	b := make([]byte, m.blockSize*max(m.extentSize, 1))
+	n, err := f.WriteAt(b, offset)
 	if err != nil {
 		return &BlockId{}, fmt.Errorf("cannot write data :%v", err)
 	}
@@ -162,62 +524,338 @@ func (m *Manager) Append(filename string) (*BlockId, error) {
 	}
 
 	//Ensure the data is flushed to disk
-	if err := f.Sync(); err != nil {
+	if err := m.sync(f); err != nil {
 		return &BlockId{}, fmt.Errorf("cannot sync file %s :%v", filename, err)
 	}
-	m.blocksWritten++
+	if m.extentSize > 1 {
+		h.length, h.allocated = newBlockNumber+1, newBlockNumber+m.extentSize
+	}
+	m.blocksWritten.Add(int64(len(b) / m.blockSize))
+	return &block, nil
+}
+
+// AppendPage appends a new block holding the contents of page to the file and returns its BlockId. Unlike Append
+// followed by Write, it writes the block once and does not sync it, whatever the sync policy: the caller must call
+// Sync before relying on the block being on stable storage. This makes loading many blocks cost one sync.
+func (m *Manager) AppendPage(filename string, page *Page) (*BlockId, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	if m.readOnly {
+		return &BlockId{}, ErrReadOnly
+	}
+	newBlockNumber, err := m.length(filename)
+	if err != nil {
+		return &BlockId{}, fmt.Errorf("cannot get length of %s :%v", filename, err)
+	}
+	block := BlockId{File: filename, BlockNumber: newBlockNumber}
+	f, err := m.getFile(filename)
+	if err != nil {
+		return &BlockId{}, fmt.Errorf("cannot append block %s: %v", block.String(), err)
+	}
+	buf := page.Contents()
+	n, err := f.WriteAt(buf, int64(block.Number())*int64(m.blockSize))
+	if err != nil {
+		return &BlockId{}, fmt.Errorf("cannot write data :%v", err)
+	}
+	if n != len(buf) {
+		return &BlockId{}, fmt.Errorf("short write : expected %d bytes, write %d", len(buf), n)
+	}
+	m.openFiles[filename].extend(newBlockNumber + 1)
+	m.blocksWritten.Add(1)
 	return &block, nil
 }
 
-func (m *Manager) getFile(filename string) (*os.File, error) {
-	if f, ok := m.openFiles[filename]; ok {
-		return f, nil
+// Delete removes the named file. It is not an error if the file does not exist. The file must not be in use by a
+// read or write.
+func (m *Manager) Delete(filename string) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.closed {
+		return ErrClosed
+	}
+	if h, ok := m.openFiles[filename]; ok && h.users > 0 {
+		return fmt.Errorf("cannot remove file %s: file is in use", filename)
+	}
+	existing, err := m.backend.List()
+	if err != nil {
+		return fmt.Errorf("cannot remove file %s: %v", filename, err)
+	}
+	if !slices.Contains(existing, filename) {
+		return nil
+	}
+	return m.remove(filename)
+}
+
+// Truncate shortens the named file to its first numBlocks blocks, and syncs it if the sync policy requires it.
+// The file cannot be extended this way.
+func (m *Manager) Truncate(filename string, numBlocks int) error {
+	if m.readOnly {
+		return ErrReadOnly
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	length, err := m.length(filename)
+	if err != nil {
+		return err
+	}
+	if numBlocks < 0 || numBlocks > length {
+		return fmt.Errorf("cannot truncate %s of %d blocks to %d blocks", filename, length, numBlocks)
+	}
+	f, err := m.getFile(filename)
+	if err != nil {
+		return fmt.Errorf("cannot truncate %s: %v", filename, err)
+	}
+	if err := f.Truncate(int64(numBlocks) * int64(m.blockSize)); err != nil {
+		return fmt.Errorf("cannot truncate %s: %v", filename, err)
+	}
+	h := m.openFiles[filename]
+	h.length, h.allocated = 0, 0
+	if err := m.sync(f); err != nil {
+		return fmt.Errorf("cannot sync file %s :%v", filename, err)
+	}
+	return nil
+}
+
+// Sync forces the blocks written to the file to stable storage, if the sync policy requires it.
+func (m *Manager) Sync(filename string) error {
+	if m.readOnly {
+		return nil
+	}
+	h, err := m.acquire(filename)
+	if err != nil {
+		return fmt.Errorf("cannot sync file %s :%v", filename, err)
+	}
+	defer m.release(h)
+	if err := m.sync(h.file); err != nil {
+		return fmt.Errorf("cannot sync file %s :%v", filename, err)
+	}
+	return nil
+}
+
+// openFile is a file held open by the Manager.
+type openFile struct {
+	file File
+	// users counts the reads and writes using the file, which cannot be closed until they are done.
+	users int
+	// elem is the file's entry in the Manager's lru list.
+	elem *list.Element
+	// length is the number of blocks in use and allocated the number of blocks on disk, when Append preallocated
+	// blocks past the end of the file; both are zero otherwise, and the file ends with its last block.
+	length    int
+	allocated int
+}
+
+// extend records that the blocks before end are in use, because they were appended or written. The caller must hold
+// the Manager's lock.
+func (h *openFile) extend(end int) {
+	if h.allocated == 0 || end <= h.length {
+		return
+	}
+	h.length = end
+	if h.length >= h.allocated {
+		h.length, h.allocated = 0, 0
+	}
+}
+
+// wrote records that the blocks of h before end were written, when blocks may be preallocated.
+func (m *Manager) wrote(h *openFile, end int) {
+	if m.extentSize > 1 {
+		m.mu.Lock()
+		h.extend(end)
+		m.mu.Unlock()
+	}
+}
+
+// trim cuts off the blocks preallocated past the end of the file h, before it is closed.
+func (m *Manager) trim(name string, h *openFile) error {
+	if h.allocated == 0 {
+		return nil
+	}
+	if err := h.file.Truncate(int64(h.length) * int64(m.blockSize)); err != nil {
+		return fmt.Errorf("cannot truncate %s: %v", name, err)
+	}
+	h.length, h.allocated = 0, 0
+	return nil
+}
+
+// acquire returns the open file with the given name, opening it if needed, and keeps it open until release is
+// called. Like Read and Write, the caller uses the file without holding the Manager's lock.
+func (m *Manager) acquire(filename string) (*openFile, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, err := m.getFile(filename); err != nil {
+		return nil, err
 	}
+	h := m.openFiles[filename]
+	h.users++
+	return h, nil
+}
 
-	dbTable := filepath.Join(m.dbDirectory, filename)
-	f, err := os.OpenFile(dbTable, os.O_RDWR|os.O_CREATE|os.O_SYNC, 0666)
+// release lets h be closed again once no other caller uses it.
+func (m *Manager) release(h *openFile) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	h.users--
+}
+
+// getFile returns the open file with the given name, opening it if needed. The caller must hold m.mu, and may
+// only use the file while holding it.
+func (m *Manager) getFile(filename string) (File, error) {
+	if m.closed {
+		return nil, ErrClosed
+	}
+	if h, ok := m.openFiles[filename]; ok {
+		m.lru.MoveToFront(h.elem)
+		return h.file, nil
+	}
+
+	if err := m.evict(); err != nil {
+		return nil, err
+	}
+	f, err := m.backend.Open(filename)
 	if err != nil {
-		return nil, fmt.Errorf("cannot open file %s: %v", dbTable, err)
+		return nil, err
 	}
-	m.openFiles[filename] = f
+	m.openFiles[filename] = &openFile{file: f, elem: m.lru.PushFront(filename)}
 	return f, nil
 }
 
-// Length returns the number of blocks in the specified file. This method is not thread-safe.
+// evict closes the least recently used files that are not in use until there is room for one more under the cap
+// on open files. The caller must hold m.mu.
+func (m *Manager) evict() error {
+	if m.maxOpenFiles <= 0 {
+		return nil
+	}
+	for e := m.lru.Back(); e != nil && len(m.openFiles) >= m.maxOpenFiles; {
+		prev := e.Prev()
+		name := e.Value.(string)
+		if h := m.openFiles[name]; h.users == 0 {
+			if err := m.closeFile(name, h); err != nil {
+				return err
+			}
+		}
+		e = prev
+	}
+	return nil
+}
+
+// closeFile closes the open file h with the given name and forgets it. The caller must hold m.mu.
+func (m *Manager) closeFile(name string, h *openFile) error {
+	delete(m.openFiles, name)
+	m.lru.Remove(h.elem)
+	if err := m.trim(name, h); err != nil {
+		return err
+	}
+	if err := h.file.Close(); err != nil {
+		return fmt.Errorf("cannot close %s: %v", name, err)
+	}
+	return nil
+}
+
+// sync flushes f to stable storage if the sync policy requires it.
+func (m *Manager) sync(f File) error {
+	if m.syncPolicy == SyncNever {
+		return nil
+	}
+	return f.Sync()
+}
+
+// Length returns the number of blocks in the specified file.
 func (m *Manager) Length(filename string) (int, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.length(filename)
+}
+
+// length is like Length; the caller must hold m.mu.
+func (m *Manager) length(filename string) (int, error) {
 	f, err := m.getFile(filename)
 	if err != nil {
 		return 0, fmt.Errorf("cannot access %s : %v", filename, err)
 	}
-	fileInfo, err := f.Stat()
+	if h := m.openFiles[filename]; h.allocated > 0 {
+		return h.length, nil
+	}
+	fileSizeInBytes, err := f.Size()
 	if err != nil {
 		return 0, fmt.Errorf("cannot stat %s:%v", filename, err)
 	}
 
-	fileSizeInBytes := fileInfo.Size()
 	return int(fileSizeInBytes / int64(m.blockSize)), nil
 }
 
+// Exists returns true if the named file exists. Unlike Length, it does not create the file.
+func (m *Manager) Exists(filename string) (bool, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if _, ok := m.openFiles[filename]; ok {
+		return true, nil
+	}
+	names, err := m.backend.List()
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(names, filename), nil
+}
+
+// Files returns the names of the files held by the Manager, in sorted order.
+func (m *Manager) Files() ([]string, error) {
+	names, err := m.backend.List()
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// Close closes every file opened by the Manager. Reading or writing blocks afterward fails with ErrClosed.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	var errs []error
+	for name, h := range m.openFiles {
+		if err := m.trim(name, h); err != nil {
+			errs = append(errs, err)
+		}
+		if err := h.file.Close(); err != nil {
+			errs = append(errs, fmt.Errorf("cannot close %s: %v", name, err))
+		}
+	}
+	m.openFiles = make(map[string]*openFile)
+	m.lru.Init()
+	m.closed = true
+	return errors.Join(errs...)
+}
+
 // IsNew returns true if the database directory is newly created.
 func (m *Manager) IsNew() bool {
 	return m.isNew
 }
 
+// ReadOnly returns true if the Manager rejects writes.
+func (m *Manager) ReadOnly() bool {
+	return m.readOnly
+}
+
+// SyncPolicy returns the sync policy used by the Manager.
+func (m *Manager) SyncPolicy() SyncPolicy {
+	return m.syncPolicy
+}
+
 // BlockSize returns the block size used by the FileMgr.
 func (m *Manager) BlockSize() int {
 	return m.blockSize
 }
 
 func (m *Manager) GetBlocksRead() int {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	return m.blocksRead
+	return int(m.blocksRead.Load())
 }
 
 func (m *Manager) GetBlocksWritten() int {
-	m.mu.Lock()
-	defer m.mu.Unlock()
-
-	return m.blocksWritten
+	return int(m.blocksWritten.Load())
 }
@@ -2,6 +2,7 @@ package file
 
 import (
 	"fmt"
+	"mydb/utils"
 	"os"
 	"path/filepath"
 	"sync"
@@ -181,4 +182,370 @@ func TestFileManager(t *testing.T) {
 		wg.Wait()
 	})
 
+	t.Run("TieredBackend", func(t *testing.T) {
+		assert := assert.New(t)
+		hot, cold := NewMemoryBackend(), NewMemoryBackend()
+		backend, err := NewTieredBackend(hot, cold)
+		assert.NoError(err)
+
+		mgr, err := NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		block, err := mgr.Append("cold.db")
+		assert.NoError(err)
+		page := NewPage(blockSize)
+		assert.NoError(page.SetString(0, "rarely read"))
+		assert.NoError(mgr.Write(block, page))
+
+		moved, err := backend.MigrateIdle(0)
+		assert.NoError(err)
+		assert.Empty(moved, "open files must stay hot")
+
+		assert.NoError(mgr.Close())
+		moved, err = backend.MigrateIdle(0)
+		assert.NoError(err)
+		assert.Equal([]string{"cold.db"}, moved)
+		assert.Equal([]string{"cold.db"}, backend.ColdFiles())
+		names, err := hot.List()
+		assert.NoError(err)
+		assert.Empty(names)
+		names, err = backend.List()
+		assert.NoError(err)
+		assert.Equal([]string{"cold.db"}, names)
+
+		mgr, err = NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		defer mgr.Close()
+		readPage := NewPage(blockSize)
+		assert.NoError(mgr.Read(block, readPage))
+		readData, err := readPage.GetString(0)
+		assert.NoError(err)
+		assert.Equal("rarely read", readData)
+		assert.Empty(backend.ColdFiles())
+	})
+
+	t.Run("Operation", func(t *testing.T) {
+		assert := assert.New(t)
+		backend := NewMemoryBackend()
+		mgr, err := NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		_, err = mgr.Append("old.db")
+		assert.NoError(err)
+
+		_, err = mgr.BeginOperation([]string{"old.db"}, nil)
+		assert.ErrorContains(err, "already exists")
+
+		// A crash before Commit rolls the operation back.
+		op, err := mgr.BeginOperation([]string{"new.db"}, []string{"old.db"})
+		assert.NoError(err)
+		_, err = mgr.Append("new.db")
+		assert.NoError(err)
+		_, err = mgr.BeginOperation([]string{"other.db"}, nil)
+		assert.ErrorContains(err, "in progress")
+
+		mgr, err = NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		names, err := mgr.Files()
+		assert.NoError(err)
+		assert.Equal([]string{"old.db"}, names)
+
+		_, err = NewManagerWithBackend(backend, blockSize, WithReadOnly())
+		assert.NoError(err)
+
+		op, err = mgr.BeginOperation([]string{"new.db"}, []string{"old.db"})
+		assert.NoError(err)
+		_, err = mgr.Append("new.db")
+		assert.NoError(err)
+		assert.NoError(op.Commit())
+		assert.Error(op.Abort(), "the operation is finished")
+		names, err = mgr.Files()
+		assert.NoError(err)
+		assert.Equal([]string{"new.db"}, names)
+	})
+
+	t.Run("MaxOpenFiles", func(t *testing.T) {
+		assert := assert.New(t)
+		mgr, err := NewManagerWithBackend(NewMemoryBackend(), blockSize, WithMaxOpenFiles(2))
+		assert.NoError(err)
+
+		page := NewPage(blockSize)
+		for i, name := range []string{"a.db", "b.db", "c.db"} {
+			block, err := mgr.Append(name)
+			assert.NoError(err)
+			page.SetInt(0, i)
+			assert.NoError(mgr.Write(block, page))
+		}
+		assert.Len(mgr.openFiles, 2)
+		assert.NotContains(mgr.openFiles, "a.db", "the least recently used file is closed")
+
+		assert.NoError(mgr.Read(NewBlockId("a.db", 0), page))
+		assert.Equal(0, page.GetInt(0), "a closed file is reopened on access")
+		assert.NotContains(mgr.openFiles, "b.db")
+
+		// Files in use are not closed, even if that exceeds the cap.
+		a, err := mgr.acquire("a.db")
+		assert.NoError(err)
+		c, err := mgr.acquire("c.db")
+		assert.NoError(err)
+		assert.NoError(mgr.Read(NewBlockId("b.db", 0), page))
+		assert.Equal(1, page.GetInt(0))
+		assert.Len(mgr.openFiles, 3)
+		mgr.release(a)
+		mgr.release(c)
+		assert.NoError(mgr.Close())
+	})
+
+	t.Run("DirectIO", func(t *testing.T) {
+		if directIOFlag == 0 {
+			t.Skip("direct I/O is not supported on this platform")
+		}
+		assert := assert.New(t)
+		_, err := NewManager(t.TempDir(), blockSize, WithDirectIO())
+		assert.ErrorContains(err, "multiple of")
+
+		mgr, err := NewManager(t.TempDir(), DirectIOAlignment, WithDirectIO())
+		assert.NoError(err)
+		defer mgr.Close()
+		block, err := mgr.Append("direct.db")
+		if err != nil {
+			t.Skipf("the file system does not support direct I/O: %v", err)
+		}
+		page := NewPage(DirectIOAlignment)
+		assert.NoError(page.SetString(0, "aligned"))
+		assert.NoError(mgr.Write(block, page))
+
+		// A page wrapping unaligned memory goes through an aligned copy.
+		unaligned := NewPageFromBytes(make([]byte, DirectIOAlignment+1)[1:])
+		assert.NoError(mgr.Read(block, unaligned))
+		value, err := unaligned.GetString(0)
+		assert.NoError(err)
+		assert.Equal("aligned", value)
+		assert.NoError(unaligned.SetString(0, "unaligned"))
+		assert.NoError(mgr.Write(block, unaligned))
+		assert.NoError(mgr.Read(block, page))
+		value, err = page.GetString(0)
+		assert.NoError(err)
+		assert.Equal("unaligned", value)
+	})
+
+	t.Run("MmapReads", func(t *testing.T) {
+		if !mmapSupported {
+			t.Skip("memory-mapped reads are not supported on this platform")
+		}
+		assert := assert.New(t)
+		mgr, err := NewManager(t.TempDir(), blockSize, WithMmapReads())
+		assert.NoError(err)
+		defer mgr.Close()
+
+		page := NewPage(blockSize)
+		for i := 0; i < 3; i++ {
+			block, err := mgr.Append("mapped.db")
+			assert.NoError(err)
+			page.SetInt(0, i)
+			assert.NoError(mgr.Write(block, page))
+			// Each read past the mapping extends it to the grown file.
+			assert.NoError(mgr.Read(block, page))
+			assert.Equal(i, page.GetInt(0))
+		}
+
+		block := NewBlockId("mapped.db", 1)
+		page.SetInt(0, 42)
+		assert.NoError(mgr.Write(block, page))
+		assert.NoError(mgr.Read(NewBlockId("mapped.db", 0), page))
+		assert.NoError(mgr.Read(block, page))
+		assert.Equal(42, page.GetInt(0), "writes are visible through the mapping")
+
+		_, err = NewManager(t.TempDir(), blockSize, WithMmapReads(), WithDirectIO())
+		assert.Error(err)
+	})
+
+	t.Run("ReadMany", func(t *testing.T) {
+		assert := assert.New(t)
+		mgr, err := NewManagerWithBackend(NewMemoryBackend(), blockSize)
+		assert.NoError(err)
+		page := NewPage(blockSize)
+		for i := 0; i < 3; i++ {
+			for _, name := range []string{"a.db", "b.db"} {
+				block, err := mgr.Append(name)
+				assert.NoError(err)
+				page.SetInt(0, i)
+				assert.NoError(page.SetString(utils.IntSize, name))
+				assert.NoError(mgr.Write(block, page))
+			}
+		}
+
+		blocks := []BlockId{{"a.db", 0}, {"a.db", 1}, {"a.db", 2}, {"a.db", 3}, {"b.db", 2}, {"b.db", 0}}
+		pages := make([]*Page, len(blocks))
+		for i := range pages {
+			pages[i] = NewPage(blockSize)
+			pages[i].SetInt(0, -1)
+		}
+		before := mgr.GetBlocksRead()
+		assert.NoError(mgr.ReadMany(blocks, pages))
+		assert.Equal(len(blocks), mgr.GetBlocksRead()-before)
+		for i, want := range []int{0, 1, 2, -1, 2, 0} {
+			assert.Equal(want, pages[i].GetInt(0), "block %s", blocks[i].String())
+		}
+		name, err := pages[4].GetString(utils.IntSize)
+		assert.NoError(err)
+		assert.Equal("b.db", name)
+
+		assert.Error(mgr.ReadMany(blocks, pages[:1]))
+	})
+
+	t.Run("DeleteAndTruncate", func(t *testing.T) {
+		assert := assert.New(t)
+		backend := NewMemoryBackend()
+		mgr, err := NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		for i := 0; i < 3; i++ {
+			_, err := mgr.Append("a.db")
+			assert.NoError(err)
+		}
+
+		assert.Error(mgr.Truncate("a.db", 4), "files cannot grow")
+		assert.NoError(mgr.Truncate("a.db", 1))
+		length, err := mgr.Length("a.db")
+		assert.NoError(err)
+		assert.Equal(1, length)
+
+		assert.NoError(mgr.Delete("a.db"))
+		names, err := backend.List()
+		assert.NoError(err)
+		assert.NotContains(names, "a.db")
+		assert.NoError(mgr.Delete("a.db"), "deleting a missing file is not an error")
+	})
+
+	t.Run("WriteAll", func(t *testing.T) {
+		assert := assert.New(t)
+		mgr, err := NewManagerWithBackend(NewMemoryBackend(), blockSize)
+		assert.NoError(err)
+		for _, name := range []string{"a.db", "b.db"} {
+			for i := 0; i < 2; i++ {
+				_, err := mgr.Append(name)
+				assert.NoError(err)
+			}
+		}
+
+		blocks := []BlockId{{"a.db", 0}, {"a.db", 1}, {"b.db", 1}, {"a.db", 3}}
+		pages := make([]*Page, len(blocks))
+		for i := range pages {
+			pages[i] = NewPage(blockSize)
+			pages[i].SetInt(0, 10+i)
+		}
+		before := mgr.GetBlocksWritten()
+		assert.NoError(mgr.WriteAll(blocks, pages))
+		assert.Equal(len(blocks), mgr.GetBlocksWritten()-before)
+
+		page := NewPage(blockSize)
+		for i := range blocks {
+			assert.NoError(mgr.Read(&blocks[i], page))
+			assert.Equal(10+i, page.GetInt(0), "block %s", blocks[i].String())
+		}
+		length, err := mgr.Length("a.db")
+		assert.NoError(err)
+		assert.Equal(4, length, "writing past the end extends the file")
+
+		assert.Error(mgr.WriteAll(blocks, pages[:1]))
+	})
+
+	t.Run("ExtentSize", func(t *testing.T) {
+		assert := assert.New(t)
+		backend := NewMemoryBackend()
+		mgr, err := NewManagerWithBackend(backend, blockSize, WithExtentSize(4))
+		assert.NoError(err)
+
+		before := mgr.GetBlocksWritten()
+		for i := 0; i < 5; i++ {
+			block, err := mgr.Append("a.db")
+			assert.NoError(err)
+			assert.Equal(i, block.Number())
+			length, err := mgr.Length("a.db")
+			assert.NoError(err)
+			assert.Equal(i+1, length, "the preallocated blocks are not part of the file")
+		}
+		assert.Equal(8, mgr.GetBlocksWritten()-before, "two extents were written")
+
+		page := NewPage(blockSize)
+		page.SetInt(0, 42)
+		assert.NoError(mgr.Write(NewBlockId("a.db", 6), page))
+		length, err := mgr.Length("a.db")
+		assert.NoError(err)
+		assert.Equal(7, length, "writing into the extent extends the file")
+		block, err := mgr.AppendPage("a.db", page)
+		assert.NoError(err)
+		assert.Equal(7, block.Number())
+		block, err = mgr.Append("a.db")
+		assert.NoError(err)
+		assert.Equal(8, block.Number(), "the extent is used up, so a new one is preallocated")
+
+		assert.NoError(mgr.Truncate("a.db", 3))
+		length, err = mgr.Length("a.db")
+		assert.NoError(err)
+		assert.Equal(3, length)
+		_, err = mgr.Append("a.db")
+		assert.NoError(err)
+		assert.NoError(mgr.Close())
+
+		f, err := backend.Open("a.db")
+		assert.NoError(err)
+		size, err := f.Size()
+		assert.NoError(err)
+		assert.Equal(int64(4*blockSize), size, "closing cuts off the preallocated blocks")
+		assert.NoError(f.Close())
+	})
+
+	t.Run("DoubleWrite", func(t *testing.T) {
+		assert := assert.New(t)
+		backend := NewMemoryBackend()
+		mgr, err := NewManagerWithBackend(backend, blockSize, WithDoubleWrite())
+		assert.NoError(err)
+		blocks := []BlockId{{"a.db", 0}, {"a.db", 1}, {"temp1", 0}}
+		pages := make([]*Page, len(blocks))
+		for i := range pages {
+			pages[i] = NewPage(blockSize)
+			pages[i].SetInt(0, 10+i)
+		}
+		assert.NoError(mgr.WriteAll(blocks, pages))
+		length, err := mgr.Length(DoubleWriteFile)
+		assert.NoError(err)
+		assert.Equal(0, length, "the area is cleared once the blocks are in place")
+
+		// Leave the first two blocks in the area, as a crash after syncing it would, and tear the second in place.
+		header := NewPage(blockSize)
+		n, err := encodeDoubleWrite(header, blocks[:2], pages[:2])
+		assert.NoError(err)
+		assert.Equal(2, n)
+		assert.NoError(mgr.writeAll([]BlockId{{DoubleWriteFile, 0}, {DoubleWriteFile, 1}, {DoubleWriteFile, 2}},
+			[]*Page{header, pages[0], pages[1]}))
+		torn := NewPage(blockSize)
+		torn.SetInt(0, 99)
+		assert.NoError(mgr.writeAll(blocks[1:2], []*Page{torn}))
+		assert.NoError(mgr.Close())
+
+		_, err = NewManagerWithBackend(backend, blockSize, WithReadOnly())
+		assert.Error(err, "a read-only manager cannot restore the area")
+
+		mgr, err = NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		page := NewPage(blockSize)
+		assert.NoError(mgr.Read(&blocks[1], page))
+		assert.Equal(11, page.GetInt(0), "the torn block is restored")
+		length, err = mgr.Length(DoubleWriteFile)
+		assert.NoError(err)
+		assert.Equal(0, length)
+
+		// An area torn itself is discarded without touching the blocks.
+		header.SetInt(doubleWriteCountPos, 1)
+		assert.NoError(mgr.writeAll([]BlockId{{DoubleWriteFile, 0}}, []*Page{header}))
+		assert.NoError(mgr.Write(&blocks[1], torn))
+		assert.NoError(mgr.Close())
+		mgr, err = NewManagerWithBackend(backend, blockSize)
+		assert.NoError(err)
+		assert.NoError(mgr.Read(&blocks[1], page))
+		assert.Equal(99, page.GetInt(0))
+		length, err = mgr.Length(DoubleWriteFile)
+		assert.NoError(err)
+		assert.Equal(0, length)
+		assert.NoError(mgr.Close())
+	})
 }
@@ -0,0 +1,10 @@
+//go:build !(linux || darwin || freebsd || netbsd || openbsd || dragonfly)
+
+package file
+
+const mmapSupported = false
+
+// mmapFile is not available on this platform; WithMmapReads makes NewManager fail.
+type mmapFile struct {
+	*osFile
+}
@@ -0,0 +1,239 @@
+package file
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"mydb/utils"
+	"slices"
+)
+
+// ManifestFile is the name of the file holding the manifest of the multi-file operation in progress, if any.
+const ManifestFile = "mydb.manifest"
+
+const manifestMagic = 0x4d5944424d414e49 // "MYDBMANI"
+
+// Layout of the blocks of ManifestFile. Block 0 is the manifest proper: the number of created files and their names,
+// then the number of removed files and their names. Block 1 is the commit marker and holds nothing but its magic
+// number. Each block has a checksum covering every byte after it, so a block torn by a crash is recognized.
+const (
+	manifestChecksumPos = 0
+	manifestMagicPos    = 8
+	manifestEntriesPos  = 16
+)
+
+// Operation is a change to several files that is atomic across a crash: either all of the files it creates are kept
+// and all of the files it removes are gone, or nothing changed. See Manager.BeginOperation.
+type Operation struct {
+	m       *Manager
+	created []string
+	removed []string
+	done    bool
+}
+
+// BeginOperation starts an operation that creates the files named in created, none of which may exist yet, and
+// removes those named in removed. It records both lists in ManifestFile before returning; the caller then writes the new files and calls Commit, or
+// Abort to give up. If the process crashes before Commit returns, the next Manager attached to the same storage
+// either rolls the operation back, removing the created files, or, when the crash came after the commit point,
+// rolls it forward, removing the removed files. Only one operation can be in progress at a time.
+func (m *Manager) BeginOperation(created, removed []string) (*Operation, error) {
+	if m.readOnly {
+		return nil, ErrReadOnly
+	}
+	page := NewPage(m.blockSize)
+	if err := encodeManifest(page, created, removed); err != nil {
+		return nil, err
+	}
+
+	m.mu.Lock()
+	if err := m.checkOperation(created); err != nil {
+		m.mu.Unlock()
+		return nil, err
+	}
+	m.operating = true
+	m.mu.Unlock()
+
+	if err := m.writeManifestBlock(0, page); err != nil {
+		m.endOperation(nil)
+		return nil, err
+	}
+	return &Operation{m: m, created: slices.Clone(created), removed: slices.Clone(removed)}, nil
+}
+
+// checkOperation returns an error if an operation creating the named files cannot begin. The caller must hold m.mu.
+func (m *Manager) checkOperation(created []string) error {
+	if m.closed {
+		return ErrClosed
+	}
+	if m.operating {
+		return errors.New("another multi-file operation is in progress")
+	}
+	existing, err := m.backend.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range created {
+		if slices.Contains(existing, name) {
+			return fmt.Errorf("file %s already exists", name)
+		}
+	}
+	return nil
+}
+
+// Commit syncs the created files, writes the commit marker and removes the files the operation replaces. Once the
+// commit marker is on disk the operation takes effect even if removing the old files fails.
+func (o *Operation) Commit() error {
+	if o.done {
+		return errors.New("multi-file operation already finished")
+	}
+	o.done = true
+	for _, name := range o.created {
+		if err := o.m.Sync(name); err != nil {
+			o.m.endOperation(nil)
+			return err
+		}
+	}
+	page := NewPage(o.m.blockSize)
+	page.SetLong(manifestMagicPos, manifestMagic)
+	page.SetLong(manifestChecksumPos, manifestChecksum(page))
+	if err := o.m.writeManifestBlock(1, page); err != nil {
+		o.m.endOperation(nil)
+		return err
+	}
+	return o.m.endOperation(o.removed)
+}
+
+// Abort removes the files created so far and discards the operation.
+func (o *Operation) Abort() error {
+	if o.done {
+		return errors.New("multi-file operation already finished")
+	}
+	o.done = true
+	return o.m.endOperation(o.created)
+}
+
+// writeManifestBlock writes page to the given block of ManifestFile and syncs it.
+func (m *Manager) writeManifestBlock(blockNum int, page *Page) error {
+	if err := m.Write(NewBlockId(ManifestFile, blockNum), page); err != nil {
+		return fmt.Errorf("cannot write manifest: %v", err)
+	}
+	if err := m.Sync(ManifestFile); err != nil {
+		return fmt.Errorf("cannot write manifest: %v", err)
+	}
+	return nil
+}
+
+// endOperation removes the named files and then ManifestFile, and lets the next operation begin. If the manager is
+// closed, the files are left for the next Manager to resolve.
+func (m *Manager) endOperation(names []string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.operating = false
+	if m.closed {
+		return ErrClosed
+	}
+	return m.removeOperationFiles(names)
+}
+
+// removeOperationFiles removes the named files that exist, then ManifestFile. The caller must hold m.mu, unless
+// the Manager is still being attached.
+func (m *Manager) removeOperationFiles(names []string) error {
+	existing, err := m.backend.List()
+	if err != nil {
+		return err
+	}
+	for _, name := range append(slices.Clone(names), ManifestFile) {
+		if !slices.Contains(existing, name) {
+			continue
+		}
+		if err := m.remove(name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// resolveOperation finishes the operation left in progress by a crash, rolling it forward if its commit marker is
+// on disk and back otherwise. A manifest torn by the crash belongs to an operation that had not created anything
+// yet, so it is simply removed.
+func (m *Manager) resolveOperation() error {
+	length, err := m.Length(ManifestFile)
+	if err != nil {
+		return fmt.Errorf("cannot read manifest: %v", err)
+	}
+	page := NewPage(m.blockSize)
+	var created, removed []string
+	if length > 0 {
+		if err := m.Read(NewBlockId(ManifestFile, 0), page); err != nil {
+			return fmt.Errorf("cannot read manifest: %v", err)
+		}
+		if validManifestBlock(page) {
+			if created, removed, err = decodeManifest(page); err != nil {
+				return fmt.Errorf("cannot read manifest: %v", err)
+			}
+		}
+	}
+	committed := false
+	if length > 1 {
+		if err := m.Read(NewBlockId(ManifestFile, 1), page); err != nil {
+			return fmt.Errorf("cannot read manifest: %v", err)
+		}
+		committed = validManifestBlock(page)
+	}
+	if committed {
+		return m.removeOperationFiles(removed)
+	}
+	return m.removeOperationFiles(created)
+}
+
+// encodeManifest writes the lists of created and removed files to page, with the magic number and checksum.
+func encodeManifest(page *Page, created, removed []string) error {
+	pos := manifestEntriesPos
+	for _, names := range [][]string{created, removed} {
+		if pos+utils.IntSize > len(page.Contents()) {
+			return errors.New("too many files for one multi-file operation")
+		}
+		page.SetInt(pos, len(names))
+		pos += utils.IntSize
+		for _, name := range names {
+			if pos+MaxLength(len(name)) > len(page.Contents()) {
+				return errors.New("too many files for one multi-file operation")
+			}
+			if err := page.SetString(pos, name); err != nil {
+				return err
+			}
+			pos += MaxLength(len(name))
+		}
+	}
+	page.SetLong(manifestMagicPos, manifestMagic)
+	page.SetLong(manifestChecksumPos, manifestChecksum(page))
+	return nil
+}
+
+// decodeManifest returns the lists of created and removed files held by page.
+func decodeManifest(page *Page) (created, removed []string, err error) {
+	pos := manifestEntriesPos
+	lists := make([][]string, 2)
+	for i := range lists {
+		count := page.GetInt(pos)
+		pos += utils.IntSize
+		for range count {
+			name, err := page.GetString(pos)
+			if err != nil {
+				return nil, nil, err
+			}
+			lists[i] = append(lists[i], name)
+			pos += MaxLength(len(name))
+		}
+	}
+	return lists[0], lists[1], nil
+}
+
+// validManifestBlock returns true if page holds a block of ManifestFile that was completely written.
+func validManifestBlock(page *Page) bool {
+	return page.GetLong(manifestMagicPos) == manifestMagic && page.GetLong(manifestChecksumPos) == manifestChecksum(page)
+}
+
+func manifestChecksum(page *Page) int64 {
+	return int64(crc32.ChecksumIEEE(page.Contents()[manifestMagicPos:]))
+}
@@ -3,8 +3,9 @@ package file
 import (
 	"encoding/binary"
 	"errors"
+	"fmt"
+	"math"
 	"mydb/utils"
-	"runtime"
 	"time"
 	"unicode/utf8"
 )
@@ -19,6 +20,10 @@ type Page struct {
 
 // NewPage creates a Page with a buffer of the given block size.
 func NewPage(blockSize int) *Page {
+	// Pages that may be read or written with direct I/O are aligned for it.
+	if blockSize > 0 && blockSize%DirectIOAlignment == 0 {
+		return &Page{buffer: AlignedBytes(blockSize)}
+	}
 	return &Page{buffer: make([]byte, blockSize)}
 }
 
@@ -27,22 +32,15 @@ func NewPageFromBytes(bytes []byte) *Page {
 	return &Page{buffer: bytes}
 }
 
-// GetInt retrieves an integer from the buffer at the specified offset.
+// GetInt retrieves an integer from the buffer at the specified offset. Integers are stored in utils.IntSize bytes
+// whatever the host architecture; on a 32-bit host, a value written on a 64-bit one that does not fit an int wraps.
 func (p *Page) GetInt(offset int) int {
-	if runtime.GOARCH == "386" || runtime.GOARCH == "arm" {
-		return int(binary.BigEndian.Uint32(p.buffer[offset:]))
-	}
-	// arm64 (M1/M2 Macs) and amd64 use 64-bit
 	return int(binary.BigEndian.Uint64(p.buffer[offset:]))
 }
 
-// SetInt writes an integer to the buffer at the specified offset.
+// SetInt writes an integer to the buffer at the specified offset, in utils.IntSize bytes.
 func (p *Page) SetInt(offset int, n int) {
-	if runtime.GOARCH == "386" || runtime.GOARCH == "arm" {
-		binary.BigEndian.PutUint32(p.buffer[offset:], uint32(n))
-	} else {
-		binary.BigEndian.PutUint64(p.buffer[offset:], uint64(n))
-	}
+	binary.BigEndian.PutUint64(p.buffer[offset:], uint64(n))
 }
 
 // GetLong retrieves a 64-bit integer from the buffer at the specified offset.
@@ -55,6 +53,16 @@ func (p *Page) SetLong(offset int, n int64) {
 	binary.BigEndian.PutUint64(p.buffer[offset:], uint64(n))
 }
 
+// GetFloat retrieves a float64 from the buffer at the specified offset.
+func (p *Page) GetFloat(offset int) float64 {
+	return math.Float64frombits(binary.BigEndian.Uint64(p.buffer[offset:]))
+}
+
+// SetFloat writes a float64 to the buffer at the specified offset, as its 8-byte IEEE 754 representation.
+func (p *Page) SetFloat(offset int, f float64) {
+	binary.BigEndian.PutUint64(p.buffer[offset:], math.Float64bits(f))
+}
+
 // GetBytes retrieves a byte slice from the buffer starting at the specified offset.
 func (p *Page) GetBytes(offset int) []byte {
 	length := p.GetInt(offset)
@@ -73,6 +81,19 @@ func (p *Page) SetBytes(offset int, b []byte) {
 	copy(p.buffer[start:], b)
 }
 
+// GetRawBytes returns a copy of the length bytes starting at the specified offset. Unlike GetBytes, the range
+// carries no length prefix.
+func (p *Page) GetRawBytes(offset, length int) []byte {
+	b := make([]byte, length)
+	copy(b, p.buffer[offset:offset+length])
+	return b
+}
+
+// SetRawBytes overwrites the bytes starting at the specified offset with b, without a length prefix.
+func (p *Page) SetRawBytes(offset int, b []byte) {
+	copy(p.buffer[offset:offset+len(b)], b)
+}
+
 // GetString retrieves a string from the buffer at the specified offset.
 func (p *Page) GetString(offset int) (string, error) {
 	b := p.GetBytes(offset)
@@ -82,11 +103,16 @@ func (p *Page) GetString(offset int) (string, error) {
 	return string(b), nil
 }
 
-// SetString writes a string to the buffer at the specified offset.
+// SetString writes a string to the buffer at the specified offset. A string that does not fit in the rest of the
+// page is rejected; values larger than a block are stored with tx.Transaction.SetLargeBytes.
 func (p *Page) SetString(offset int, s string) error {
 	if !utf8.ValidString(s) {
 		return errors.New("string contains invalid UTF-8 characters")
 	}
+	if offset < 0 || offset+utils.IntSize+len(s) > len(p.buffer) {
+		return fmt.Errorf("string of %d bytes does not fit at offset %d of a page of %d bytes", len(s), offset,
+			len(p.buffer))
+	}
 	p.SetBytes(offset, []byte(s))
 	return nil
 }
@@ -121,6 +147,68 @@ func (p *Page) SetDate(offset int, date time.Time) {
 	binary.BigEndian.PutUint64(p.buffer[offset:], uint64(date.Unix()))
 }
 
+// TimestampSize is the number of bytes a timestamp occupies in a page.
+const TimestampSize = 12
+
+// GetTimestamp retrieves a timestamp with nanosecond precision from the buffer at the specified offset, in UTC.
+func (p *Page) GetTimestamp(offset int) time.Time {
+	seconds := int64(binary.BigEndian.Uint64(p.buffer[offset:]))
+	nanos := int64(binary.BigEndian.Uint32(p.buffer[offset+8:]))
+	return time.Unix(seconds, nanos).UTC()
+}
+
+// SetTimestamp writes the instant t to the buffer at the specified offset as whole seconds and nanoseconds since
+// the Unix epoch. Unlike SetDate it keeps the nanoseconds. The location of t is not stored.
+func (p *Page) SetTimestamp(offset int, t time.Time) {
+	binary.BigEndian.PutUint64(p.buffer[offset:], uint64(t.Unix()))
+	binary.BigEndian.PutUint32(p.buffer[offset+8:], uint32(t.Nanosecond()))
+}
+
+// GetInterval retrieves an interval from the buffer at the specified offset.
+func (p *Page) GetInterval(offset int) Interval {
+	return Interval{
+		Months:   int32(binary.BigEndian.Uint32(p.buffer[offset:])),
+		Days:     int32(binary.BigEndian.Uint32(p.buffer[offset+4:])),
+		Duration: time.Duration(binary.BigEndian.Uint64(p.buffer[offset+8:])),
+	}
+}
+
+// SetInterval writes an interval to the buffer at the specified offset.
+func (p *Page) SetInterval(offset int, interval Interval) {
+	binary.BigEndian.PutUint32(p.buffer[offset:], uint32(interval.Months))
+	binary.BigEndian.PutUint32(p.buffer[offset+4:], uint32(interval.Days))
+	binary.BigEndian.PutUint64(p.buffer[offset+8:], uint64(interval.Duration))
+}
+
+// GetDecimal retrieves a decimal from the buffer at the specified offset.
+func (p *Page) GetDecimal(offset int) Decimal {
+	return Decimal{
+		Unscaled:  int64(binary.BigEndian.Uint64(p.buffer[offset:])),
+		Precision: p.buffer[offset+8],
+		Scale:     p.buffer[offset+9],
+	}
+}
+
+// SetDecimal writes a decimal to the buffer at the specified offset: its unscaled value, then its precision and
+// scale in a byte each.
+func (p *Page) SetDecimal(offset int, d Decimal) {
+	binary.BigEndian.PutUint64(p.buffer[offset:], uint64(d.Unscaled))
+	p.buffer[offset+8] = d.Precision
+	p.buffer[offset+9] = d.Scale
+}
+
+// GetUUID retrieves a UUID from the buffer at the specified offset.
+func (p *Page) GetUUID(offset int) UUID {
+	var u UUID
+	copy(u[:], p.buffer[offset:offset+UUIDSize])
+	return u
+}
+
+// SetUUID writes the 16 bytes of a UUID to the buffer at the specified offset.
+func (p *Page) SetUUID(offset int, u UUID) {
+	copy(p.buffer[offset:offset+UUIDSize], u[:])
+}
+
 // MaxLength calculates the maximum number of bytes required to store a string of a given length.
 func MaxLength(strlen int) int {
 	return utils.IntSize + strlen*utf8.UTFMax
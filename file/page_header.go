@@ -0,0 +1,112 @@
+package file
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// PageType identifies what a block holds, for blocks that start with a page header. Layers that keep structured
+// blocks, such as records or index nodes, write the header with Page.SetHeader and pin the blocks with their type,
+// so that a block of another kind, a log block say, is rejected when it is read instead of being misread.
+type PageType byte
+
+const (
+	// PageTypeAny accepts every block, with or without a header. It is never written to a header.
+	PageTypeAny PageType = iota
+	PageTypeData
+	PageTypeIndex
+	PageTypeOverflow
+)
+
+func (t PageType) String() string {
+	switch t {
+	case PageTypeAny:
+		return "any"
+	case PageTypeData:
+		return "data"
+	case PageTypeIndex:
+		return "index"
+	case PageTypeOverflow:
+		return "overflow"
+	default:
+		return fmt.Sprintf("PageType(%d)", byte(t))
+	}
+}
+
+// Layout of a page header: a magic number, the version of the header format, the page type and two bytes reserved
+// for later use. The contents of the block follow, from offset PageHeaderSize.
+const (
+	pageMagicPos   = 0
+	pageVersionPos = 4
+	pageTypePos    = 5
+	// PageHeaderSize is the number of bytes taken by a page header.
+	PageHeaderSize = 8
+)
+
+// PageHeaderVersion is the version of the page header format written by SetHeader.
+const PageHeaderVersion = 1
+
+const pageMagic = 0x4d594447 // "MYDG"
+
+// ErrPageHeader is wrapped by every error caused by a page header that is invalid or of the wrong type.
+var ErrPageHeader = errors.New("invalid page header")
+
+// PageHeader returns the bytes of the header of a page of type t.
+func PageHeader(t PageType) []byte {
+	header := make([]byte, PageHeaderSize)
+	binary.BigEndian.PutUint32(header[pageMagicPos:], pageMagic)
+	header[pageVersionPos] = PageHeaderVersion
+	header[pageTypePos] = byte(t)
+	return header
+}
+
+// SetHeader writes the header of a page of type t at the start of the page.
+func (p *Page) SetHeader(t PageType) {
+	copy(p.buffer, PageHeader(t))
+}
+
+// Type returns the type recorded in the page header. A page whose header bytes are all zero, such as a block that
+// was appended but never written, has no type yet, and PageTypeAny is returned.
+func (p *Page) Type() (PageType, error) {
+	header := p.buffer[:PageHeaderSize]
+	if allZero(header) {
+		return PageTypeAny, nil
+	}
+	if magic := binary.BigEndian.Uint32(header[pageMagicPos:]); magic != pageMagic {
+		return PageTypeAny, fmt.Errorf("%w: magic number %#x", ErrPageHeader, magic)
+	}
+	if version := header[pageVersionPos]; version == 0 || version > PageHeaderVersion {
+		return PageTypeAny, fmt.Errorf("%w: unsupported version %d", ErrPageHeader, version)
+	}
+	t := PageType(header[pageTypePos])
+	if t == PageTypeAny || t > PageTypeOverflow {
+		return PageTypeAny, fmt.Errorf("%w: unknown page type %d", ErrPageHeader, byte(t))
+	}
+	return t, nil
+}
+
+// CheckHeader returns an error wrapping ErrPageHeader unless the page has a valid header of type want, or no header
+// at all. Every page passes for PageTypeAny.
+func (p *Page) CheckHeader(want PageType) error {
+	if want == PageTypeAny {
+		return nil
+	}
+	t, err := p.Type()
+	if err != nil {
+		return err
+	}
+	if t != PageTypeAny && t != want {
+		return fmt.Errorf("%w: %s page where a %s page was expected", ErrPageHeader, t, want)
+	}
+	return nil
+}
+
+func allZero(b []byte) bool {
+	for _, c := range b {
+		if c != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -1,7 +1,10 @@
 package file
 
 import (
+	"math"
+	"mydb/utils"
 	"testing"
+	"time"
 	"unicode/utf8"
 
 	"github.com/stretchr/testify/assert"
@@ -32,10 +35,10 @@ func TestPage(t *testing.T) {
 			value  int
 		}{
 			{0, 42},
-			{4, -123},
-			{8, 0},
-			{12, 2147483647},  // max int32
-			{16, -2147483648}, // min int32
+			{utils.IntSize, -123},
+			{2 * utils.IntSize, 0},
+			{3 * utils.IntSize, math.MaxInt64},
+			{4 * utils.IntSize, math.MinInt64},
 		}
 
 		for _, tc := range testCases {
@@ -43,6 +46,11 @@ func TestPage(t *testing.T) {
 			got := page.GetInt(tc.offset)
 			assert.Equal(tc.value, got, "Integer value at offset %d should match", tc.offset)
 		}
+
+		// The on-disk layout is the same on every architecture.
+		page.SetInt(0, -2)
+		assert.Equal([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xfe}, page.Contents()[:utils.IntSize])
+		assert.Equal(-123, page.GetInt(utils.IntSize), "an int takes exactly utils.IntSize bytes")
 	})
 
 	t.Run("BytesOperations", func(t *testing.T) {
@@ -108,16 +116,118 @@ func TestPage(t *testing.T) {
 		assert.Error(err, "GetString should fail for invalid UTF-8 sequence")
 	})
 
+	t.Run("TimestampAndIntervalOperations", func(t *testing.T) {
+		assert := assert.New(t)
+		page := NewPage(100)
+		ts := time.Date(1969, 7, 20, 20, 17, 40, 123456789, time.FixedZone("EDT", -4*60*60))
+		page.SetTimestamp(0, ts)
+		assert.True(ts.Equal(page.GetTimestamp(0)), "nanoseconds before the epoch must survive")
+		assert.Equal(time.UTC, page.GetTimestamp(0).Location())
+
+		interval := Interval{Months: 1, Days: -3, Duration: 90 * time.Minute}
+		page.SetInterval(TimestampSize, interval)
+		assert.Equal(interval, page.GetInterval(TimestampSize))
+		assert.True(ts.Equal(page.GetTimestamp(0)), "the interval must not overlap the timestamp")
+
+		// January 31 plus one month normalizes to March 2, as with time.AddDate.
+		jan31 := time.Date(2024, 1, 31, 0, 0, 0, 0, time.UTC)
+		assert.Equal(time.Date(2024, 2, 28, 1, 30, 0, 0, time.UTC), interval.AddTo(jan31))
+	})
+
+	t.Run("FloatOperations", func(t *testing.T) {
+		assert := assert.New(t)
+		page := NewPage(100)
+		for i, f := range []float64{3.141592653589793, math.Copysign(0, -1), math.Inf(-1), math.SmallestNonzeroFloat64} {
+			page.SetFloat(i*8, f)
+		}
+		assert.Equal(3.141592653589793, page.GetFloat(0))
+		assert.True(math.Signbit(page.GetFloat(8)), "negative zero must keep its sign")
+		assert.True(math.IsInf(page.GetFloat(16), -1))
+		assert.Equal(math.SmallestNonzeroFloat64, page.GetFloat(24))
+
+		page.SetFloat(32, math.NaN())
+		assert.True(math.IsNaN(page.GetFloat(32)))
+	})
+
+	t.Run("DecimalOperations", func(t *testing.T) {
+		assert := assert.New(t)
+		price, err := ParseDecimal("-12.5", 10, 2)
+		assert.NoError(err)
+		assert.Equal(Decimal{Unscaled: -1250, Precision: 10, Scale: 2}, price)
+		assert.Equal("-12.50", price.String())
+		small, err := NewDecimal(7, 3, 3)
+		assert.NoError(err)
+		assert.Equal("0.007", small.String())
+
+		page := NewPage(100)
+		page.SetDecimal(0, price)
+		page.SetDecimal(DecimalSize, small)
+		assert.Equal(price, page.GetDecimal(0))
+		assert.Equal(small, page.GetDecimal(DecimalSize))
+		assert.Equal(Decimal{}, page.GetDecimal(2*DecimalSize), "a zeroed page holds the zero decimal")
+		assert.NoError(Decimal{}.Validate())
+
+		_, err = NewDecimal(1000, 3, 0)
+		assert.Error(err, "the value has more digits than the precision")
+		_, err = NewDecimal(1, 19, 0)
+		assert.Error(err)
+		_, err = NewDecimal(1, 2, 3)
+		assert.Error(err, "the scale exceeds the precision")
+		_, err = ParseDecimal("1.234", 10, 2)
+		assert.Error(err, "more digits after the point than the scale")
+		_, err = ParseDecimal("1.2x", 10, 2)
+		assert.Error(err)
+		_, err = ParseDecimal(".", 10, 2)
+		assert.Error(err)
+	})
+
+	t.Run("UUIDOperations", func(t *testing.T) {
+		assert := assert.New(t)
+		id, err := ParseUUID("123E4567-e89b-12d3-a456-426614174000")
+		assert.NoError(err)
+		assert.Equal("123e4567-e89b-12d3-a456-426614174000", id.String())
+
+		page := NewPage(100)
+		page.SetUUID(4, id)
+		assert.Equal(id, page.GetUUID(4))
+		assert.Equal(0, page.GetInt(20), "the UUID takes exactly 16 bytes")
+
+		for _, text := range []string{"", "123e4567e89b12d3a456426614174000", "123e4567-e89b-12d3-a456-42661417400g"} {
+			_, err := ParseUUID(text)
+			assert.Error(err, "%q is not a UUID", text)
+		}
+	})
+
+	t.Run("Header", func(t *testing.T) {
+		assert := assert.New(t)
+		page := NewPage(100)
+		typ, err := page.Type()
+		assert.NoError(err)
+		assert.Equal(PageTypeAny, typ, "a zeroed page has no header")
+		assert.NoError(page.CheckHeader(PageTypeIndex))
+
+		page.SetHeader(PageTypeData)
+		typ, err = page.Type()
+		assert.NoError(err)
+		assert.Equal(PageTypeData, typ)
+		assert.NoError(page.CheckHeader(PageTypeData))
+		assert.NoError(page.CheckHeader(PageTypeAny))
+		assert.ErrorIs(page.CheckHeader(PageTypeIndex), ErrPageHeader)
+
+		page.SetInt(0, 128) // as a log block starts, with its boundary
+		assert.ErrorIs(page.CheckHeader(PageTypeData), ErrPageHeader)
+	})
+
 	t.Run("MaxLength", func(t *testing.T) {
 		assert := assert.New(t)
 		testCases := []struct {
 			strlen int
 			want   int
 		}{
-			{0, 4},                       // empty string
-			{1, 4 + utf8.UTFMax},         // single character
-			{10, 4 + 10*utf8.UTFMax},     // 10 characters
-			{1000, 4 + 1000*utf8.UTFMax}, // 1000 characters
+			{0, utils.IntSize},                       // empty string
+			{1, utils.IntSize + utf8.UTFMax},         // single character
+			{10, utils.IntSize + 10*utf8.UTFMax},     // 10 characters
+			{1000, utils.IntSize + 1000*utf8.UTFMax}, // 1000 characters
 		}
 
 		for _, tc := range testCases {
@@ -132,10 +242,20 @@ func TestPage(t *testing.T) {
 		page := NewPage(blockSize)
 
 		// Test writing at the end of buffer
-		lastValidOffset := blockSize - 4 // space for one int32
-		page.SetInt(lastValidOffset, 42)
-		got := page.GetInt(lastValidOffset)
-		assert.Equal(int32(42), got, "Value at buffer boundary should match")
+		lastValidOffset := blockSize - utils.IntSize // space for one int
+		page.SetInt(lastValidOffset, math.MinInt64)
+		assert.Equal(math.MinInt64, page.GetInt(lastValidOffset), "Value at buffer boundary should match")
+		assert.Panics(func() { page.SetInt(lastValidOffset+1, 42) }, "an int must fit in the page")
+	})
+
+	t.Run("StringTooLong", func(t *testing.T) {
+		assert := assert.New(t)
+		page := NewPage(20)
+		page.SetInt(12, 42)
+		assert.Error(page.SetString(0, "too long for the page"))
+		assert.Error(page.SetString(-1, ""))
+		assert.Equal(42, page.GetInt(12), "a rejected string leaves the page unchanged")
+		assert.NoError(page.SetString(12, ""))
 	})
 
 	t.Run("LargeData", func(t *testing.T) {
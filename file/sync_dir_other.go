@@ -0,0 +1,16 @@
+//go:build !windows
+
+package file
+
+import "os"
+
+// syncDir commits the entries of the directory dir to stable storage, so that a file created or removed in it
+// stays created or removed after a crash. Syncing the file itself does not guarantee that.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
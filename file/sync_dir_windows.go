@@ -0,0 +1,7 @@
+package file
+
+// syncDir does nothing on Windows, where directories cannot be opened for syncing and NTFS journals the creation
+// and removal of files itself.
+func syncDir(dir string) error {
+	return nil
+}
@@ -0,0 +1,30 @@
+package file
+
+import (
+	"os"
+	"syscall"
+)
+
+// syncFile commits the contents of f to stable storage with fdatasync, which unlike fsync skips metadata such as
+// the modification time that is not needed to read the file back. The size is still synced.
+func syncFile(f *os.File) error {
+	conn, err := f.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var syncErr error
+	if err := conn.Control(func(fd uintptr) {
+		for {
+			syncErr = syscall.Fdatasync(int(fd))
+			if syncErr != syscall.EINTR {
+				return
+			}
+		}
+	}); err != nil {
+		return err
+	}
+	if syncErr != nil {
+		return &os.PathError{Op: "fdatasync", Path: f.Name(), Err: syncErr}
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package file
+
+import "os"
+
+// syncFile commits the contents of f to stable storage. os.File.Sync already does what durability requires on the
+// other platforms: it issues fcntl F_FULLFSYNC on macOS, which plain fsync does not, and FlushFileBuffers on Windows.
+func syncFile(f *os.File) error {
+	return f.Sync()
+}
@@ -0,0 +1,53 @@
+package file
+
+import "fmt"
+
+// SyncPolicy determines when the file manager forces written blocks to stable storage.
+type SyncPolicy int
+
+const (
+	// SyncAlways syncs every write and append before it returns. This is the only policy under which
+	// a committed transaction is guaranteed to survive a power failure.
+	SyncAlways SyncPolicy = iota
+	// SyncNever leaves flushing to the operating system. Data survives a crash of the process,
+	// but not of the machine. Useful for tests and scratch databases.
+	SyncNever
+)
+
+func (p SyncPolicy) String() string {
+	switch p {
+	case SyncAlways:
+		return "always"
+	case SyncNever:
+		return "never"
+	default:
+		return "unknown"
+	}
+}
+
+// ParseSyncPolicy returns the SyncPolicy with the given name.
+func ParseSyncPolicy(name string) (SyncPolicy, error) {
+	switch name {
+	case "always":
+		return SyncAlways, nil
+	case "never":
+		return SyncNever, nil
+	default:
+		return SyncAlways, fmt.Errorf("unknown sync policy %q", name)
+	}
+}
+
+// UnmarshalText lets a SyncPolicy be read from configuration files by name.
+func (p *SyncPolicy) UnmarshalText(text []byte) error {
+	policy, err := ParseSyncPolicy(string(text))
+	if err != nil {
+		return err
+	}
+	*p = policy
+	return nil
+}
+
+// MarshalText writes a SyncPolicy by name.
+func (p SyncPolicy) MarshalText() ([]byte, error) {
+	return []byte(p.String()), nil
+}
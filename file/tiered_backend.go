@@ -0,0 +1,277 @@
+package file
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+// TieredBackend is a Backend that keeps recently used files in a hot backend and moves idle ones, compressed, to a
+// cold backend, such as a directory on cheaper storage or an adapter for an object store. Moving is done by
+// MigrateIdle, which the embedder calls on whatever schedule suits it. A cold file is moved back to the hot backend
+// when it is opened, so the Manager never sees the difference.
+//
+// A file counts as idle once it has not been open for the given duration. Access times are kept in memory, and a
+// file that was in the hot backend when the TieredBackend was created counts as accessed at that time. By default
+// the Manager keeps every file it reads open until it is closed, so only files the database has not touched since it
+// was opened can become cold; with WithMaxOpenFiles, files it closed to stay under the cap can become cold too.
+//
+// Whenever a file exists in both backends, the cold copy is the authoritative one: a file is only removed from the
+// hot backend after its compressed copy is synced, and only removed from the cold backend after it was fully
+// rewritten to the hot one. A crash in between leaves both copies, and the next Open rehydrates again.
+// The TieredBackend is thread-safe.
+type TieredBackend struct {
+	hot     Backend
+	cold    Backend
+	created time.Time
+
+	mu         sync.Mutex
+	coldFiles  map[string]bool
+	open       map[string]int
+	lastAccess map[string]time.Time
+}
+
+// NewTieredBackend returns a TieredBackend that moves files between hot and cold. Files already in cold are
+// treated as cold.
+func NewTieredBackend(hot, cold Backend) (*TieredBackend, error) {
+	names, err := cold.List()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list cold files: %v", err)
+	}
+	b := &TieredBackend{
+		hot:        hot,
+		cold:       cold,
+		created:    time.Now(),
+		coldFiles:  make(map[string]bool, len(names)),
+		open:       make(map[string]int),
+		lastAccess: make(map[string]time.Time),
+	}
+	for _, name := range names {
+		b.coldFiles[name] = true
+	}
+	return b, nil
+}
+
+// Open opens the named file in the hot backend, moving it there first if it is cold.
+func (b *TieredBackend) Open(name string) (File, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.coldFiles[name] {
+		if err := b.rehydrate(name); err != nil {
+			return nil, err
+		}
+	}
+	f, err := b.hot.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	b.open[name]++
+	b.lastAccess[name] = time.Now()
+	return &tieredFile{File: f, backend: b, name: name}, nil
+}
+
+func (b *TieredBackend) Remove(name string) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.coldFiles[name] {
+		if err := b.cold.Remove(name); err != nil {
+			return err
+		}
+		delete(b.coldFiles, name)
+		if !b.inHot(name) {
+			return nil
+		}
+	}
+	delete(b.lastAccess, name)
+	return b.hot.Remove(name)
+}
+
+// List returns the names of the files in both backends.
+func (b *TieredBackend) List() ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names, err := b.hot.List()
+	if err != nil {
+		return nil, err
+	}
+	for name := range b.coldFiles {
+		if !slices.Contains(names, name) {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names, nil
+}
+
+// ColdFiles returns the names of the files currently held in the cold backend.
+func (b *TieredBackend) ColdFiles() []string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names := make([]string, 0, len(b.coldFiles))
+	for name := range b.coldFiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// MigrateIdle moves every file of the hot backend that is not open and has not been accessed for idle to the cold
+// backend. Temporary files stay hot, as does a stale hot copy of a cold file. It returns the names of the files it
+// moved.
+func (b *TieredBackend) MigrateIdle(idle time.Duration) ([]string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	names, err := b.hot.List()
+	if err != nil {
+		return nil, fmt.Errorf("cannot list hot files: %v", err)
+	}
+	sort.Strings(names)
+	var moved []string
+	now := time.Now()
+	for _, name := range names {
+		if IsTempFile(name) || b.open[name] > 0 || b.coldFiles[name] {
+			continue
+		}
+		last, ok := b.lastAccess[name]
+		if !ok {
+			last = b.created
+		}
+		if now.Sub(last) < idle {
+			continue
+		}
+		if err := b.freeze(name); err != nil {
+			return moved, err
+		}
+		moved = append(moved, name)
+	}
+	return moved, nil
+}
+
+// freeze compresses the named hot file into the cold backend and removes it from the hot one.
+func (b *TieredBackend) freeze(name string) error {
+	data, err := readAll(b.hot, name)
+	if err != nil {
+		return err
+	}
+	var compressed bytes.Buffer
+	w := gzip.NewWriter(&compressed)
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("cannot compress file %s: %v", name, err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("cannot compress file %s: %v", name, err)
+	}
+	if err := writeAll(b.cold, name, compressed.Bytes()); err != nil {
+		return err
+	}
+	b.coldFiles[name] = true
+	if err := b.hot.Remove(name); err != nil {
+		return fmt.Errorf("cannot remove hot file %s: %v", name, err)
+	}
+	delete(b.lastAccess, name)
+	return nil
+}
+
+// rehydrate decompresses the named cold file into the hot backend and removes it from the cold one.
+func (b *TieredBackend) rehydrate(name string) error {
+	compressed, err := readAll(b.cold, name)
+	if err != nil {
+		return err
+	}
+	r, err := gzip.NewReader(bytes.NewReader(compressed))
+	if err != nil {
+		return fmt.Errorf("cannot decompress file %s: %v", name, err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return fmt.Errorf("cannot decompress file %s: %v", name, err)
+	}
+	// A hot copy left behind by an interrupted move may be longer than the cold one, so it is replaced.
+	if b.inHot(name) {
+		if err := b.hot.Remove(name); err != nil {
+			return fmt.Errorf("cannot replace hot file %s: %v", name, err)
+		}
+	}
+	if err := writeAll(b.hot, name, data); err != nil {
+		return err
+	}
+	if err := b.cold.Remove(name); err != nil {
+		return fmt.Errorf("cannot remove cold file %s: %v", name, err)
+	}
+	delete(b.coldFiles, name)
+	return nil
+}
+
+func (b *TieredBackend) inHot(name string) bool {
+	names, err := b.hot.List()
+	return err == nil && slices.Contains(names, name)
+}
+
+// closed records that one of the handles of the named file was closed.
+func (b *TieredBackend) closed(name string) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.open[name]--; b.open[name] <= 0 {
+		delete(b.open, name)
+	}
+	b.lastAccess[name] = time.Now()
+}
+
+// tieredFile is a file opened through a TieredBackend. It keeps the file hot until it is closed.
+type tieredFile struct {
+	File
+	backend *TieredBackend
+	name    string
+	once    sync.Once
+}
+
+func (f *tieredFile) Close() error {
+	err := f.File.Close()
+	f.once.Do(func() { f.backend.closed(f.name) })
+	return err
+}
+
+// readAll returns the contents of the named file of backend.
+func readAll(backend Backend, name string) ([]byte, error) {
+	f, err := backend.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	size, err := f.Size()
+	if err != nil {
+		return nil, fmt.Errorf("cannot get size of file %s: %v", name, err)
+	}
+	data := make([]byte, size)
+	if _, err := f.ReadAt(data, 0); err != nil && err != io.EOF {
+		return nil, fmt.Errorf("cannot read file %s: %v", name, err)
+	}
+	return data, nil
+}
+
+// writeAll writes data to the named file of backend and syncs it.
+func writeAll(backend Backend, name string, data []byte) error {
+	f, err := backend.Open(name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if _, err := f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("cannot write file %s: %v", name, err)
+	}
+	if err := f.Sync(); err != nil {
+		return fmt.Errorf("cannot sync file %s: %v", name, err)
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package file
+
+import (
+	"encoding/hex"
+	"fmt"
+)
+
+// UUIDSize is the number of bytes a UUID occupies in a page.
+const UUIDSize = 16
+
+// UUID is a universally unique identifier, stored as its 16 bytes rather than as its 36-character text form.
+type UUID [UUIDSize]byte
+
+// ParseUUID parses the canonical text form of a UUID, such as "123e4567-e89b-12d3-a456-426614174000". Hex digits
+// may be in either case.
+func ParseUUID(s string) (UUID, error) {
+	var u UUID
+	if len(s) != 36 || s[8] != '-' || s[13] != '-' || s[18] != '-' || s[23] != '-' {
+		return u, fmt.Errorf("cannot parse %q as a UUID", s)
+	}
+	digits := s[0:8] + s[9:13] + s[14:18] + s[19:23] + s[24:36]
+	if _, err := hex.Decode(u[:], []byte(digits)); err != nil {
+		return UUID{}, fmt.Errorf("cannot parse %q as a UUID: %v", s, err)
+	}
+	return u, nil
+}
+
+// String returns the canonical text form of the UUID, in lower case.
+func (u UUID) String() string {
+	text := hex.EncodeToString(u[:])
+	return text[0:8] + "-" + text[8:12] + "-" + text[12:16] + "-" + text[16:20] + "-" + text[20:32]
+}
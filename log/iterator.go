@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"mydb/file"
+	"mydb/utils"
 )
 
 // Iterator provides the ability to move through the records of the log files in reverse order
@@ -13,15 +14,23 @@ type Iterator struct {
 	page            *file.Page
 	currentPosition int
 	boundary        int
+	// pages holds the blocks read ahead, the first of them being block first. window is the number of blocks the
+	// last read ahead fetched.
+	pages  []*file.Page
+	first  int
+	window int
 }
 
+// maxReadAhead is the largest number of log blocks an Iterator reads at once. An Iterator starts by reading one
+// block and doubles the count with each read, so a rollback that only needs the last few blocks reads little
+// while a recovery scanning the whole log reads maxReadAhead blocks per read.
+const maxReadAhead = 32
+
 // NewIterator creates an iterator for the records in the log file, positioned after the last log record.
 func NewIterator(fileManager *file.Manager, block *file.BlockId) (*Iterator, error) {
-	page := file.NewPage(fileManager.BlockSize())
 	iterator := &Iterator{
 		fileManager: fileManager,
 		block:       block,
-		page:        page,
 	}
 	if err := iterator.moveToBlock(block); err != nil {
 		return nil, fmt.Errorf("failed to move to block: %v", err)
@@ -39,7 +48,7 @@ func (it *Iterator) HasNext() bool {
 // If there are no more log records in the block, then move to the previous block and return the log record from there.
 // Returns the next earliest log record.
 func (it *Iterator) Next() ([]byte, error) {
-	if it.currentPosition == it.fileManager.BlockSize() {
+	for it.currentPosition == it.fileManager.BlockSize() {
 		if it.block.Number() == 0 {
 			return nil, errors.New("no more log records")
 		}
@@ -49,17 +58,69 @@ func (it *Iterator) Next() ([]byte, error) {
 		}
 
 	}
+	blockSize := it.fileManager.BlockSize()
+	if it.currentPosition+utils.IntSize > blockSize {
+		return nil, fmt.Errorf("corrupt log block %s: record length at offset %d lies past the block end", it.block, it.currentPosition)
+	}
+	length := it.page.GetInt(it.currentPosition)
+	if length < 0 || length > blockSize-it.currentPosition-utils.IntSize {
+		return nil, fmt.Errorf("corrupt log block %s: record at offset %d has length %d", it.block, it.currentPosition, length)
+	}
 	record := it.page.GetBytes(it.currentPosition)
-	it.currentPosition += 4 + len(record) // (size if record) + (length of record)
+	it.currentPosition += utils.IntSize + len(record) // (size of record) + (length of record)
 	return record, nil
 }
 
+// BlockNumber returns the number of the log block holding the most recently returned record.
+func (it *Iterator) BlockNumber() int {
+	return it.block.Number()
+}
+
 func (it *Iterator) moveToBlock(block *file.BlockId) error {
-	if err := it.fileManager.Read(block, it.page); err != nil {
+	if err := it.readAhead(block); err != nil {
 		return fmt.Errorf("failed to read block: %v", err)
 	}
+	it.page = it.pages[block.Number()-it.first]
 
 	it.boundary = int(it.page.GetInt(0))
+	// A block still zero was appended but never written, like the blocks a crash leaves preallocated at the end of
+	// the log: it holds no records.
+	if it.boundary == 0 {
+		it.boundary = it.fileManager.BlockSize()
+	}
+	if it.boundary < utils.IntSize || it.boundary > it.fileManager.BlockSize() {
+		return fmt.Errorf("corrupt log block %s: boundary %d", block, it.boundary)
+	}
 	it.currentPosition = it.boundary
 	return nil
 }
+
+// readAhead makes sure that block is among the pages read ahead, reading it together with the blocks before it.
+func (it *Iterator) readAhead(block *file.BlockId) error {
+	if len(it.pages) > 0 && block.Number() >= it.first && block.Number() < it.first+len(it.pages) {
+		return nil
+	}
+	it.window = min(max(2*it.window, 1), maxReadAhead)
+	first := max(block.Number()-it.window+1, 0)
+	count := block.Number() - first + 1
+	// Pages of earlier reads are reused, including those beyond the length of it.pages.
+	if cap(it.pages) < count {
+		it.pages = append(it.pages[:cap(it.pages)], make([]*file.Page, count-cap(it.pages))...)
+	}
+	it.pages = it.pages[:count]
+	for i, page := range it.pages {
+		if page == nil {
+			it.pages[i] = file.NewPage(it.fileManager.BlockSize())
+		}
+	}
+	blocks := make([]file.BlockId, count)
+	for i := range blocks {
+		blocks[i] = file.BlockId{File: block.Filename(), BlockNumber: first + i}
+	}
+	if err := it.fileManager.ReadMany(blocks, it.pages); err != nil {
+		it.pages = it.pages[:0]
+		return err
+	}
+	it.first = first
+	return nil
+}
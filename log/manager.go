@@ -3,6 +3,7 @@ package log
 import (
 	"fmt"
 	"mydb/file"
+	"mydb/utils"
 	"sync"
 )
 
@@ -33,7 +34,9 @@ func NewManager(fileManager *file.Manager, logFile string) (*Manager, error) {
 	}
 
 	var currentBlock *file.BlockId
-	if logSize == 0 {
+	if logSize == 0 && fileManager.ReadOnly() {
+		return nil, fmt.Errorf("log file %s is empty", logFile)
+	} else if logSize == 0 {
 		//if log file is empty, append a new empty block to it.
 		currentBlock, err = appendNewBlock(fileManager, logFile, logPage)
 		if err != nil {
@@ -46,6 +49,10 @@ func NewManager(fileManager *file.Manager, logFile string) (*Manager, error) {
 		if err := fileManager.Read(currentBlock, logPage); err != nil {
 			return nil, fmt.Errorf("failed to read log page: %v", err)
 		}
+		// A crash between appending the block and writing its boundary leaves a zeroed block: treat it as empty.
+		if logPage.GetInt(0) == 0 {
+			logPage.SetInt(0, fileManager.BlockSize())
+		}
 	}
 	return &Manager{
 		fileManager:  fileManager,
@@ -67,26 +74,76 @@ func (m *Manager) Flush(lsn int) error {
 	return nil
 }
 
+// Close writes the records still buffered in the log page to the log file. The file itself is closed by the file
+// manager.
+func (m *Manager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.flush()
+}
+
+// Size returns the number of blocks in the log file.
+func (m *Manager) Size() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.currentBlock.Number() + 1
+}
+
+// LatestLSN returns the LSN of the most recently appended record. LSNs start over at 0 every time the log is opened.
+func (m *Manager) LatestLSN() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.latestLSN
+}
+
+// FlushedLSN returns the LSN of the most recent record that has been written to the log file.
+func (m *Manager) FlushedLSN() int {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	return m.lastSavedLSN
+}
+
 func (m *Manager) Iterator() (*Iterator, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
 	if err := m.flush(); err != nil {
 		return nil, fmt.Errorf("failed to flush log: %v", err)
 	}
 	return NewIterator(m.fileManager, m.currentBlock)
 }
 
+// Append appends a log record to the log.
 // The beginning of the buffer contains the location of the last-written record (the "boundary").
 // Storing the records backwards makes it easy to read them in reverse order.
 // Returns the LSN of the final value.
 func (m *Manager) Append(logRecord []byte) (int, error) {
+	return m.AppendBatch([][]byte{logRecord})
+}
+
+// AppendBatch appends several log records as a unit and returns the LSN of the last one. The records are placed
+// next to each other in a single log block, starting a new block if the current one cannot hold them all, so they
+// reach the disk in the same block write: after a crash either all of them are in the log or none is.
+// A batch that does not fit in an empty block is rejected.
+func (m *Manager) AppendBatch(logRecords [][]byte) (int, error) {
 	m.mu.Lock()
 	defer m.mu.Unlock()
 
+	if m.fileManager.ReadOnly() {
+		return 0, file.ErrReadOnly
+	}
+
+	bytesNeeded := 0
+	for _, logRecord := range logRecords {
+		bytesNeeded += len(logRecord) + utils.IntSize // room for the integer storing the record size
+	}
+	if available := m.fileManager.BlockSize() - utils.IntSize; bytesNeeded > available {
+		return 0, fmt.Errorf("log records need %d bytes, a log block holds %d", bytesNeeded, available)
+	}
+
 	//Get the current boundary
 	boundary := int(m.logPage.GetInt(0))
-
-	recordSize := len(logRecord)
-	bytesNeeded := recordSize + 4 // 4 bytes for the integer storing the record size
-	if boundary-bytesNeeded < 4 {
+	if boundary-bytesNeeded < utils.IntSize {
 		if err := m.flush(); err != nil {
 			return 0, fmt.Errorf("failed to flush log: %v", err)
 		}
@@ -100,13 +157,15 @@ func (m *Manager) Append(logRecord []byte) (int, error) {
 		boundary = int(m.logPage.GetInt(0))
 	}
 
-	recordPosition := boundary - bytesNeeded
-
-	m.logPage.SetBytes(recordPosition, logRecord)
-
-	m.logPage.SetInt(0, recordPosition)
+	for _, logRecord := range logRecords {
+		recordPosition := boundary - len(logRecord) - utils.IntSize
+		m.logPage.SetBytes(recordPosition, logRecord)
+		boundary = recordPosition
+		m.latestLSN++
+	}
+	// The boundary is set last: the block only ever shows the batch as a whole.
+	m.logPage.SetInt(0, boundary)
 
-	m.latestLSN++
 	return m.latestLSN, nil
 }
 
@@ -123,8 +182,11 @@ func appendNewBlock(fileManager *file.Manager, logFile string, logPage *file.Pag
 	return block, nil
 }
 
-// flush writes the buffer to the log file. This method is not thread-safe.
+// flush writes the buffer to the log file. A read-only log has nothing to write. This method is not thread-safe.
 func (m *Manager) flush() error {
+	if m.fileManager.ReadOnly() {
+		return nil
+	}
 	if err := m.fileManager.Write(m.currentBlock, m.logPage); err != nil {
 		return fmt.Errorf("failed to write log page:%v", err)
 	}
@@ -1,6 +1,7 @@
 package log
 
 import (
+	"bytes"
 	"fmt"
 	"mydb/file"
 	"os"
@@ -65,3 +66,130 @@ func TestLogMgr_AppendAndIteratorConsistency(t *testing.T) {
 
 	assert.Falsef(iterator.HasNext(), "Expected no more records, but iterator has more")
 }
+
+// TestLogMgr_IterateManyBlocks iterates over a log long enough for the read ahead to grow to its largest window.
+func TestLogMgr_IterateManyBlocks(t *testing.T) {
+	assert := assert.New(t)
+	fm, cleanup, err := createTempFileMgr(64)
+	assert.NoError(err)
+	defer cleanup()
+
+	lm, err := NewManager(fm, "testlog")
+	assert.NoError(err)
+
+	recordCount := 500
+	for i := 0; i < recordCount; i++ {
+		_, err := lm.Append([]byte(fmt.Sprintf("record %d", i)))
+		assert.NoError(err)
+	}
+	assert.Greater(lm.Size(), 2*maxReadAhead)
+
+	iterator, err := lm.Iterator()
+	assert.NoError(err)
+	for i := recordCount - 1; i >= 0; i-- {
+		rec, err := iterator.Next()
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal([]byte(fmt.Sprintf("record %d", i)), rec)
+	}
+	assert.False(iterator.HasNext())
+}
+
+func TestLogMgr_PreallocatedBlocks(t *testing.T) {
+	assert := assert.New(t)
+	backend := file.NewMemoryBackend()
+	fm, err := file.NewManagerWithBackend(backend, 64, file.WithExtentSize(4))
+	assert.NoError(err)
+	lm, err := NewManager(fm, "testlog")
+	assert.NoError(err)
+	for i := 0; i < 5; i++ {
+		_, err := lm.Append([]byte(fmt.Sprintf("record %d", i)))
+		assert.NoError(err)
+	}
+	assert.NoError(lm.Flush(lm.LatestLSN()))
+	assert.NotZero(lm.Size()%4, "some of the preallocated blocks are unused")
+
+	// Crash without closing the file manager: the log keeps the blocks preallocated past its end, zeroed.
+	fm, err = file.NewManagerWithBackend(backend, 64, file.WithExtentSize(4))
+	assert.NoError(err)
+	length, err := fm.Length("testlog")
+	assert.NoError(err)
+	assert.Equal(0, length%4, "the log ends with its preallocated extent")
+	lm, err = NewManager(fm, "testlog")
+	assert.NoError(err)
+	for i := 5; i < 20; i++ {
+		_, err := lm.Append([]byte(fmt.Sprintf("record %d", i)))
+		assert.NoError(err)
+	}
+
+	iterator, err := lm.Iterator()
+	assert.NoError(err)
+	for i := 19; i >= 0; i-- {
+		rec, err := iterator.Next()
+		if !assert.NoError(err) {
+			return
+		}
+		assert.Equal([]byte(fmt.Sprintf("record %d", i)), rec)
+	}
+}
+
+func TestLogMgr_AppendBatch(t *testing.T) {
+	assert := assert.New(t)
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 100)
+	assert.NoError(err)
+	lm, err := NewManager(fm, "testlog")
+	assert.NoError(err)
+
+	first := bytes.Repeat([]byte{1}, 40)
+	lsn, err := lm.Append(first)
+	assert.NoError(err)
+	assert.Equal(1, lsn)
+
+	// The batch would fit in the space left only partially, so it moves to a new block as a whole.
+	batch := [][]byte{bytes.Repeat([]byte{2}, 30), bytes.Repeat([]byte{3}, 30)}
+	lsn, err = lm.AppendBatch(batch)
+	assert.NoError(err)
+	assert.Equal(3, lsn)
+	assert.Equal(2, lm.currentBlock.Number()+1, "the batch starts a new block")
+
+	_, err = lm.AppendBatch([][]byte{make([]byte, 50), make([]byte, 50)})
+	assert.ErrorContains(err, "a log block holds")
+
+	iterator, err := lm.Iterator()
+	assert.NoError(err)
+	for _, expected := range [][]byte{batch[1], batch[0], first} {
+		rec, err := iterator.Next()
+		assert.NoError(err)
+		assert.Equal(expected, rec)
+	}
+	assert.False(iterator.HasNext())
+}
+
+func TestLogMgr_CorruptBlock(t *testing.T) {
+	assert := assert.New(t)
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 100)
+	assert.NoError(err)
+	lm, err := NewManager(fm, "testlog")
+	assert.NoError(err)
+	_, err = lm.Append([]byte("record"))
+	assert.NoError(err)
+	assert.NoError(lm.Close())
+
+	block := file.NewBlockId("testlog", 0)
+	page := file.NewPage(100)
+	assert.NoError(fm.Read(block, page))
+	boundary := page.GetInt(0)
+
+	page.SetInt(boundary, 1000)
+	assert.NoError(fm.Write(block, page))
+	iterator, err := NewIterator(fm, block)
+	assert.NoError(err)
+	_, err = iterator.Next()
+	assert.ErrorContains(err, "has length 1000")
+
+	page.SetInt(0, 1000)
+	assert.NoError(fm.Write(block, page))
+	_, err = NewIterator(fm, block)
+	assert.ErrorContains(err, "boundary 1000")
+}
@@ -0,0 +1,60 @@
+package mydb
+
+import (
+	"fmt"
+	"mydb/file"
+)
+
+// migration upgrades a database in place from format version from to from+1.
+// Formats are versioned as a whole: a version bump covers any change to the layout of data pages, log records
+// or the superblock.
+type migration struct {
+	from        int64
+	description string
+	apply       func(fm *file.Manager) error
+}
+
+// migrations lists the upgrades from every past format version, in order.
+// Format version 1 is the first versioned format; databases created before superblocks existed share its layout.
+var migrations = []migration{
+	// Only the superblock changed, and migrate rewrites it after the step.
+	{from: 1, description: "record the block size and int width in the superblock", apply: func(*file.Manager) error {
+		return nil
+	}},
+}
+
+// migrate upgrades the database held by fm from sb.version to target by applying each migration in turn.
+// The superblock is rewritten after every step, so an upgrade interrupted by a crash resumes at the step that
+// failed; each migration must therefore tolerate being applied to a partially migrated database.
+// Old log records can only be read by the version that wrote them, so the database must have been shut down
+// cleanly before it can be upgraded.
+func migrate(fm *file.Manager, sb superblock, target int64, migrations []migration) (superblock, error) {
+	if sb.version < target && !sb.clean {
+		return sb, fmt.Errorf("database format version %d was not shut down cleanly and cannot be upgraded; "+
+			"open and close it with the version that wrote it first", sb.version)
+	}
+
+	for sb.version < target {
+		step, ok := findMigration(migrations, sb.version)
+		if !ok {
+			return sb, fmt.Errorf("no migration from format version %d", sb.version)
+		}
+		if err := step.apply(fm); err != nil {
+			return sb, fmt.Errorf("migration from format version %d (%s) failed: %v", sb.version, step.description, err)
+		}
+		sb.version++
+		if err := writeSuperblock(fm, sb); err != nil {
+			return sb, err
+		}
+	}
+	return sb, nil
+}
+
+func findMigration(migrations []migration, from int64) (migration, bool) {
+	for _, m := range migrations {
+		if m.from == from {
+			return m, true
+		}
+	}
+	return migration{}, false
+}
@@ -0,0 +1,65 @@
+package mydb
+
+import (
+	"errors"
+	"mydb/file"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrate(t *testing.T) {
+	newManager := func(t *testing.T) *file.Manager {
+		fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), DefaultBlockSize)
+		require.NoError(t, err)
+		return fm
+	}
+
+	var applied []int64
+	record := func(from int64) migration {
+		return migration{from: from, description: "test", apply: func(fm *file.Manager) error {
+			applied = append(applied, from)
+			return nil
+		}}
+	}
+
+	t.Run("applies every step in order", func(t *testing.T) {
+		applied = nil
+		fm := newManager(t)
+		sb, err := migrate(fm, superblock{version: 1, epoch: 4, clean: true}, 3, []migration{record(2), record(1)})
+		require.NoError(t, err)
+		assert.Equal(t, []int64{1, 2}, applied)
+		assert.Equal(t, superblock{version: 3, epoch: 4, clean: true}, sb)
+
+		stored, err := readSuperblock(fm)
+		require.NoError(t, err)
+		assert.Equal(t, sb, stored)
+	})
+
+	t.Run("failed step keeps the completed ones", func(t *testing.T) {
+		applied = nil
+		fm := newManager(t)
+		failing := migration{from: 2, description: "broken", apply: func(*file.Manager) error {
+			return errors.New("disk full")
+		}}
+		_, err := migrate(fm, superblock{version: 1, clean: true}, 3, []migration{record(1), failing})
+		assert.ErrorContains(t, err, "migration from format version 2 (broken) failed: disk full")
+
+		stored, err := readSuperblock(fm)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), stored.version)
+	})
+
+	t.Run("missing step", func(t *testing.T) {
+		_, err := migrate(newManager(t), superblock{version: 1, clean: true}, 3, []migration{record(1)})
+		assert.ErrorContains(t, err, "no migration from format version 2")
+	})
+
+	t.Run("unclean database is not upgraded", func(t *testing.T) {
+		applied = nil
+		_, err := migrate(newManager(t), superblock{version: 1}, 2, []migration{record(1)})
+		assert.ErrorContains(t, err, "not shut down cleanly")
+		assert.Empty(t, applied)
+	})
+}
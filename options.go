@@ -0,0 +1,299 @@
+package mydb
+
+import (
+	"bytes"
+	"fmt"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"os"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	DefaultBlockSize           = 400
+	DefaultBufferCount         = 8
+	DefaultTempBufferCount     = 4
+	DefaultLogFile             = "mydb.log"
+	DefaultReplacementStrategy = "naive"
+	DefaultCheckpointWaitTime  = time.Second
+	DefaultTimeZone            = "UTC"
+)
+
+// Options holds every tunable of a database. It can be built in code with DefaultOptions and the With* functions,
+// or loaded from a YAML file with LoadOptions. Zero values are replaced by the defaults when the database is opened.
+type Options struct {
+	// Directory is the folder holding the database files. It is created if it does not exist.
+	Directory string `yaml:"directory"`
+	// BlockSize is the size of a disk block in bytes.
+	BlockSize int `yaml:"block_size"`
+	// BufferCount is the number of buffers in the buffer pool.
+	BufferCount int `yaml:"buffer_count"`
+	// TempBufferCount is the number of buffers in the separate pool for blocks of temporary files.
+	TempBufferCount int `yaml:"temp_buffer_count"`
+	// ReplacementStrategy names the buffer replacement strategy, see buffer.NewStrategy.
+	ReplacementStrategy string `yaml:"replacement_strategy"`
+	// NoSteal keeps changes of unfinished transactions out of the data files until commit, at the cost of pins
+	// waiting while every free buffer is dirty. See buffer.WithNoSteal. Commit always forces changes to disk.
+	NoSteal bool `yaml:"no_steal"`
+	// HugePages asks the operating system to back the buffer pool with huge pages. See buffer.WithHugePages.
+	HugePages bool `yaml:"huge_pages"`
+	// BufferWaitTime is how long pinning a block waits for a free buffer before aborting.
+	BufferWaitTime time.Duration `yaml:"buffer_wait_time"`
+	// LockWaitTime is how long a lock request waits for a conflicting lock before aborting.
+	LockWaitTime time.Duration `yaml:"lock_wait_time"`
+	// LockWaitMinTime, if set, makes the lock wait time adapt to observed contention between LockWaitMinTime and
+	// LockWaitTime. See concurrency.WithAdaptiveWaitTime.
+	LockWaitMinTime time.Duration `yaml:"lock_wait_min_time"`
+	// SlowLockWaitTime is how long a lock request must wait to show up among the slow operations of the debug
+	// handler, see DebugHandler.
+	SlowLockWaitTime time.Duration `yaml:"slow_lock_wait_time"`
+	// SyncPolicy determines when writes are forced to stable storage.
+	SyncPolicy file.SyncPolicy `yaml:"sync_policy"`
+	// MaxOpenFiles caps the number of database files kept open at once; zero means no cap. See
+	// file.WithMaxOpenFiles.
+	MaxOpenFiles int `yaml:"max_open_files"`
+	// ExtentSize is the number of blocks by which appending grows a database file at once; zero or one grows files a
+	// block at a time. See file.WithExtentSize.
+	ExtentSize int `yaml:"extent_size"`
+	// DirectIO opens the database files for direct I/O, bypassing the page cache. See file.WithDirectIO.
+	DirectIO bool `yaml:"direct_io"`
+	// MmapReads serves reads of the database files from memory mappings. See file.WithMmapReads.
+	MmapReads bool `yaml:"mmap_reads"`
+	// DoubleWrite writes every block to a double-write area before writing it in place, so that a block torn by a
+	// crash is restored when the database is opened. See file.WithDoubleWrite.
+	DoubleWrite bool `yaml:"double_write"`
+	// LogFile is the name of the log file inside Directory.
+	LogFile string `yaml:"log_file"`
+	// ReadOnly opens an existing database without ever writing to it: recovery is skipped, files are opened O_RDONLY,
+	// and every change is rejected with file.ErrReadOnly. Changes of transactions that were unfinished when the
+	// files were last written remain visible.
+	ReadOnly bool `yaml:"read_only"`
+	// InMemory keeps the data, log and temporary files in memory and disables durability. Everything is lost when
+	// the database is dropped, but transactions still provide isolation and rollback. Directory is ignored.
+	InMemory bool `yaml:"in_memory"`
+	// CheckpointInterval, if set, checkpoints the log this often, so recovery never has to scan further back.
+	// Checkpoints are quiescent: see CheckpointWaitTime.
+	CheckpointInterval time.Duration `yaml:"checkpoint_interval"`
+	// CheckpointLogSize, if set, checkpoints the log whenever it has grown by this many bytes since the last
+	// checkpoint.
+	CheckpointLogSize int `yaml:"checkpoint_log_size"`
+	// CheckpointWaitTime is how long a checkpoint waits for active transactions to finish, while holding back new
+	// ones from writing. A checkpoint that is still waiting then gives up; the background checkpointer retries
+	// later, backing off while transactions stay active longer than this.
+	CheckpointWaitTime time.Duration `yaml:"checkpoint_wait_time"`
+	// TimeZone is the IANA name of the time zone, such as "Europe/Berlin", in which transactions created by NewTx
+	// return dates and timestamps. See tx.Transaction.SetLocation. The default is UTC.
+	TimeZone string `yaml:"time_zone"`
+	// RecoveryProgress, if set, is called with the progress of recovery when Open has to recover the database.
+	// It can only be set in code.
+	RecoveryProgress func(tx.RecoveryProgress) `yaml:"-"`
+	// Backend, if set, holds the database files instead of Directory. It can only be set in code.
+	Backend file.Backend `yaml:"-"`
+}
+
+// DefaultOptions returns the options used when nothing else is configured. The directory is left empty.
+func DefaultOptions() Options {
+	return Options{
+		BlockSize:           DefaultBlockSize,
+		BufferCount:         DefaultBufferCount,
+		TempBufferCount:     DefaultTempBufferCount,
+		ReplacementStrategy: DefaultReplacementStrategy,
+		BufferWaitTime:      buffer.DefaultMaxWaitTime,
+		LockWaitTime:        concurrency.DefaultMaxWaitTime,
+		SlowLockWaitTime:    concurrency.DefaultSlowWaitThreshold,
+		SyncPolicy:          file.SyncAlways,
+		LogFile:             DefaultLogFile,
+		CheckpointWaitTime:  DefaultCheckpointWaitTime,
+		TimeZone:            DefaultTimeZone,
+	}
+}
+
+// LoadOptions reads options from a YAML file. Settings missing from the file keep their default value;
+// unknown settings are reported as an error.
+func LoadOptions(path string) (Options, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return Options{}, fmt.Errorf("cannot read options file %s: %v", path, err)
+	}
+
+	options := DefaultOptions()
+	decoder := yaml.NewDecoder(bytes.NewReader(contents))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(&options); err != nil {
+		return Options{}, fmt.Errorf("cannot parse options file %s: %v", path, err)
+	}
+	return options, nil
+}
+
+func (o Options) withDefaults() Options {
+	defaults := DefaultOptions()
+	if o.BlockSize <= 0 {
+		o.BlockSize = defaults.BlockSize
+	}
+	if o.BufferCount <= 0 {
+		o.BufferCount = defaults.BufferCount
+	}
+	if o.TempBufferCount <= 0 {
+		o.TempBufferCount = defaults.TempBufferCount
+	}
+	if o.ReplacementStrategy == "" {
+		o.ReplacementStrategy = defaults.ReplacementStrategy
+	}
+	if o.BufferWaitTime <= 0 {
+		o.BufferWaitTime = defaults.BufferWaitTime
+	}
+	if o.LockWaitTime <= 0 {
+		o.LockWaitTime = defaults.LockWaitTime
+	}
+	if o.SlowLockWaitTime <= 0 {
+		o.SlowLockWaitTime = defaults.SlowLockWaitTime
+	}
+	if o.LogFile == "" {
+		o.LogFile = defaults.LogFile
+	}
+	if o.CheckpointWaitTime <= 0 {
+		o.CheckpointWaitTime = defaults.CheckpointWaitTime
+	}
+	if o.TimeZone == "" {
+		o.TimeZone = defaults.TimeZone
+	}
+	if o.InMemory {
+		if o.Backend == nil {
+			o.Backend = file.NewMemoryBackend()
+		}
+		o.SyncPolicy = file.SyncNever
+	}
+	return o
+}
+
+// Option adjusts a single setting of Options.
+type Option func(*Options)
+
+// WithBlockSize sets the size of a disk block in bytes.
+func WithBlockSize(size int) Option {
+	return func(o *Options) { o.BlockSize = size }
+}
+
+// WithBufferCount sets the number of buffers in the buffer pool.
+func WithBufferCount(count int) Option {
+	return func(o *Options) { o.BufferCount = count }
+}
+
+// WithTempBufferCount sets the number of buffers in the pool for temporary files.
+func WithTempBufferCount(count int) Option {
+	return func(o *Options) { o.TempBufferCount = count }
+}
+
+// WithReplacementStrategy selects the buffer replacement strategy by name.
+func WithReplacementStrategy(name string) Option {
+	return func(o *Options) { o.ReplacementStrategy = name }
+}
+
+// WithNoSteal forbids evicting buffers that hold changes of unfinished transactions.
+func WithNoSteal() Option {
+	return func(o *Options) { o.NoSteal = true }
+}
+
+// WithHugePages backs the buffer pool with huge pages where the platform supports it.
+func WithHugePages() Option {
+	return func(o *Options) { o.HugePages = true }
+}
+
+// WithBufferWaitTime sets how long pinning a block waits for a free buffer.
+func WithBufferWaitTime(d time.Duration) Option {
+	return func(o *Options) { o.BufferWaitTime = d }
+}
+
+// WithLockWaitTime sets how long a lock request waits for a conflicting lock.
+func WithLockWaitTime(d time.Duration) Option {
+	return func(o *Options) { o.LockWaitTime = d }
+}
+
+// WithAdaptiveLockWaitTime makes the lock wait time adapt to contention, never dropping below minWait.
+func WithAdaptiveLockWaitTime(minWait time.Duration) Option {
+	return func(o *Options) { o.LockWaitMinTime = minWait }
+}
+
+// WithSlowLockWaitTime sets how long a lock request must wait to be reported as slow.
+func WithSlowLockWaitTime(d time.Duration) Option {
+	return func(o *Options) { o.SlowLockWaitTime = d }
+}
+
+// WithSyncPolicy sets when writes are forced to stable storage.
+func WithSyncPolicy(policy file.SyncPolicy) Option {
+	return func(o *Options) { o.SyncPolicy = policy }
+}
+
+// WithMaxOpenFiles caps the number of database files kept open at once.
+func WithMaxOpenFiles(n int) Option {
+	return func(o *Options) { o.MaxOpenFiles = n }
+}
+
+// WithExtentSize makes appending grow the database files by extents of n blocks.
+func WithExtentSize(n int) Option {
+	return func(o *Options) { o.ExtentSize = n }
+}
+
+// WithDirectIO opens the database files for direct I/O.
+func WithDirectIO() Option {
+	return func(o *Options) { o.DirectIO = true }
+}
+
+// WithMmapReads serves reads of the database files from memory mappings.
+func WithMmapReads() Option {
+	return func(o *Options) { o.MmapReads = true }
+}
+
+// WithDoubleWrite protects the database files against blocks torn by a crash with a double-write area.
+func WithDoubleWrite() Option {
+	return func(o *Options) { o.DoubleWrite = true }
+}
+
+// WithLogFile sets the name of the log file.
+func WithLogFile(name string) Option {
+	return func(o *Options) { o.LogFile = name }
+}
+
+// WithReadOnly opens the database read-only.
+func WithReadOnly() Option {
+	return func(o *Options) { o.ReadOnly = true }
+}
+
+// WithInMemory keeps the whole database in memory, without durability.
+func WithInMemory() Option {
+	return func(o *Options) { o.InMemory = true }
+}
+
+// WithCheckpointInterval checkpoints the log at least every interval.
+func WithCheckpointInterval(interval time.Duration) Option {
+	return func(o *Options) { o.CheckpointInterval = interval }
+}
+
+// WithCheckpointLogSize checkpoints the log whenever it has grown by size bytes.
+func WithCheckpointLogSize(size int) Option {
+	return func(o *Options) { o.CheckpointLogSize = size }
+}
+
+// WithCheckpointWaitTime sets how long a checkpoint waits for active transactions to finish.
+func WithCheckpointWaitTime(d time.Duration) Option {
+	return func(o *Options) { o.CheckpointWaitTime = d }
+}
+
+// WithTimeZone sets the time zone, by IANA name, in which transactions return dates and timestamps.
+func WithTimeZone(name string) Option {
+	return func(o *Options) { o.TimeZone = name }
+}
+
+// WithRecoveryProgress reports the progress of recovery to progress.
+func WithRecoveryProgress(progress func(tx.RecoveryProgress)) Option {
+	return func(o *Options) { o.RecoveryProgress = progress }
+}
+
+// WithBackend keeps the database files in backend instead of a directory.
+func WithBackend(backend file.Backend) Option {
+	return func(o *Options) { o.Backend = backend }
+}
@@ -0,0 +1,110 @@
+package mydb
+
+import (
+	"mydb/file"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadOptions(t *testing.T) {
+	dir := t.TempDir()
+
+	t.Run("overrides defaults", func(t *testing.T) {
+		path := filepath.Join(dir, "mydb.yaml")
+		config := "directory: /var/lib/mydb\nblock_size: 4096\nlock_wait_time: 250ms\nsync_policy: never\n"
+		require.NoError(t, os.WriteFile(path, []byte(config), 0666))
+
+		options, err := LoadOptions(path)
+		require.NoError(t, err)
+
+		expected := DefaultOptions()
+		expected.Directory = "/var/lib/mydb"
+		expected.BlockSize = 4096
+		expected.LockWaitTime = 250 * time.Millisecond
+		expected.SyncPolicy = file.SyncNever
+		assert.Equal(t, expected, options)
+	})
+
+	t.Run("rejects unknown settings", func(t *testing.T) {
+		path := filepath.Join(dir, "typo.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("bufer_count: 10\n"), 0666))
+
+		_, err := LoadOptions(path)
+		assert.ErrorContains(t, err, "bufer_count")
+	})
+
+	t.Run("rejects unknown sync policy", func(t *testing.T) {
+		path := filepath.Join(dir, "sync.yaml")
+		require.NoError(t, os.WriteFile(path, []byte("sync_policy: sometimes\n"), 0666))
+
+		_, err := LoadOptions(path)
+		assert.ErrorContains(t, err, "unknown sync policy")
+	})
+}
+
+func TestOpenWithOptions(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "db")
+	db, err := Open(dir, WithBlockSize(512), WithSyncPolicy(file.SyncNever), WithLockWaitTime(100*time.Millisecond))
+	require.NoError(t, err)
+	defer db.Close()
+
+	assert.Equal(t, 512, db.FileManager().BlockSize())
+	assert.Equal(t, file.SyncNever, db.FileManager().SyncPolicy())
+
+	// A conflicting lock request gives up after the configured wait time rather than the default
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+	writer, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetInt(block, 0, 1, false))
+
+	reader, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, reader.Pin(block))
+	start := time.Now()
+	_, err = reader.GetInt(block, 0)
+	assert.ErrorContains(t, err, "lock abort")
+	assert.Less(t, time.Since(start), 5*time.Second)
+
+	require.NoError(t, reader.Rollback())
+	require.NoError(t, writer.Commit())
+
+	_, err = Open(filepath.Join(t.TempDir(), "db"), WithReplacementStrategy("random"))
+	assert.ErrorContains(t, err, "unknown replacement strategy")
+
+	_, err = Open(filepath.Join(t.TempDir(), "db"), WithTimeZone("Mars/Olympus_Mons"))
+	assert.ErrorContains(t, err, "invalid time zone")
+}
+
+func TestTimeZone(t *testing.T) {
+	db, err := Open("", WithInMemory(), WithTimeZone("Asia/Tokyo"))
+	require.NoError(t, err)
+	defer db.Close()
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+
+	// The value is stored as an instant, whatever its location, and read back in the location of the transaction.
+	value := time.Date(2024, 3, 1, 23, 30, 0, 500, time.FixedZone("UTC-5", -5*60*60))
+	transaction, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, transaction.Pin(block))
+	require.NoError(t, transaction.SetTimestamp(block, 0, value, true))
+	require.NoError(t, transaction.SetDate(block, 16, value, true))
+
+	timestamp, err := transaction.GetTimestamp(block, 0)
+	require.NoError(t, err)
+	assert.True(t, value.Equal(timestamp))
+	assert.Equal(t, "2024-03-02T13:30:00.0000005+09:00", timestamp.Format(time.RFC3339Nano))
+
+	transaction.SetLocation(time.UTC)
+	date, err := transaction.GetDate(block, 16)
+	require.NoError(t, err)
+	assert.Equal(t, "2024-03-02T04:30:00Z", date.Format(time.RFC3339))
+	require.NoError(t, transaction.Commit())
+}
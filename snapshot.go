@@ -0,0 +1,97 @@
+package mydb
+
+import (
+	"errors"
+	"fmt"
+	"mydb/file"
+	"mydb/tx"
+	"slices"
+)
+
+// Snapshot copies the database into directory, which must not hold any files yet. The copy can be opened like
+// any database, for instance to seed a replica or to archive a dataset.
+//
+// Snapshot quiesces the database like Checkpoint: it waits up to Options.CheckpointWaitTime for the transactions
+// that have written to the log to finish, and returns ErrActiveTransactions if they do not. New writers are held
+// back until the copy is complete, so every data block is copied as the last committed transaction left it and no
+// page is torn. The background checkpointer does not run meanwhile.
+// Because no transaction is unfinished, the copy needs no log history: its log holds a single block ending with a
+// checkpoint record. Temporary files are not copied, and neither are writes made without logging while Snapshot
+// runs. If Snapshot fails, the files copied so far are removed; if the process crashes before Snapshot returns, they
+// are removed when the directory is next opened.
+func (db *DB) Snapshot(directory string) (err error) {
+	db.mu.Lock()
+	defer db.mu.Unlock()
+
+	if db.closed {
+		return ErrClosed
+	}
+	target, err := file.NewManager(directory, db.fileManager.BlockSize())
+	if err != nil {
+		return fmt.Errorf("cannot create snapshot directory: %v", err)
+	}
+	defer func() {
+		err = errors.Join(err, target.Close())
+	}()
+	if names, err := target.Files(); err != nil {
+		return fmt.Errorf("cannot read snapshot directory: %v", err)
+	} else if len(names) > 0 {
+		return fmt.Errorf("snapshot directory %s is not empty", directory)
+	}
+
+	resume, err := tx.Quiesce(db.logManager, db.checkpointWait)
+	if err != nil {
+		return err
+	}
+	defer resume()
+
+	lsn, err := tx.WriteCheckpointToLog(db.logManager)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	if err := db.logManager.Flush(lsn); err != nil {
+		return err
+	}
+
+	names, err := db.fileManager.Files()
+	if err != nil {
+		return fmt.Errorf("cannot list database files: %v", err)
+	}
+	names = slices.DeleteFunc(names, func(name string) bool {
+		return file.IsTempFile(name) || name == file.ManifestFile || name == file.DoubleWriteFile
+	})
+	// A crash while copying leaves a manifest behind, and opening the snapshot directory then removes the partial
+	// copy.
+	op, err := target.BeginOperation(names, nil)
+	if err != nil {
+		return fmt.Errorf("cannot write snapshot: %v", err)
+	}
+	defer func() {
+		if err != nil {
+			err = errors.Join(err, op.Abort())
+		} else {
+			err = op.Commit()
+		}
+	}()
+	page := file.NewPage(db.fileManager.BlockSize())
+	for _, name := range names {
+		length, err := db.fileManager.Length(name)
+		if err != nil {
+			return err
+		}
+		first := 0
+		if name == db.logFile {
+			// The checkpoint just written is the newest record of the last log block.
+			first = length - 1
+		}
+		for blockNum := first; blockNum < length; blockNum++ {
+			if err := db.fileManager.Read(file.NewBlockId(name, blockNum), page); err != nil {
+				return err
+			}
+			if err := target.Write(file.NewBlockId(name, blockNum-first), page); err != nil {
+				return fmt.Errorf("cannot write snapshot: %v", err)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package mydb
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSnapshot(t *testing.T) {
+	db, err := Open(filepath.Join(t.TempDir(), "db"), WithCheckpointWaitTime(10*time.Millisecond))
+	require.NoError(t, err)
+	defer db.Close()
+
+	block, err := db.FileManager().Append("testfile")
+	require.NoError(t, err)
+	for i := 1; i <= 30; i++ {
+		transaction, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, transaction.Pin(block))
+		require.NoError(t, transaction.SetInt(block, 0, i, true))
+		require.NoError(t, transaction.Commit())
+	}
+	require.Greater(t, db.LogManager().Size(), 1)
+
+	active, err := db.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, active.Pin(block))
+	require.NoError(t, active.SetInt(block, 0, 99, true))
+	assert.ErrorIs(t, db.Snapshot(filepath.Join(t.TempDir(), "busy")), ErrActiveTransactions)
+	require.NoError(t, active.Rollback())
+
+	dir := filepath.Join(t.TempDir(), "snapshot")
+	require.NoError(t, db.Snapshot(dir))
+	assert.ErrorContains(t, db.Snapshot(dir), "not empty")
+
+	info, err := os.Stat(filepath.Join(dir, DefaultLogFile))
+	require.NoError(t, err)
+	assert.Equal(t, int64(DefaultBlockSize), info.Size(), "the snapshot log is a single block")
+
+	copied, err := Open(dir)
+	require.NoError(t, err)
+	defer copied.Close()
+	reader, err := copied.NewTx()
+	require.NoError(t, err)
+	require.NoError(t, reader.Pin(block))
+	value, err := reader.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 30, value)
+	require.NoError(t, reader.Commit())
+}
@@ -0,0 +1,214 @@
+package mydb
+
+import (
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"mydb/file"
+	"mydb/utils"
+)
+
+const (
+	// FormatVersion is the on-disk format written by this version of the database.
+	// Databases with a newer format are refused. In every version, ints take utils.IntSize bytes on disk on all
+	// architectures; databases written by 32-bit builds from before that was fixed use 4-byte ints and cannot be
+	// read. From version 2 on, the superblock records the block size and int width, which are checked on open.
+	FormatVersion = 2
+	// SuperblockFile is the name of the file holding the superblock inside the database directory.
+	SuperblockFile = "mydb.super"
+)
+
+const superblockMagic = 0x4d5944425355504b // "MYDBSUPK"
+
+// superblockCopies is the number of blocks of SuperblockFile holding a copy of the superblock. Writes alternate
+// between the copies, so a write torn by a crash only damages the older copy and the newer one is still intact.
+const superblockCopies = 2
+
+// Layout of each copy of the superblock. Every field is a fixed-size long, so the layout does not depend on the
+// platform's int size. The checksum covers every byte after it. The sequence number orders the copies: the valid
+// copy with the highest sequence is the current superblock. The block size and int width the database was created
+// with were added in format version 2; superblocks of version 1 end after the clean flag.
+const (
+	superblockChecksumPos  = 0
+	superblockMagicPos     = 8
+	superblockVersionPos   = 16
+	superblockEpochPos     = 24
+	superblockSequencePos  = 32
+	superblockCleanPos     = 40
+	superblockBlockSizePos = 48
+	superblockIntSizePos   = 56
+	superblockSize         = 64
+	superblockSizeV1       = 41
+)
+
+var (
+	errNoSuperblock    = errors.New("superblock missing")
+	errBlankSuperblock = errors.New("superblock copy was never written")
+)
+
+// superblock records facts about the database as a whole that must be known before recovery can run.
+type superblock struct {
+	// version is the format version the database was written with.
+	version int64
+	// epoch counts the times the database has been opened for writing.
+	epoch int64
+	// clean is true if the database was closed with no unfinished transaction, so recovery has nothing to undo.
+	clean bool
+}
+
+// readSuperblock reads the superblock through fm. It returns errNoSuperblock if SuperblockFile is empty, which is
+// the case for databases created before superblocks were introduced, or if no copy of the superblock was ever
+// written. A damaged copy is ignored as long as the other one is valid.
+func readSuperblock(fm *file.Manager) (superblock, error) {
+	sb, _, _, err := readSuperblockCopies(fm)
+	return sb, err
+}
+
+// readSuperblockCopies returns the current superblock together with the block holding it and its sequence number.
+func readSuperblockCopies(fm *file.Manager) (superblock, int, int64, error) {
+	length, err := fm.Length(SuperblockFile)
+	if err != nil {
+		return superblock{}, 0, 0, fmt.Errorf("cannot read superblock: %v", err)
+	}
+
+	current, currentBlock, currentSequence := superblock{}, -1, int64(0)
+	var damaged error
+	page := file.NewPage(fm.BlockSize())
+	// Block 0 is read even if the file seems empty: with a block size larger than the one the database was created
+	// with, the file is shorter than a block, and reading it fails instead of passing for a new database.
+	for blockNum := 0; blockNum < max(min(length, superblockCopies), 1); blockNum++ {
+		if err := fm.Read(file.NewBlockId(SuperblockFile, blockNum), page); err != nil {
+			return superblock{}, 0, 0, fmt.Errorf("cannot read superblock: %v", err)
+		}
+		sb, sequence, err := decodeSuperblock(page)
+		if err == nil {
+			// Every copy records the same block size, so a copy at the right offset is enough to detect a wrong one.
+			if err := checkSuperblockGeometry(page); err != nil {
+				return superblock{}, 0, 0, err
+			}
+		}
+		switch {
+		case errors.Is(err, errBlankSuperblock):
+		case err != nil:
+			if damaged == nil {
+				damaged = fmt.Errorf("superblock copy %d: %v", blockNum, err)
+			}
+		case currentBlock < 0 || sequence > currentSequence:
+			current, currentBlock, currentSequence = sb, blockNum, sequence
+		}
+	}
+
+	switch {
+	case currentBlock >= 0:
+		return current, currentBlock, currentSequence, nil
+	case damaged != nil:
+		return superblock{}, 0, 0, damaged
+	default:
+		return superblock{}, 0, 0, errNoSuperblock
+	}
+}
+
+// writeSuperblock makes sb the current superblock, creating SuperblockFile if needed. It overwrites the copy that
+// is not current, so the current copy survives if the write is torn.
+func writeSuperblock(fm *file.Manager, sb superblock) error {
+	if fm.BlockSize() < superblockSize {
+		return fmt.Errorf("block size %d is too small for the superblock", fm.BlockSize())
+	}
+	_, currentBlock, sequence, err := readSuperblockCopies(fm)
+	switch {
+	case err == nil:
+	case errors.Is(err, errNoSuperblock):
+		currentBlock = -1
+	default:
+		// Neither copy is readable, so either one can be overwritten.
+		currentBlock, sequence = -1, 0
+	}
+
+	length, err := fm.Length(SuperblockFile)
+	if err != nil {
+		return fmt.Errorf("cannot write superblock: %v", err)
+	}
+	for ; length < superblockCopies; length++ {
+		if _, err := fm.Append(SuperblockFile); err != nil {
+			return fmt.Errorf("cannot write superblock: %v", err)
+		}
+	}
+
+	page := file.NewPage(fm.BlockSize())
+	page.SetLong(superblockMagicPos, superblockMagic)
+	page.SetLong(superblockVersionPos, sb.version)
+	page.SetLong(superblockEpochPos, sb.epoch)
+	page.SetLong(superblockSequencePos, sequence+1)
+	page.SetBool(superblockCleanPos, sb.clean)
+	page.SetLong(superblockBlockSizePos, int64(fm.BlockSize()))
+	page.SetLong(superblockIntSizePos, utils.IntSize)
+	page.SetLong(superblockChecksumPos, superblockChecksum(page, superblockSize))
+
+	block := file.NewBlockId(SuperblockFile, (currentBlock+1)%superblockCopies)
+	if err := fm.Write(block, page); err != nil {
+		return fmt.Errorf("cannot write superblock: %v", err)
+	}
+	return nil
+}
+
+// CheckSuperblock returns an error if page holds a damaged copy of the superblock, or one recording a block size
+// other than the size of page. A block of zeros, left by a crash while SuperblockFile was being created, is not
+// damaged: it is a copy that was never written.
+func CheckSuperblock(page *file.Page) error {
+	_, _, err := decodeSuperblock(page)
+	switch {
+	case errors.Is(err, errBlankSuperblock):
+		return nil
+	case err != nil:
+		return err
+	default:
+		return checkSuperblockGeometry(page)
+	}
+}
+
+// checkSuperblockGeometry returns an error if the intact copy of the superblock in page records a block size other
+// than the size of page, or an int width other than utils.IntSize. Every block would then be read at the wrong
+// offset. Copies of format version 1 record neither and pass.
+func checkSuperblockGeometry(page *file.Page) error {
+	if page.GetLong(superblockVersionPos) < 2 {
+		return nil
+	}
+	if blockSize := page.GetLong(superblockBlockSizePos); blockSize != int64(len(page.Contents())) {
+		return fmt.Errorf("database was created with block size %d, not %d", blockSize, len(page.Contents()))
+	}
+	if intSize := page.GetLong(superblockIntSizePos); intSize != utils.IntSize {
+		return fmt.Errorf("database was created with %d-byte ints, not %d-byte ints", intSize, utils.IntSize)
+	}
+	return nil
+}
+
+// decodeSuperblock decodes one copy of the superblock and returns it with its sequence number.
+func decodeSuperblock(page *file.Page) (superblock, int64, error) {
+	if len(page.Contents()) < superblockSize {
+		return superblock{}, 0, fmt.Errorf("block of %d bytes is too small for the superblock", len(page.Contents()))
+	}
+	magic := page.GetLong(superblockMagicPos)
+	if magic == 0 && page.GetLong(superblockChecksumPos) == 0 {
+		return superblock{}, 0, errBlankSuperblock
+	}
+	if magic != superblockMagic {
+		return superblock{}, 0, errors.New("superblock has wrong magic number")
+	}
+	size := superblockSize
+	if page.GetLong(superblockVersionPos) < 2 {
+		size = superblockSizeV1
+	}
+	if page.GetLong(superblockChecksumPos) != superblockChecksum(page, size) {
+		return superblock{}, 0, errors.New("superblock checksum mismatch")
+	}
+	return superblock{
+		version: page.GetLong(superblockVersionPos),
+		epoch:   page.GetLong(superblockEpochPos),
+		clean:   page.GetBool(superblockCleanPos),
+	}, page.GetLong(superblockSequencePos), nil
+}
+
+// superblockChecksum returns the checksum of the first size bytes of the copy in page.
+func superblockChecksum(page *file.Page, size int) int64 {
+	return int64(crc32.ChecksumIEEE(page.Contents()[superblockMagicPos:size]))
+}
@@ -0,0 +1,179 @@
+package mydb
+
+import (
+	"mydb/file"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuperblock(t *testing.T) {
+	t.Run("clean shutdown skips recovery", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(1), db.Epoch())
+		reader, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, reader.Commit())
+		require.NoError(t, db.Close())
+
+		logPath := filepath.Join(dir, DefaultLogFile)
+		before, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+
+		db, err = Open(dir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), db.Epoch())
+		after, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+		assert.Equal(t, before, after, "recovery must not append a checkpoint after a clean shutdown")
+
+		sb, err := readSuperblock(db.FileManager())
+		require.NoError(t, err)
+		assert.Equal(t, superblock{version: FormatVersion, epoch: 2, clean: false}, sb)
+		require.NoError(t, db.Close())
+	})
+
+	t.Run("unfinished transaction makes shutdown unclean", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		block, err := db.FileManager().Append("testfile")
+		require.NoError(t, err)
+		unfinished, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, unfinished.Pin(block))
+		require.NoError(t, unfinished.SetInt(block, 0, 1, true))
+		require.NoError(t, db.Close())
+
+		fm, err := file.NewManager(dir, DefaultBlockSize)
+		require.NoError(t, err)
+		sb, err := readSuperblock(fm)
+		require.NoError(t, err)
+		assert.False(t, sb.clean)
+	})
+
+	t.Run("newer format is refused", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, writeSuperblock(db.FileManager(), superblock{version: FormatVersion + 1, epoch: 1}))
+
+		_, err = Open(dir)
+		assert.ErrorContains(t, err, "newer than supported")
+	})
+
+	t.Run("torn write falls back to the previous copy", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		// Open wrote the unclean superblock to block 0 and Close wrote the clean one to block 1.
+		fm, err := file.NewManager(dir, DefaultBlockSize)
+		require.NoError(t, err)
+		_, current, _, err := readSuperblockCopies(fm)
+		require.NoError(t, err)
+		assert.Equal(t, 1, current)
+		corruptSuperblock(t, fm, 1)
+
+		sb, err := readSuperblock(fm)
+		require.NoError(t, err)
+		assert.Equal(t, superblock{version: FormatVersion, epoch: 1, clean: false}, sb)
+
+		// The next write replaces the damaged copy and keeps the intact one.
+		require.NoError(t, writeSuperblock(fm, superblock{version: FormatVersion, epoch: 1, clean: true}))
+		sb, current, _, err = readSuperblockCopies(fm)
+		require.NoError(t, err)
+		assert.Equal(t, 1, current)
+		assert.True(t, sb.clean)
+		require.NoError(t, fm.Close())
+
+		db, err = Open(dir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(2), db.Epoch())
+		require.NoError(t, db.Close())
+	})
+
+	t.Run("corrupt superblock is refused", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		fm, err := file.NewManager(dir, DefaultBlockSize)
+		require.NoError(t, err)
+		corruptSuperblock(t, fm, 0)
+		corruptSuperblock(t, fm, 1)
+		require.NoError(t, fm.Close())
+
+		_, err = Open(dir)
+		assert.ErrorContains(t, err, "checksum mismatch")
+	})
+
+	t.Run("wrong block size is refused", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		_, err = Open(dir, WithBlockSize(2*DefaultBlockSize))
+		assert.ErrorContains(t, err, "database was created with block size 400, not 800")
+		_, err = Open(dir, WithBlockSize(DefaultBlockSize/2))
+		assert.ErrorContains(t, err, "database was created with block size 400, not 200")
+		_, err = Open(dir, WithBlockSize(4*DefaultBlockSize))
+		assert.ErrorContains(t, err, "cannot read superblock", "the superblock is shorter than a block")
+
+		db, err = Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+	})
+
+	t.Run("version 1 superblock is upgraded", func(t *testing.T) {
+		dir := filepath.Join(t.TempDir(), "db")
+		db, err := Open(dir)
+		require.NoError(t, err)
+		require.NoError(t, db.Close())
+
+		// Replace the superblock with one of format version 1, which ends after the clean flag.
+		fm, err := file.NewManager(dir, DefaultBlockSize)
+		require.NoError(t, err)
+		page := file.NewPage(DefaultBlockSize)
+		page.SetLong(superblockMagicPos, superblockMagic)
+		page.SetLong(superblockVersionPos, 1)
+		page.SetLong(superblockEpochPos, 3)
+		page.SetLong(superblockSequencePos, 1)
+		page.SetBool(superblockCleanPos, true)
+		page.SetLong(superblockChecksumPos, superblockChecksum(page, superblockSizeV1))
+		require.NoError(t, fm.Write(file.NewBlockId(SuperblockFile, 0), page))
+		require.NoError(t, fm.Write(file.NewBlockId(SuperblockFile, 1), file.NewPage(DefaultBlockSize)))
+		sb, err := readSuperblock(fm)
+		require.NoError(t, err)
+		assert.Equal(t, superblock{version: 1, epoch: 3, clean: true}, sb)
+		require.NoError(t, fm.Close())
+
+		db, err = Open(dir)
+		require.NoError(t, err)
+		assert.Equal(t, int64(4), db.Epoch())
+		sb, current, _, err := readSuperblockCopies(db.FileManager())
+		require.NoError(t, err)
+		assert.Equal(t, int64(FormatVersion), sb.version)
+		require.NoError(t, db.FileManager().Read(file.NewBlockId(SuperblockFile, current), page))
+		assert.Equal(t, int64(DefaultBlockSize), page.GetLong(superblockBlockSizePos))
+		require.NoError(t, db.Close())
+	})
+}
+
+// corruptSuperblock changes a field of the superblock copy in blockNum without updating its checksum.
+func corruptSuperblock(t *testing.T, fm *file.Manager, blockNum int) {
+	t.Helper()
+	page := file.NewPage(DefaultBlockSize)
+	block := file.NewBlockId(SuperblockFile, blockNum)
+	require.NoError(t, fm.Read(block, page))
+	page.SetLong(superblockEpochPos, 99)
+	require.NoError(t, fm.Write(block, page))
+}
@@ -38,6 +38,23 @@ func (bl *BufferList) GetBuffer(block *file.BlockId) *buffer.Buffer {
 // Pin pins the block. If the block is already pinned by this transaction,
 // simply increment the reference count. Otherwise, pin it via bufferManager.
 func (bl *BufferList) Pin(block *file.BlockId) error {
+	return bl.PinWithPriority(block, buffer.PriorityData)
+}
+
+// PinWithPriority pins the block like Pin. The priority only takes effect if the transaction has not pinned the
+// block already.
+func (bl *BufferList) PinWithPriority(block *file.BlockId, priority buffer.Priority) error {
+	return bl.pin(block, func() (*buffer.Buffer, error) { return bl.bufferManager.PinWithPriority(block, priority) })
+}
+
+// PinWithType pins the block like Pin, checking its page type as buffer.Manager.PinWithType does if the transaction
+// has not pinned the block already.
+func (bl *BufferList) PinWithType(block *file.BlockId, pageType file.PageType) error {
+	return bl.pin(block, func() (*buffer.Buffer, error) { return bl.bufferManager.PinWithType(block, pageType) })
+}
+
+// pin pins the block with pinBuffer, unless the transaction has pinned it already.
+func (bl *BufferList) pin(block *file.BlockId, pinBuffer func() (*buffer.Buffer, error)) error {
 	if pinnedBuf, ok := bl.buffers[*block]; ok {
 		// Already pinned by this transaction; just increase refCount
 		pinnedBuf.refCount++
@@ -45,7 +62,7 @@ func (bl *BufferList) Pin(block *file.BlockId) error {
 	}
 
 	// Not pinned yet; ask bufferManager for a fresh pin
-	buff, err := bl.bufferManager.Pin(block)
+	buff, err := pinBuffer()
 	if err != nil {
 		return err
 	}
@@ -73,19 +90,11 @@ func (bl *BufferList) Unpin(block *file.BlockId) {
 }
 
 // UnpinAll unpins all blocks pinned by this transaction.
-// We decrement each block's refCount down to zero, unpinning once for each pin.
+// The buffer manager pinned each buffer once, however many times the transaction pinned its block, so each buffer
+// is unpinned once.
 func (bl *BufferList) UnpinAll() {
 	for _, pinnedBuf := range bl.buffers {
-		// We pinned this 'pinnedBuf.refCount' times; unpin that many times
-		for pinnedBuf.refCount > 0 {
-			pinnedBuf.refCount--
-			bl.bufferManager.Unpin(pinnedBuf.buffer)
-		}
-		// Alternatively:
-		//   for i := 0; i < pinnedBuf.refCount; i++ {
-		//       bl.bufferManager.Unpin(pinnedBuf.buffer)
-		//   }
-		// pinnedBuf.refCount = 0
+		bl.bufferManager.Unpin(pinnedBuf.buffer)
 	}
 	// Clear our map
 	bl.buffers = make(map[file.BlockId]*pinnedBuffer)
@@ -0,0 +1,29 @@
+package tx
+
+import (
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBufferListUnpinAll(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 3)
+	block := file.NewBlockId("testfile", 0)
+
+	bl := NewBufferList(bm)
+	require.NoError(t, bl.Pin(block))
+	require.NoError(t, bl.Pin(block))
+	assert.Equal(t, 2, bm.Available())
+
+	bl.UnpinAll()
+	assert.Equal(t, 3, bm.Available(), "a block pinned twice is unpinned once in the buffer manager")
+	assert.Nil(t, bl.GetBuffer(block))
+}
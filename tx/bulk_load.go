@@ -0,0 +1,151 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/tx/concurrency"
+	"mydb/utils"
+)
+
+// BulkLoadRecord records that a transaction started appending blocks to a file directly, without logging their
+// contents. It holds the length of the file before the load, so that undo can discard the loaded blocks.
+type BulkLoadRecord struct {
+	LogRecord
+	txNum    int
+	fileName string
+	blocks   int
+}
+
+func NewBulkLoadRecord(page *file.Page) (*BulkLoadRecord, error) {
+	reader := newRecordReader(page, "BulkLoad")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blocksPos := fileNamePos + file.MaxLength(len(fileName))
+	blocks, err := reader.getNonNegativeInt("blocks", blocksPos)
+	if err != nil {
+		return nil, err
+	}
+
+	return &BulkLoadRecord{txNum: txNum, fileName: fileName, blocks: blocks}, nil
+}
+
+func (r *BulkLoadRecord) Op() LogRecordType {
+	return BulkLoad
+}
+
+func (r *BulkLoadRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *BulkLoadRecord) changedFile() string {
+	return r.fileName
+}
+
+func (r *BulkLoadRecord) String() string {
+	return fmt.Sprintf("<BULKLOAD %d %s %d>", r.txNum, r.fileName, r.blocks)
+}
+
+// Undo zeroes every block of the file past its length before the load. The blocks remain in the file,
+// empty.
+func (r *BulkLoadRecord) Undo(tx *Transaction) error {
+	length, err := tx.fileManager.Length(r.fileName)
+	if err != nil {
+		return err
+	}
+	zeros := make([]byte, tx.fileManager.BlockSize())
+	for n := r.blocks; n < length; n++ {
+		block := file.NewBlockId(r.fileName, n)
+		if err := tx.Pin(block); err != nil {
+			return err
+		}
+		err := tx.SetBytes(block, 0, zeros, false)
+		tx.Unpin(block)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// WriteBulkLoadToLog writes a BulkLoad record for a load into fileName, which holds blocks blocks before the load.
+func WriteBulkLoadToLog(logManager LogAppender, txNum int, fileName string, blocks int) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	blocksPos := fileNamePos + file.MaxLength(len(fileName))
+	recordLen := blocksPos + utils.IntSize
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(BulkLoad))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blocksPos, blocks)
+
+	return logManager.Append(recordBytes)
+}
+
+// BulkLoader appends blocks to a file for a transaction, writing them straight to the file instead of through the
+// buffer pool and the log. See Transaction.BulkLoad.
+type BulkLoader struct {
+	tx       *Transaction
+	filename string
+}
+
+// BulkLoad starts loading blocks into filename. The transaction locks the whole file exclusively and logs a single
+// record holding the current length of the file. The blocks added with the returned BulkLoader are then not logged
+// at all: Commit forces them to disk before the commit record, and undo, by Rollback or by recovery, zeroes them.
+// This makes filling a new or append-only file much cheaper than setting its values one by one.
+// Blocks loaded this way must not be read or changed by the transaction before it commits.
+func (tx *Transaction) BulkLoad(filename string) (*BulkLoader, error) {
+	if tx.fileManager.ReadOnly() {
+		return nil, file.ErrReadOnly
+	}
+	if err := tx.LockFile(filename, concurrency.Exclusive); err != nil {
+		return nil, err
+	}
+	if !tx.bulkFiles[filename] {
+		length, err := tx.fileManager.Length(filename)
+		if err != nil {
+			return nil, err
+		}
+		if !file.IsTempFile(filename) {
+			if err := tx.recoveryManager.BulkLoad(filename, length); err != nil {
+				return nil, err
+			}
+		}
+		if tx.bulkFiles == nil {
+			tx.bulkFiles = make(map[string]bool)
+		}
+		tx.bulkFiles[filename] = true
+	}
+	return &BulkLoader{tx: tx, filename: filename}, nil
+}
+
+// Append appends a block holding the contents of page to the file and returns it. The page must be one block long.
+func (l *BulkLoader) Append(page *file.Page) (*file.BlockId, error) {
+	if err := l.tx.cancelled(); err != nil {
+		return nil, err
+	}
+	if l.tx.finished.Load() {
+		return nil, ErrTxDone
+	}
+	if size := len(page.Contents()); size != l.tx.fileManager.BlockSize() {
+		return nil, fmt.Errorf("page of %d bytes does not fit a block of %d bytes", size, l.tx.fileManager.BlockSize())
+	}
+	return l.tx.fileManager.AppendPage(l.filename, page)
+}
@@ -2,7 +2,7 @@ package tx
 
 import (
 	"mydb/file"
-	"mydb/log"
+	"mydb/utils"
 )
 
 type CheckpointRecord struct {
@@ -37,8 +37,8 @@ func (r *CheckpointRecord) String() string {
 // WriteCheckpointToLog writes a checkpoint record to the log. This log record contains the Checkpoint operator and
 // nothing else.
 // The method returns the LSN of the new log record.
-func WriteCheckpointToLog(logManager *log.Manager) (int, error) {
-	record := make([]byte, 4)
+func WriteCheckpointToLog(logManager LogAppender) (int, error) {
+	record := make([]byte, utils.IntSize)
 
 	page := file.NewPageFromBytes(record)
 	page.SetInt(0, int(Checkpoint))
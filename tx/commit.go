@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -15,9 +14,12 @@ type CommitRecord struct {
 func NewCommitRecord(page *file.Page) (*CommitRecord, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := newRecordReader(page, "Commit").getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 
-	return &CommitRecord{txNum: int(txNum)}, nil
+	return &CommitRecord{txNum: txNum}, nil
 }
 
 // Op returns the type of the log record.
@@ -42,7 +44,7 @@ func (r *CommitRecord) String() string {
 // WriteCommitToLog writes a commit record to the log. This log record contains the Commit operator,
 // followed by the transaction id.
 // The method returns the LSN of the new log record.
-func WriteCommitToLog(logManager *log.Manager, txNum int) (int, error) {
+func WriteCommitToLog(logManager LogAppender, txNum int) (int, error) {
 	record := make([]byte, 2*utils.IntSize)
 
 	page := file.NewPageFromBytes(record)
@@ -0,0 +1,139 @@
+package concurrency
+
+import (
+	"mydb/file"
+	"sort"
+	"time"
+)
+
+// DefaultSlowWaitThreshold is how long a lock request must wait to be recorded as slow unless configured otherwise.
+const DefaultSlowWaitThreshold = 100 * time.Millisecond
+
+// slowWaitLimit is the number of slow waits a LockTable remembers.
+const slowWaitLimit = 32
+
+// WithSlowWaitThreshold sets how long a lock request must wait to be recorded as slow, see SlowWaits.
+func WithSlowWaitThreshold(d time.Duration) Option {
+	return func(lt *LockTable) {
+		lt.slowWait = d
+	}
+}
+
+// pendingRequest is a lock request that is waiting for conflicting locks to be released.
+type pendingRequest struct {
+	block file.BlockId
+	mode  LockMode
+	since time.Time
+}
+
+// Holder is a transaction holding a lock.
+type Holder struct {
+	TxNum int
+	Mode  LockMode
+}
+
+// Wait describes a transaction waiting for a lock: which block it wants and in which mode, since when, and which
+// transactions hold the block. Together the waits form the wait-for graph of the lock table.
+type Wait struct {
+	TxNum   int
+	Block   file.BlockId
+	Mode    LockMode
+	Since   time.Time
+	Waited  time.Duration
+	Holders []Holder
+}
+
+// Waits returns a snapshot of every lock request currently waiting, ordered by transaction number.
+// Holders are ordered by transaction number as well.
+func (lt *LockTable) Waits() []Wait {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	now := time.Now()
+	waits := make([]Wait, 0, len(lt.waiting))
+	for txNum, request := range lt.waiting {
+		holders := make([]Holder, 0, len(lt.locks[request.block]))
+		for owner, mode := range lt.locks[request.block] {
+			if owner != txNum {
+				holders = append(holders, Holder{TxNum: owner, Mode: mode})
+			}
+		}
+		sort.Slice(holders, func(i, j int) bool { return holders[i].TxNum < holders[j].TxNum })
+		waits = append(waits, Wait{
+			TxNum:   txNum,
+			Block:   request.block,
+			Mode:    request.mode,
+			Since:   request.since,
+			Waited:  now.Sub(request.since),
+			Holders: holders,
+		})
+	}
+	sort.Slice(waits, func(i, j int) bool { return waits[i].TxNum < waits[j].TxNum })
+	return waits
+}
+
+// Lock describes a locked block and the transactions holding it.
+type Lock struct {
+	Block   file.BlockId
+	Holders []Holder
+}
+
+// Locks returns a snapshot of every locked block, ordered by block. Holders are ordered by transaction number.
+func (lt *LockTable) Locks() []Lock {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+
+	locks := make([]Lock, 0, len(lt.locks))
+	for block, owners := range lt.locks {
+		holders := make([]Holder, 0, len(owners))
+		for owner, mode := range owners {
+			holders = append(holders, Holder{TxNum: owner, Mode: mode})
+		}
+		sort.Slice(holders, func(i, j int) bool { return holders[i].TxNum < holders[j].TxNum })
+		locks = append(locks, Lock{Block: block, Holders: holders})
+	}
+	sort.Slice(locks, func(i, j int) bool {
+		if locks[i].Block.File != locks[j].Block.File {
+			return locks[i].Block.File < locks[j].Block.File
+		}
+		return locks[i].Block.BlockNumber < locks[j].Block.BlockNumber
+	})
+	return locks
+}
+
+// SlowWait describes a lock request that waited at least the slow wait threshold before it was granted or gave up.
+type SlowWait struct {
+	TxNum   int
+	Block   file.BlockId
+	Mode    LockMode
+	Since   time.Time
+	Waited  time.Duration
+	Granted bool
+}
+
+// SlowWaits returns the most recent slow lock requests, oldest first.
+func (lt *LockTable) SlowWaits() []SlowWait {
+	lt.mu.Lock()
+	defer lt.mu.Unlock()
+	return append([]SlowWait(nil), lt.slowWaits...)
+}
+
+// recordSlowWait remembers a finished wait if it was slow, forgetting the oldest one beyond slowWaitLimit. The caller
+// must hold the table's lock.
+func (lt *LockTable) recordSlowWait(txNum int, block *file.BlockId, mode LockMode, since time.Time, waited time.Duration,
+	err error) {
+	if waited < lt.slowWait {
+		return
+	}
+	if len(lt.slowWaits) == slowWaitLimit {
+		lt.slowWaits = append(lt.slowWaits[:0], lt.slowWaits[1:]...)
+	}
+	lt.slowWaits = append(lt.slowWaits, SlowWait{
+		TxNum:   txNum,
+		Block:   *block,
+		Mode:    mode,
+		Since:   since,
+		Waited:  waited,
+		Granted: err == nil,
+	})
+}
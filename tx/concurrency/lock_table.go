@@ -9,79 +9,177 @@ import (
 	"time"
 )
 
-const maxWaitTime = 10 * time.Second
+// DefaultMaxWaitTime is how long a lock request waits before aborting unless configured otherwise.
+const DefaultMaxWaitTime = 10 * time.Second
+
+// ErrNotLockOwner is returned when a transaction releases a lock it does not hold.
+var ErrNotLockOwner = errors.New("transaction does not hold the lock")
+
+// LockMode is the mode in which a transaction holds a lock.
+type LockMode int
+
+const (
+	Shared LockMode = iota + 1
+	Exclusive
+	// IntentionShared is held on a file by a transaction holding shared locks on some of its blocks.
+	IntentionShared
+	// IntentionExclusive is held on a file by a transaction holding exclusive locks on some of its blocks.
+	IntentionExclusive
+)
+
+var modeNames = map[LockMode]string{
+	Shared:             "shared",
+	Exclusive:          "exclusive",
+	IntentionShared:    "intention shared",
+	IntentionExclusive: "intention exclusive",
+}
+
+func (m LockMode) String() string {
+	switch m {
+	case Shared:
+		return "S"
+	case Exclusive:
+		return "X"
+	case IntentionShared:
+		return "IS"
+	case IntentionExclusive:
+		return "IX"
+	default:
+		return fmt.Sprintf("LockMode(%d)", int(m))
+	}
+}
 
 // LockTable provides methods to lock and Unlock blocks.
+// For every locked block, the table records which transactions hold a lock on it and in which mode, so that a
+// transaction locking the same block twice holds a single lock, and a transaction can only release its own locks.
 // If a transaction requests a lock that causes a conflict with an existing lock,
 // then that transaction is placed on a wait list.
 // There is only one wait list for all blocks.
-// When the last lock on a block is unlocked,
-// then all transactions are removed from the wait list and rescheduled.
+// Whenever a lock is released, all transactions are removed from the wait list and rescheduled.
 // If one of those transactions discovers that the lock it is waiting for is still locked,
 // it will place itself back on the wait list.
 type LockTable struct {
-	locks map[file.BlockId]int
-	mu    sync.Mutex
-	cond  *sync.Cond
+	locks       map[file.BlockId]map[int]LockMode
+	waiting     map[int]pendingRequest
+	mu          sync.Mutex
+	cond        *sync.Cond
+	maxWaitTime time.Duration
+	minWaitTime time.Duration
+	debug       bool
+	statsMu     sync.Mutex
+	averageWait time.Duration
+	slowWait    time.Duration
+	slowWaits   []SlowWait
+}
+
+// Option configures optional behaviour of a LockTable.
+type Option func(*LockTable)
+
+// WithMaxWaitTime sets how long a lock request waits for a conflicting lock to be released before aborting.
+func WithMaxWaitTime(d time.Duration) Option {
+	return func(lt *LockTable) {
+		lt.maxWaitTime = d
+	}
+}
+
+// WithDebug makes the LockTable panic when a transaction releases a lock it does not hold, instead of returning
+// ErrNotLockOwner. Such a release always indicates a bug in the caller.
+func WithDebug() Option {
+	return func(lt *LockTable) {
+		lt.debug = true
+	}
 }
 
-func NewLockTable() *LockTable {
-	lt := &LockTable{locks: make(map[file.BlockId]int)}
+func NewLockTable(opts ...Option) *LockTable {
+	lt := &LockTable{
+		locks:       make(map[file.BlockId]map[int]LockMode),
+		waiting:     make(map[int]pendingRequest),
+		maxWaitTime: DefaultMaxWaitTime,
+		slowWait:    DefaultSlowWaitThreshold,
+	}
+	for _, opt := range opts {
+		opt(lt)
+	}
 	lt.cond = sync.NewCond(&lt.mu)
 	return lt
 }
 
-func (lt *LockTable) SLock(block *file.BlockId) error {
-	lt.mu.Lock()
-	defer lt.mu.Unlock()
-
-	ctx, cancel := context.WithTimeout(context.Background(), maxWaitTime)
-	defer cancel()
+// SLock grants transaction txNum a shared lock on the specified block.
+// If another transaction holds an exclusive lock on the block, the calling thread is placed on a wait list until it
+// is released. If the thread remains on the wait list for longer than WaitTime(WaitNormal), then the method will
+// return an error. A transaction that already holds a lock on the block keeps it unchanged.
+func (lt *LockTable) SLock(txNum int, block *file.BlockId) error {
+	return lt.SLockWithin(txNum, block, lt.WaitTime(WaitNormal))
+}
 
-	// This function will run after the context expires.
-	stop := context.AfterFunc(ctx, func() {
-		lt.cond.L.Lock()
-		lt.cond.Broadcast()
-		lt.cond.L.Unlock()
-	})
+// SLockWithin is like SLock, but gives up after waiting for maxWait.
+func (lt *LockTable) SLockWithin(txNum int, block *file.BlockId, maxWait time.Duration) error {
+	return lt.LockWithin(txNum, block, Shared, maxWait)
+}
 
-	defer stop()
+// XLock grants transaction txNum an exclusive lock on the specified block, upgrading its shared lock if it holds one.
+// If a lock of any type (by some other transaction) exists when the method is called,
+// then the calling thread will be placed on a wait list until the locks are released.
+// If the thread remains on the wait list for longer than WaitTime(WaitNormal),
+// then the method will return an error.
+func (lt *LockTable) XLock(txNum int, block *file.BlockId) error {
+	return lt.XLockWithin(txNum, block, lt.WaitTime(WaitNormal))
+}
 
-	for {
-		// If there's no exclusive lock, we can proceed
-		if !lt.hasXLock(block) {
-			// Get the number of shared locks
-			val := lt.getLockVal(block)
-			// Grant the shared lock.
-			lt.locks[*block] = val + 1
-			return nil
-		}
+// XLockWithin is like XLock, but gives up after waiting for maxWait.
+func (lt *LockTable) XLockWithin(txNum int, block *file.BlockId, maxWait time.Duration) error {
+	return lt.LockWithin(txNum, block, Exclusive, maxWait)
+}
 
-		// Wait until notified or context is done
-		lt.cond.Wait()
+// LockWithin grants transaction txNum a lock of the given mode on the specified block, waiting at most maxWait for
+// conflicting locks of other transactions to be released. A transaction that already holds a lock on the block ends
+// up holding the weakest mode that covers both, see Combine.
+func (lt *LockTable) LockWithin(txNum int, block *file.BlockId, mode LockMode, maxWait time.Duration) error {
+	return lt.LockWithinContext(context.Background(), txNum, block, mode, maxWait)
+}
 
-		if ctx.Err() != nil {
-			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				return fmt.Errorf("lock abort execption: could not acquire shared lock on block %v: %v", block, ctx.Err())
+// LockWithinContext is like LockWithin, but also stops waiting when ctx is done, returning an error that wraps
+// ctx.Err().
+func (lt *LockTable) LockWithinContext(ctx context.Context, txNum int, block *file.BlockId, mode LockMode,
+	maxWait time.Duration) error {
+	return lt.acquire(ctx, txNum, mode, block, maxWait, func() bool {
+		owners := lt.locks[*block]
+		wanted := Combine(owners[txNum], mode)
+		for owner, held := range owners {
+			if owner != txNum && !compatible(held, wanted) {
+				return false
 			}
-			return ctx.Err()
 		}
-	}
+		lt.owners(block)[txNum] = wanted
+		return true
+	})
 }
 
-// XLock grants an exclusive lock on the specified block.
-// Assumes that the calling thread already has a shared lock on the block.
-// If a lock of any type (by some other transaction) exists when the method is called,
-// then the calling thread will be placed on a wait list until the locks are released.
-// If the thread remains on the wait list for too long (10 seconds for now),
-// then the method will return an error.
-func (lt *LockTable) XLock(block *file.BlockId) error {
+// acquire calls grant until it succeeds, waiting for a lock to be released between attempts, for at most maxWait or
+// until parent is done.
+// The time spent waiting feeds the contention estimate used by adaptive wait times.
+func (lt *LockTable) acquire(parent context.Context, txNum int, mode LockMode, block *file.BlockId,
+	maxWait time.Duration, grant func() bool) (err error) {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
-	ctx, cancel := context.WithTimeout(context.Background(), maxWaitTime)
+	if grant() {
+		return nil
+	}
+
+	start := time.Now()
+	lt.waiting[txNum] = pendingRequest{block: *block, mode: mode, since: start}
+	defer func() {
+		delete(lt.waiting, txNum)
+		waited := time.Since(start)
+		lt.recordWait(waited)
+		lt.recordSlowWait(txNum, block, mode, start, waited, err)
+	}()
+
+	ctx, cancel := context.WithTimeout(parent, maxWait)
 	defer cancel()
 
+	// This function will run after the context expires.
 	stop := context.AfterFunc(ctx, func() {
 		lt.cond.L.Lock()
 		lt.cond.Broadcast()
@@ -91,48 +189,82 @@ func (lt *LockTable) XLock(block *file.BlockId) error {
 	defer stop()
 
 	for {
-		// Assume that the calling thread already has a shared lock. If any shared locks exist, we cannot proceed.
-		if !lt.hasOtherSLocks(block) {
-			lt.locks[*block] = -1
-			return nil
-		}
+		// Wait until notified or context is done
 		lt.cond.Wait()
 
+		// A request whose wait has run out aborts even if the lock became free in the meantime, so that
+		// the waiters of a deadlock all give up instead of the last one winning the lock from the others.
 		if ctx.Err() != nil {
 			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
-				return fmt.Errorf("lock abort execption: could not acquire exlcusive lock on block %v:%v", block, ctx.Err())
+				return fmt.Errorf("lock abort exception: could not acquire %s lock on block %v: %v", modeNames[mode], block, ctx.Err())
 			}
-			return ctx.Err()
+			return fmt.Errorf("could not acquire %s lock on block %v: %w", modeNames[mode], block, ctx.Err())
+		}
+		if grant() {
+			return nil
 		}
 	}
 }
 
-// Unlock releases the lock on the specified block.
-// If this lock is the last lock on that block,
-// then the waiting transactions are notified.
-func (lt *LockTable) Unlock(block *file.BlockId) {
+// Unlock releases the lock held by transaction txNum on the specified block, and notifies the waiting transactions.
+// Releasing a lock the transaction does not hold returns ErrNotLockOwner, or panics if the table is in debug mode.
+func (lt *LockTable) Unlock(txNum int, block *file.BlockId) error {
 	lt.mu.Lock()
 	defer lt.mu.Unlock()
 
-	val := lt.getLockVal(block)
-	if val > 1 {
-		lt.locks[*block] = val - 1
-	} else {
+	owners := lt.locks[*block]
+	if _, ok := owners[txNum]; !ok {
+		err := fmt.Errorf("%w: transaction %d, block %v", ErrNotLockOwner, txNum, block)
+		if lt.debug {
+			panic(err)
+		}
+		return err
+	}
+	delete(owners, txNum)
+	if len(owners) == 0 {
 		delete(lt.locks, *block)
-		lt.cond.Broadcast()
 	}
+	// Any release can unblock a waiter: the last shared lock besides an upgrader's own lets the upgrade proceed.
+	lt.cond.Broadcast()
+	return nil
 }
 
-// hasXLock returns true if there is an exclusive lock on the block.
-func (lt *LockTable) hasXLock(block *file.BlockId) bool {
-	return lt.getLockVal(block) < 0
+// owners returns the lock holders of the block, creating the entry if necessary.
+func (lt *LockTable) owners(block *file.BlockId) map[int]LockMode {
+	owners, ok := lt.locks[*block]
+	if !ok {
+		owners = make(map[int]LockMode)
+		lt.locks[*block] = owners
+	}
+	return owners
 }
 
-// hasOtherSLocks returns true if there is more than one shared locks on the block.
-func (lt *LockTable) hasOtherSLocks(block *file.BlockId) bool {
-	return lt.getLockVal(block) > 1
+// compatible reports whether two transactions can hold locks of modes a and b on the same block at the same time.
+func compatible(a, b LockMode) bool {
+	switch {
+	case a == Exclusive || b == Exclusive:
+		return false
+	case a == IntentionShared || b == IntentionShared:
+		return true
+	default:
+		// Shared locks only coexist with shared locks, intention exclusive locks with intention exclusive locks.
+		return a == b
+	}
 }
 
-func (lt *LockTable) getLockVal(block *file.BlockId) int {
-	return lt.locks[*block]
+// Combine returns the mode of the lock a transaction holds after requesting mode requested while holding mode held
+// (zero if it held none). Shared and intention exclusive combine to exclusive: there is no SIX mode.
+func Combine(held, requested LockMode) LockMode {
+	switch {
+	case held == 0 || held == requested:
+		return requested
+	case held == Exclusive || requested == Exclusive:
+		return Exclusive
+	case held == IntentionShared:
+		return requested
+	case requested == IntentionShared:
+		return held
+	default:
+		return Exclusive
+	}
 }
@@ -0,0 +1,181 @@
+package concurrency
+
+import (
+	"mydb/file"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLockTableOwners(t *testing.T) {
+	block := file.NewBlockId("testfile", 1)
+
+	t.Run("repeated shared lock is held once", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(100 * time.Millisecond))
+		require.NoError(t, lt.SLock(1, block))
+		require.NoError(t, lt.SLock(1, block))
+		require.NoError(t, lt.Unlock(1, block))
+
+		require.NoError(t, lt.XLock(2, block), "a single release must free the block")
+		assert.ErrorIs(t, lt.Unlock(1, block), ErrNotLockOwner)
+	})
+
+	t.Run("only the owner can release", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(100 * time.Millisecond))
+		require.NoError(t, lt.XLock(1, block))
+		assert.ErrorIs(t, lt.Unlock(2, block), ErrNotLockOwner)
+
+		err := lt.SLock(2, block)
+		assert.ErrorContains(t, err, "lock abort exception")
+	})
+
+	t.Run("owner can read its exclusive lock", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(100 * time.Millisecond))
+		require.NoError(t, lt.XLock(1, block))
+		require.NoError(t, lt.SLock(1, block))
+		require.NoError(t, lt.Unlock(1, block))
+		require.NoError(t, lt.XLock(2, block))
+	})
+
+	t.Run("upgrade waits for other shared locks", func(t *testing.T) {
+		lt := NewLockTable()
+		require.NoError(t, lt.SLock(1, block))
+		require.NoError(t, lt.SLock(2, block))
+
+		upgraded := make(chan error)
+		go func() { upgraded <- lt.XLock(1, block) }()
+
+		select {
+		case err := <-upgraded:
+			t.Fatalf("upgrade granted while another shared lock is held: %v", err)
+		case <-time.After(50 * time.Millisecond):
+		}
+		require.NoError(t, lt.Unlock(2, block))
+		select {
+		case err := <-upgraded:
+			assert.NoError(t, err)
+		case <-time.After(time.Second):
+			t.Fatal("upgrade not granted after the other shared lock was released")
+		}
+	})
+
+	t.Run("debug mode panics on mismatch", func(t *testing.T) {
+		lt := NewLockTable(WithDebug())
+		assert.Panics(t, func() { _ = lt.Unlock(1, block) })
+	})
+}
+
+func TestWaitTime(t *testing.T) {
+	block := file.NewBlockId("testfile", 1)
+
+	t.Run("priorities scale the wait time", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(time.Second))
+		assert.Equal(t, time.Second, lt.WaitTime(WaitNormal))
+		assert.Equal(t, 250*time.Millisecond, lt.WaitTime(WaitInteractive))
+		assert.Equal(t, 4*time.Second, lt.WaitTime(WaitBatch))
+	})
+
+	t.Run("adaptive wait time follows contention", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(time.Second), WithAdaptiveWaitTime(20*time.Millisecond))
+		assert.Equal(t, 20*time.Millisecond, lt.WaitTime(WaitNormal), "no contention observed yet")
+
+		require.NoError(t, lt.XLock(1, block))
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			_ = lt.Unlock(1, block)
+		}()
+		require.NoError(t, lt.SLockWithin(2, block, time.Second))
+
+		assert.GreaterOrEqual(t, lt.AverageWait(), 40*time.Millisecond)
+		assert.Equal(t, adaptiveWaitFactor*lt.AverageWait(), lt.WaitTime(WaitNormal))
+	})
+
+	t.Run("explicit wait time overrides the table", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(time.Hour))
+		require.NoError(t, lt.XLock(1, block))
+
+		m := NewManager(lt, 2)
+		m.SetWaitTime(20 * time.Millisecond)
+		start := time.Now()
+		assert.ErrorContains(t, m.SLock(block), "lock abort exception")
+		assert.Less(t, time.Since(start), time.Second)
+	})
+}
+
+func TestWaits(t *testing.T) {
+	block := file.NewBlockId("testfile", 1)
+	lt := NewLockTable(WithMaxWaitTime(time.Second))
+	assert.Empty(t, lt.Waits())
+
+	require.NoError(t, lt.SLock(1, block))
+	require.NoError(t, lt.SLock(2, block))
+	granted := make(chan error)
+	go func() { granted <- lt.XLock(3, block) }()
+
+	require.Eventually(t, func() bool { return len(lt.Waits()) == 1 }, time.Second, time.Millisecond)
+	wait := lt.Waits()[0]
+	assert.Equal(t, 3, wait.TxNum)
+	assert.Equal(t, *block, wait.Block)
+	assert.Equal(t, Exclusive, wait.Mode)
+	assert.Equal(t, []Holder{{TxNum: 1, Mode: Shared}, {TxNum: 2, Mode: Shared}}, wait.Holders)
+	assert.False(t, wait.Since.IsZero())
+
+	require.NoError(t, lt.Unlock(1, block))
+	require.NoError(t, lt.Unlock(2, block))
+	require.NoError(t, <-granted)
+	assert.Empty(t, lt.Waits())
+}
+
+func TestLockFile(t *testing.T) {
+	block := file.NewBlockId("testfile", 1)
+	other := file.NewBlockId("testfile", 2)
+
+	t.Run("file lock conflicts with block locks", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(50 * time.Millisecond))
+		loader := NewManager(lt, 1)
+		require.NoError(t, loader.LockFile("testfile", Exclusive))
+		require.NoError(t, loader.XLock(block))
+		assert.Equal(t, []Holder{{TxNum: 1, Mode: Exclusive}}, holders(lt, *fileLock("testfile")))
+		assert.Empty(t, holders(lt, *block), "the file lock covers the block")
+
+		reader := NewManager(lt, 2)
+		assert.ErrorContains(t, reader.SLock(other), "lock abort exception")
+		require.NoError(t, loader.Release())
+		require.NoError(t, reader.SLock(other))
+
+		assert.ErrorContains(t, loader.LockFile("testfile", Exclusive), "lock abort exception",
+			"a block lock of another transaction blocks the file lock")
+	})
+
+	t.Run("block locks of different blocks coexist", func(t *testing.T) {
+		lt := NewLockTable(WithMaxWaitTime(50 * time.Millisecond))
+		require.NoError(t, NewManager(lt, 1).XLock(block))
+		require.NoError(t, NewManager(lt, 2).XLock(other))
+		require.NoError(t, NewManager(lt, 3).SLock(file.NewBlockId("testfile", 3)))
+	})
+
+	t.Run("only shared or exclusive", func(t *testing.T) {
+		assert.ErrorContains(t, NewManager(NewLockTable(), 1).LockFile("testfile", IntentionShared), "intention shared")
+	})
+}
+
+func TestCombine(t *testing.T) {
+	assert.Equal(t, Shared, Combine(0, Shared))
+	assert.Equal(t, IntentionExclusive, Combine(IntentionShared, IntentionExclusive))
+	assert.Equal(t, Shared, Combine(Shared, IntentionShared))
+	assert.Equal(t, Exclusive, Combine(Shared, IntentionExclusive))
+	assert.Equal(t, Exclusive, Combine(Exclusive, Shared))
+}
+
+// holders returns the holders of a lock, ordered by transaction number.
+func holders(lt *LockTable, block file.BlockId) []Holder {
+	var result []Holder
+	for _, txNum := range []int{1, 2, 3} {
+		if mode, ok := lt.locks[block][txNum]; ok {
+			result = append(result, Holder{TxNum: txNum, Mode: mode})
+		}
+	}
+	return result
+}
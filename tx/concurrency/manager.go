@@ -1,26 +1,63 @@
 package concurrency
 
-import "mydb/file"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer emits a span for every request made to the lock table.
+var tracer = otel.Tracer("mydb/tx/concurrency")
+
+// FileLockBlock is the block number under which the lock table records the lock on a whole file. Locking a block
+// first takes an intention lock on its file, so a transaction locking the file as a whole waits for, and is waited for
+// by, transactions locking single blocks of it.
+const FileLockBlock = -2
+
+// Manager tracks the locks held by a single transaction, identified by its transaction number in the lock table.
 type Manager struct {
 	lockTable *LockTable // pointer to the global lock table
-	locks     map[file.BlockId]string
+	txNum     int
+	locks     map[file.BlockId]LockMode
+	ctx       context.Context
+	priority  WaitPriority
+	waitTime  time.Duration
+}
+
+// NewManager creates a new Manager for transaction txNum.
+func NewManager(lockTable *LockTable, txNum int) *Manager {
+	return NewManagerWithContext(context.Background(), lockTable, txNum)
 }
 
-// NewManager creates a new Manager.
-func NewManager(lockTable *LockTable) *Manager {
-	return &Manager{lockTable: lockTable, locks: make(map[file.BlockId]string)}
+// NewManagerWithContext creates a new Manager whose lock acquisition spans are children of the span carried by ctx.
+// Lock requests stop waiting once ctx is done.
+func NewManagerWithContext(ctx context.Context, lockTable *LockTable, txNum int) *Manager {
+	return &Manager{lockTable: lockTable, txNum: txNum, locks: make(map[file.BlockId]LockMode), ctx: ctx}
 }
 
 // SLock obtains a shared lock on the block, if necessary.
-// The method will ask the lock table for an SLock if the transaction currently has no locks on the block.
+// The method will ask the lock table for an SLock if the transaction currently has no locks on the block, after
+// taking an intention shared lock on its file. A lock on the whole file makes block locks unnecessary.
 func (m *Manager) SLock(block *file.BlockId) error {
+	if m.coversFile(block.Filename(), Shared) {
+		return nil
+	}
+	if err := m.lock(fileLock(block.Filename()), IntentionShared); err != nil {
+		return err
+	}
 	//if the lock does not exist in the locks map, acquire it from the lock table
 	if _, ok := m.locks[*block]; !ok {
-		if err := m.lockTable.SLock(block); err != nil {
+		if err := m.traced("lock.slock", block, Shared); err != nil {
 			return err
 		}
-		m.locks[*block] = "s"
+		m.locks[*block] = Shared
 	}
 	return nil
 }
@@ -28,28 +65,103 @@ func (m *Manager) SLock(block *file.BlockId) error {
 // XLock obtains an exclusive lock on the block, if necessary.
 // If the transaction does not have an exclusive lock on the block,
 // the method first gets a shared lock on that block (if necessary), and then upgrades it to an exclusive lock.
+// The file is locked in intention exclusive mode first, unless the transaction holds an exclusive lock on it.
 func (m *Manager) XLock(block *file.BlockId) error {
+	if m.coversFile(block.Filename(), Exclusive) {
+		return nil
+	}
+	if err := m.lock(fileLock(block.Filename()), IntentionExclusive); err != nil {
+		return err
+	}
 	if !m.hasXLock(block) {
 		if err := m.SLock(block); err != nil {
 			return err
 		}
-		if err := m.lockTable.XLock(block); err != nil {
+		if err := m.traced("lock.xlock", block, Exclusive); err != nil {
 			return err
 		}
-		m.locks[*block] = "x"
+		m.locks[*block] = Exclusive
 	}
 	return nil
 }
 
-func (m *Manager) Release() {
+// LockFile locks the whole file in the given mode, Shared or Exclusive, so the transaction needs no locks on its
+// blocks. The lock waits for transactions holding conflicting locks on any block of the file.
+// A transaction holding intention exclusive locks that locks the file in shared mode gets an exclusive lock.
+func (m *Manager) LockFile(filename string, mode LockMode) error {
+	if mode != Shared && mode != Exclusive {
+		return fmt.Errorf("files can only be locked in shared or exclusive mode, not %s", modeNames[mode])
+	}
+	return m.lock(fileLock(filename), mode)
+}
+
+// lock obtains a lock of the given mode on block if the transaction does not hold one that covers it already.
+func (m *Manager) lock(block *file.BlockId, mode LockMode) error {
+	held := m.locks[*block]
+	wanted := Combine(held, mode)
+	if wanted == held {
+		return nil
+	}
+	if err := m.traced("lock."+strings.ToLower(wanted.String())+"lock", block, wanted); err != nil {
+		return err
+	}
+	m.locks[*block] = wanted
+	return nil
+}
+
+// coversFile returns true if the transaction holds a lock on the whole file that covers a block lock of mode.
+func (m *Manager) coversFile(filename string, mode LockMode) bool {
+	held := m.locks[*fileLock(filename)]
+	return held == Exclusive || (held == Shared && mode == Shared)
+}
+
+// fileLock returns the block under which the lock on the whole file is recorded.
+func fileLock(filename string) *file.BlockId {
+	return &file.BlockId{File: filename, BlockNumber: FileLockBlock}
+}
+
+// Release releases every lock held by the transaction. It returns an error if the lock table disagrees about a lock
+// the transaction believed it held; the remaining locks are released regardless.
+func (m *Manager) Release() error {
+	var errs []error
 	for block := range m.locks {
-		m.lockTable.Unlock(&block)
+		if err := m.lockTable.Unlock(m.txNum, &block); err != nil {
+			errs = append(errs, err)
+		}
 	}
-	m.locks = make(map[file.BlockId]string)
+	m.locks = make(map[file.BlockId]LockMode)
+	return errors.Join(errs...)
+}
+
+// SetWaitPriority sets the priority used to derive the lock wait time from the lock table.
+func (m *Manager) SetWaitPriority(priority WaitPriority) {
+	m.priority = priority
+}
+
+// SetWaitTime overrides the lock wait time of the lock table for this transaction. Zero restores the default.
+func (m *Manager) SetWaitTime(d time.Duration) {
+	m.waitTime = d
+}
+
+// maxWait returns how long a lock request of this transaction waits before aborting.
+func (m *Manager) maxWait() time.Duration {
+	if m.waitTime > 0 {
+		return m.waitTime
+	}
+	return m.lockTable.WaitTime(m.priority)
 }
 
 // hasXLock returns true if the transaction has an exclusive lock on the block.
 func (m *Manager) hasXLock(block *file.BlockId) bool {
-	lock, ok := m.locks[*block]
-	return ok && lock == "x"
+	return m.locks[*block] == Exclusive
+}
+
+// traced requests a lock of the given mode from the lock table inside a span, so time spent waiting for a lock shows
+// up in traces. The request gives up when the context of the manager is done.
+func (m *Manager) traced(name string, block *file.BlockId, mode LockMode) error {
+	attrs := append(utils.BlockAttributes(block.Filename(), block.Number()), utils.TxNumAttribute(m.txNum))
+	ctx, span := tracer.Start(m.ctx, name, trace.WithAttributes(attrs...))
+	err := m.lockTable.LockWithinContext(ctx, m.txNum, block, mode, m.maxWait())
+	utils.EndSpan(span, err)
+	return err
 }
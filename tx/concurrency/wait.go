@@ -0,0 +1,71 @@
+package concurrency
+
+import "time"
+
+// WaitPriority declares how long a transaction is prepared to wait for a lock. Interactive transactions prefer to
+// fail fast and retry, while batch transactions would rather wait than redo a large amount of work.
+type WaitPriority int
+
+const (
+	WaitNormal WaitPriority = iota
+	WaitInteractive
+	WaitBatch
+)
+
+const (
+	// interactiveWaitDivisor and batchWaitFactor scale the normal wait time for the other priorities.
+	interactiveWaitDivisor = 4
+	batchWaitFactor        = 4
+	// adaptiveWaitFactor is how many times the average observed wait an adaptive wait time allows.
+	adaptiveWaitFactor = 4
+	// waitSmoothing is the weight of the latest sample in the moving average of observed waits.
+	waitSmoothing = 0.2
+)
+
+// WithAdaptiveWaitTime makes the normal wait time follow the contention observed by the LockTable: a lock request
+// waits adaptiveWaitFactor times the moving average of recent waits, but never less than minWait and never more than
+// the maximum wait time. Under low contention, deadlocked transactions are then detected quickly, while under heavy
+// contention transactions are not aborted merely for queueing.
+func WithAdaptiveWaitTime(minWait time.Duration) Option {
+	return func(lt *LockTable) {
+		lt.minWaitTime = minWait
+	}
+}
+
+// WaitTime returns how long a lock request of the given priority waits before aborting.
+func (lt *LockTable) WaitTime(priority WaitPriority) time.Duration {
+	lt.statsMu.Lock()
+	base := lt.maxWaitTime
+	if lt.minWaitTime > 0 {
+		base = min(max(adaptiveWaitFactor*lt.averageWait, lt.minWaitTime), lt.maxWaitTime)
+	}
+	lt.statsMu.Unlock()
+
+	switch priority {
+	case WaitInteractive:
+		return base / interactiveWaitDivisor
+	case WaitBatch:
+		return base * batchWaitFactor
+	default:
+		return base
+	}
+}
+
+// AverageWait returns the moving average of the time lock requests had to wait before being granted or aborted.
+// Requests granted immediately do not count.
+func (lt *LockTable) AverageWait() time.Duration {
+	lt.statsMu.Lock()
+	defer lt.statsMu.Unlock()
+	return lt.averageWait
+}
+
+// recordWait adds a wait to the moving average.
+func (lt *LockTable) recordWait(d time.Duration) {
+	lt.statsMu.Lock()
+	defer lt.statsMu.Unlock()
+	if lt.averageWait == 0 {
+		lt.averageWait = d
+		return
+	}
+	lt.averageWait = time.Duration(waitSmoothing*float64(d) + (1-waitSmoothing)*float64(lt.averageWait))
+}
@@ -15,6 +15,7 @@ import (
 	"time"
 
 	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
 )
 
 type TransactionResult struct {
@@ -303,9 +304,11 @@ func TestDeadlockDetection(t *testing.T) {
 	assert.NotNil(t, resultB, "Transaction B result missing")
 
 	for _, result := range []*TransactionResult{resultA, resultB} {
+		if result == nil {
+			continue
+		}
 		assert.True(t, result.Aborted, "Transaction should have aborted")
-		assert.Error(t, result.Error, "Aborted transaction should have error")
-		assert.Contains(t, result.Error.Error(), "lock abort exception", "Aborted transaction should have lock abort error")
+		assert.ErrorContains(t, result.Error, "lock abort exception", "Aborted transaction should have lock abort error")
 	}
 
 }
@@ -415,3 +418,32 @@ func transactionDeadlockB(fm *file.Manager, lm *log.Manager, bm *buffer.Manager,
 	result.Committed = true
 	return result
 }
+
+func TestReleaseErrors(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	for name, finish := range map[string]func(*tx.Transaction) error{
+		"commit":   (*tx.Transaction).Commit,
+		"rollback": (*tx.Transaction).Rollback,
+	} {
+		t.Run(name, func(t *testing.T) {
+			transaction := tx.NewTransaction(fm, lm, bm, lt)
+			block, err := transaction.Append("testfile")
+			require.NoError(t, err)
+			require.NoError(t, transaction.Pin(block))
+			require.NoError(t, transaction.SetInt(block, 0, 1, true))
+
+			// Someone else releasing the lock leaves the transaction unable to release it
+			require.NoError(t, lt.Unlock(transaction.TxNum(), block))
+			err = finish(transaction)
+			assert.ErrorIs(t, err, concurrency.ErrNotLockOwner)
+			assert.True(t, transaction.Finished())
+			assert.Equal(t, 8, bm.Available(), "buffers are unpinned despite the error")
+		})
+	}
+}
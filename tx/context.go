@@ -0,0 +1,24 @@
+package tx
+
+import "context"
+
+// contextKey is the key under which WithTx stores a transaction in a context.
+type contextKey struct{}
+
+// WithTx returns a copy of ctx that carries transaction t. Code handed the context finds the transaction with
+// TxFromContext, so embedders can pass one context through their handlers instead of a *Transaction.
+func WithTx(ctx context.Context, t *Transaction) context.Context {
+	return context.WithValue(ctx, contextKey{}, t)
+}
+
+// TxFromContext returns the transaction carried by ctx. The second result is false if ctx carries none.
+func TxFromContext(ctx context.Context) (*Transaction, bool) {
+	t, ok := ctx.Value(contextKey{}).(*Transaction)
+	return t, ok && t != nil
+}
+
+// Context returns a context that carries the transaction and its trace span. Spans started from it, by the
+// embedder or by a transaction created with NewTransactionWithContext, become children of the transaction span.
+func (tx *Transaction) Context() context.Context {
+	return WithTx(tx.ctx, tx)
+}
@@ -0,0 +1,85 @@
+package tx_test
+
+import (
+	"context"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTxFromContext(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	transaction := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), concurrency.NewLockTable())
+
+	_, ok := tx.TxFromContext(context.Background())
+	assert.False(t, ok)
+
+	found, ok := tx.TxFromContext(tx.WithTx(context.Background(), transaction))
+	assert.True(t, ok)
+	assert.Same(t, transaction, found)
+
+	found, ok = tx.TxFromContext(transaction.Context())
+	assert.True(t, ok)
+	assert.Same(t, transaction, found)
+	require.NoError(t, transaction.Commit())
+}
+
+func TestCancellation(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	block, err := fm.Append("testfile")
+	require.NoError(t, err)
+
+	t.Run("rolls back at the next pin", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		transaction := tx.NewTransactionWithContext(ctx, fm, lm, bm, lt)
+		require.NoError(t, transaction.Pin(block))
+		require.NoError(t, transaction.SetInt(block, 0, 42, true))
+
+		cancel()
+		assert.ErrorIs(t, transaction.Pin(block), context.Canceled)
+		assert.True(t, transaction.Finished())
+		assert.Equal(t, 8, bm.Available(), "the pins are released")
+		assert.ErrorIs(t, transaction.Rollback(), tx.ErrTxDone)
+		assert.ErrorIs(t, transaction.Commit(), tx.ErrTxDone)
+
+		reader := tx.NewTransaction(fm, lm, bm, lt)
+		require.NoError(t, reader.Pin(block))
+		value, err := reader.GetInt(block, 0)
+		require.NoError(t, err)
+		assert.Equal(t, 0, value, "the change is undone")
+		require.NoError(t, reader.Commit())
+	})
+
+	t.Run("stops a lock wait", func(t *testing.T) {
+		writer := tx.NewTransaction(fm, lm, bm, lt)
+		require.NoError(t, writer.Pin(block))
+		require.NoError(t, writer.SetInt(block, 0, 1, true))
+
+		ctx, cancel := context.WithCancel(context.Background())
+		reader := tx.NewTransactionWithContext(ctx, fm, lm, bm, lt)
+		reader.SetLockWaitTime(time.Minute)
+		require.NoError(t, reader.Pin(block))
+		time.AfterFunc(20*time.Millisecond, cancel)
+		start := time.Now()
+		_, err := reader.GetInt(block, 0)
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Less(t, time.Since(start), 10*time.Second)
+		assert.True(t, reader.Finished())
+		require.NoError(t, writer.Commit())
+	})
+}
@@ -0,0 +1,159 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/tx/concurrency"
+	"mydb/utils"
+)
+
+// DeleteFileRecord records that a transaction deleted a file. The file is only removed once the transaction has
+// committed, so there is nothing to undo; recovery removes the file if a crash came before it was.
+type DeleteFileRecord struct {
+	LogRecord
+	txNum    int
+	fileName string
+}
+
+func NewDeleteFileRecord(page *file.Page) (*DeleteFileRecord, error) {
+	reader := newRecordReader(page, "DeleteFile")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	return &DeleteFileRecord{txNum: txNum, fileName: fileName}, nil
+}
+
+func (r *DeleteFileRecord) Op() LogRecordType {
+	return DeleteFile
+}
+
+func (r *DeleteFileRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *DeleteFileRecord) changedFile() string {
+	return r.fileName
+}
+
+func (r *DeleteFileRecord) String() string {
+	return fmt.Sprintf("<DELETEFILE %d %s>", r.txNum, r.fileName)
+}
+
+// Undo does nothing, as the file is only removed after the transaction commits.
+func (r *DeleteFileRecord) Undo(tx *Transaction) error {
+	return nil
+}
+
+// redo removes the file, for a committed transaction that crashed before it could.
+func (r *DeleteFileRecord) redo(tx *Transaction) error {
+	return tx.shrinkFile(r.fileName, deletedFile)
+}
+
+// WriteDeleteFileToLog writes a DeleteFile record for the deletion of fileName.
+func WriteDeleteFileToLog(logManager LogAppender, txNum int, fileName string) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	recordLen := fileNamePos + file.MaxLength(len(fileName))
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(DeleteFile))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+
+	return logManager.Append(recordBytes)
+}
+
+// deletedFile is the number of blocks kept by a file the transaction deletes, see Transaction.shrinks.
+const deletedFile = -1
+
+// DeleteFile deletes filename when the transaction commits. The transaction locks the whole file exclusively, which
+// also covers its end-of-file marker, and logs a DeleteFile record. Until the commit the file is left as it is, so
+// a rollback has nothing to undo; the file is removed once the commit record is on disk, by recovery if a crash
+// comes first. Temporary files are deleted the same way, without logging. The free map and the overflow file of
+// the file, if any, are deleted along with it.
+func (tx *Transaction) DeleteFile(filename string) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.LockFile(filename, concurrency.Exclusive); err != nil {
+		return err
+	}
+	if !file.IsTempFile(filename) {
+		if err := tx.recoveryManager.DeleteFile(filename); err != nil {
+			return err
+		}
+	}
+	tx.shrink(filename, deletedFile)
+
+	for _, sidecar := range []string{filename + FreeMapSuffix, filename + OverflowSuffix} {
+		exists, err := tx.fileManager.Exists(sidecar)
+		if err != nil {
+			return err
+		}
+		if exists {
+			if err := tx.DeleteFile(sidecar); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// shrink records that filename keeps only its first numBlocks blocks when the transaction commits.
+func (tx *Transaction) shrink(filename string, numBlocks int) {
+	if kept, ok := tx.shrinks[filename]; ok && kept <= numBlocks {
+		return
+	}
+	if tx.shrinks == nil {
+		tx.shrinks = make(map[string]int)
+	}
+	tx.shrinks[filename] = numBlocks
+}
+
+// shrinkFiles deletes and truncates the files as the transaction asked with DeleteFile and Truncate.
+func (tx *Transaction) shrinkFiles() error {
+	for filename, numBlocks := range tx.shrinks {
+		if err := tx.shrinkFile(filename, numBlocks); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// shrinkFile deletes filename if numBlocks is deletedFile, and otherwise truncates it to numBlocks blocks unless it
+// is already shorter. The buffers of the blocks that are gone are then discarded.
+func (tx *Transaction) shrinkFile(filename string, numBlocks int) error {
+	if numBlocks == deletedFile {
+		if err := tx.fileManager.Delete(filename); err != nil {
+			return err
+		}
+	} else {
+		length, err := tx.fileManager.Length(filename)
+		if err != nil {
+			return err
+		}
+		if length <= numBlocks {
+			return nil
+		}
+		if err := tx.fileManager.Truncate(filename, numBlocks); err != nil {
+			return err
+		}
+	}
+	tx.bufferManager.Discard(filename, max(numBlocks, 0))
+	return nil
+}
@@ -0,0 +1,17 @@
+package tx
+
+import "mydb/log"
+
+// CheckpointInProgress returns true while QuiescentCheckpoint holds back new writers to logManager.
+func CheckpointInProgress(logManager *log.Manager) bool {
+	quiescence.mu.Lock()
+	defer quiescence.mu.Unlock()
+	return quiescence.quiescing[logManager]
+}
+
+// ResetTxNumbers makes transaction numbers start again at 1, as they do in a new process.
+func ResetTxNumbers() {
+	nextTxNumMu.Lock()
+	defer nextTxNumMu.Unlock()
+	nextTxNum = 0
+}
@@ -0,0 +1,148 @@
+package tx
+
+import (
+	"fmt"
+	"math/bits"
+	"mydb/file"
+)
+
+// FreeMapSuffix is appended to the name of a file to name its free map, the file tracking which of its blocks are
+// free. The free map is a bitmap with one bit per block of the file, set while the block is free. It is read and
+// changed through the transaction like any other file, so freeing and reusing blocks is locked, logged and rolled
+// back like the rest of a transaction's changes.
+const FreeMapSuffix = ".free"
+
+// FreeBlock marks block as free, so that Append hands it out again instead of growing the file once the transaction
+// has committed. The block must no longer be referenced. Like Append, FreeBlock locks the end-of-file marker of the
+// file, and then the part of the free map covering the block.
+func (tx *Transaction) FreeBlock(block *file.BlockId) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(file.NewBlockId(block.Filename(), EndOfFile)); err != nil {
+		return err
+	}
+	length, err := tx.fileManager.Length(block.Filename())
+	if err != nil {
+		return err
+	}
+	if block.Number() >= length {
+		return fmt.Errorf("cannot free block %s past the end of the file", block)
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+
+	freeMap := block.Filename() + FreeMapSuffix
+	mapBlock, offset, mask := tx.freeMapBit(block)
+	mapSize, err := tx.Size(freeMap)
+	if err != nil {
+		return err
+	}
+	for ; mapSize <= mapBlock.Number(); mapSize++ {
+		if _, err := tx.appendMapBlock(freeMap); err != nil {
+			return err
+		}
+	}
+	if err := tx.Pin(mapBlock); err != nil {
+		return err
+	}
+	defer tx.Unpin(mapBlock)
+	b, err := tx.GetBytes(mapBlock, offset, 1)
+	if err != nil {
+		return err
+	}
+	if b[0]&mask != 0 {
+		return fmt.Errorf("block %s is already free", block)
+	}
+	if err := tx.SetBytes(mapBlock, offset, []byte{b[0] | mask}, true); err != nil {
+		return err
+	}
+	if tx.freed == nil {
+		tx.freed = make(map[file.BlockId]bool)
+	}
+	tx.freed[*block] = true
+	return nil
+}
+
+// freeMapBit returns the block of the free map, the offset in it and the bit of the byte at that offset that
+// track block.
+func (tx *Transaction) freeMapBit(block *file.BlockId) (*file.BlockId, int, byte) {
+	bitsPerBlock := 8 * tx.BlockSize()
+	bit := block.Number() % bitsPerBlock
+	return file.NewBlockId(block.Filename()+FreeMapSuffix, block.Number()/bitsPerBlock), bit / 8, 1 << (bit % 8)
+}
+
+// appendMapBlock appends a block to a free map. Free maps do not have free maps of their own.
+func (tx *Transaction) appendMapBlock(freeMap string) (*file.BlockId, error) {
+	if err := tx.xLock(file.NewBlockId(freeMap, EndOfFile)); err != nil {
+		return nil, err
+	}
+	return tx.fileManager.Append(freeMap)
+}
+
+// reuseFreeBlock takes the first free block of filename out of its free map, zeroes it and returns it, or returns
+// nil if the file has no free block. The caller must hold the exclusive lock on the end-of-file marker of the file.
+// Free blocks past the end of the file, left by a truncation, are dropped from the free map.
+func (tx *Transaction) reuseFreeBlock(filename string) (*file.BlockId, error) {
+	freeMap := filename + FreeMapSuffix
+	exists, err := tx.fileManager.Exists(freeMap)
+	if err != nil || !exists {
+		return nil, err
+	}
+	mapSize, err := tx.Size(freeMap)
+	if err != nil {
+		return nil, err
+	}
+	length, err := tx.fileManager.Length(filename)
+	if err != nil {
+		return nil, err
+	}
+	bitsPerBlock := 8 * tx.BlockSize()
+	for n := 0; n < mapSize; n++ {
+		mapBlock := file.NewBlockId(freeMap, n)
+		block, err := tx.takeFreeBlock(filename, mapBlock, n*bitsPerBlock, length)
+		if err != nil || block != nil {
+			return block, err
+		}
+	}
+	return nil, nil
+}
+
+// takeFreeBlock is reuseFreeBlock for the block mapBlock of the free map, whose first bit tracks block first.
+func (tx *Transaction) takeFreeBlock(filename string, mapBlock *file.BlockId, first, length int) (*file.BlockId, error) {
+	if err := tx.Pin(mapBlock); err != nil {
+		return nil, err
+	}
+	defer tx.Unpin(mapBlock)
+	bitmap, err := tx.GetBytes(mapBlock, 0, tx.BlockSize())
+	if err != nil {
+		return nil, err
+	}
+	for offset, b := range bitmap {
+		for b != 0 {
+			bit := bits.TrailingZeros8(b)
+			b &^= 1 << bit
+			if err := tx.SetBytes(mapBlock, offset, []byte{b}, true); err != nil {
+				return nil, err
+			}
+			if n := first + 8*offset + bit; n < length {
+				return tx.zeroBlock(file.NewBlockId(filename, n))
+			}
+		}
+	}
+	return nil, nil
+}
+
+// zeroBlock clears a reused block. The old contents of a block freed by another transaction are garbage, so the
+// change is not logged; a block the transaction freed itself gets its contents back if the transaction rolls back.
+func (tx *Transaction) zeroBlock(block *file.BlockId) (*file.BlockId, error) {
+	if err := tx.Pin(block); err != nil {
+		return nil, err
+	}
+	defer tx.Unpin(block)
+	if err := tx.SetBytes(block, 0, make([]byte, tx.BlockSize()), tx.freed[*block]); err != nil {
+		return nil, err
+	}
+	return block, nil
+}
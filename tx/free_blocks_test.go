@@ -0,0 +1,102 @@
+package tx_test
+
+import (
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFreeBlocks(t *testing.T) {
+	backend := file.NewMemoryBackend()
+	fm, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	setup := tx.NewTransaction(fm, lm, bm, lt)
+	for i := 0; i < 4; i++ {
+		block, err := setup.Append("data.tbl")
+		require.NoError(t, err)
+		require.NoError(t, setup.Pin(block))
+		require.NoError(t, setup.SetInt(block, 0, 10+i, true))
+	}
+	require.NoError(t, setup.Commit())
+
+	appendBlock := func(commit bool) (int, int) {
+		transaction := tx.NewTransaction(fm, lm, bm, lt)
+		block, err := transaction.Append("data.tbl")
+		require.NoError(t, err)
+		require.NoError(t, transaction.Pin(block))
+		value, err := transaction.GetInt(block, 0)
+		require.NoError(t, err)
+		if commit {
+			require.NoError(t, transaction.Commit())
+		} else {
+			require.NoError(t, transaction.Rollback())
+		}
+		return block.Number(), value
+	}
+	free := func(commit bool, numbers ...int) {
+		transaction := tx.NewTransaction(fm, lm, bm, lt)
+		for _, n := range numbers {
+			require.NoError(t, transaction.FreeBlock(file.NewBlockId("data.tbl", n)))
+		}
+		if commit {
+			require.NoError(t, transaction.Commit())
+		} else {
+			require.NoError(t, transaction.Rollback())
+		}
+	}
+
+	free(false, 1)
+	n, _ := appendBlock(true)
+	assert.Equal(t, 4, n, "a rolled back free does not free the block")
+
+	free(true, 2, 1)
+	n, value := appendBlock(false)
+	assert.Equal(t, 1, n, "the lowest free block is reused first")
+	assert.Equal(t, 0, value, "a reused block is zeroed")
+	n, _ = appendBlock(true)
+	assert.Equal(t, 1, n, "a rolled back append leaves the block free")
+	n, _ = appendBlock(true)
+	assert.Equal(t, 2, n)
+	n, _ = appendBlock(true)
+	assert.Equal(t, 5, n, "the file grows once no block is free")
+
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.FreeBlock(file.NewBlockId("data.tbl", 3)))
+	block, err := transaction.Append("data.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 3, block.Number(), "a transaction reuses the blocks it freed itself")
+	require.NoError(t, transaction.Rollback())
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.Pin(block))
+	value, err = transaction.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 13, value, "rolling back restores a block freed and reused by the same transaction")
+	require.NoError(t, transaction.Commit())
+
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.FreeBlock(file.NewBlockId("data.tbl", 3)))
+	assert.Error(t, transaction.FreeBlock(file.NewBlockId("data.tbl", 3)), "a block cannot be freed twice")
+	assert.Error(t, transaction.FreeBlock(file.NewBlockId("data.tbl", 6)), "blocks past the end cannot be freed")
+	require.NoError(t, transaction.Truncate("data.tbl", 3))
+	require.NoError(t, transaction.Commit())
+	n, _ = appendBlock(true)
+	assert.Equal(t, 3, n, "free blocks past the end of a truncated file are dropped")
+
+	transaction = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.DeleteFile("data.tbl"))
+	require.NoError(t, transaction.Commit())
+	names, err := backend.List()
+	require.NoError(t, err)
+	assert.NotContains(t, names, "data.tbl"+tx.FreeMapSuffix, "the free map is deleted with its file")
+}
@@ -2,9 +2,21 @@ package tx
 
 import (
 	"errors"
+	"fmt"
 	"mydb/file"
+	"mydb/utils"
+	"unicode/utf8"
 )
 
+// ErrMalformedLogRecord is wrapped by every error caused by log record bytes that cannot be decoded.
+var ErrMalformedLogRecord = errors.New("malformed log record")
+
+// LogAppender is where the Write...ToLog functions append the records they encode. It is implemented by
+// log.Manager, and by the log of a single transaction, which adds the transaction's start record.
+type LogAppender interface {
+	Append(logRecord []byte) (int, error)
+}
+
 // LogRecordType is the type of log record.
 type LogRecordType int
 
@@ -19,6 +31,15 @@ const (
 	SetLong
 	SetShort
 	SetDate
+	SetBytes
+	SetTimestamp
+	SetInterval
+	BulkLoad
+	SetFloat
+	DeleteFile
+	Truncate
+	SetDecimal
+	SetUUID
 )
 
 func (t LogRecordType) String() string {
@@ -43,6 +64,24 @@ func (t LogRecordType) String() string {
 		return "SetShort"
 	case SetDate:
 		return "SetDate"
+	case SetBytes:
+		return "SetBytes"
+	case SetTimestamp:
+		return "SetTimestamp"
+	case SetInterval:
+		return "SetInterval"
+	case BulkLoad:
+		return "BulkLoad"
+	case SetFloat:
+		return "SetFloat"
+	case DeleteFile:
+		return "DeleteFile"
+	case Truncate:
+		return "Truncate"
+	case SetDecimal:
+		return "SetDecimal"
+	case SetUUID:
+		return "SetUUID"
 	default:
 		return "Unknown"
 	}
@@ -70,6 +109,24 @@ func FromCode(code int) (LogRecordType, error) {
 		return SetShort, nil
 	case 9:
 		return SetDate, nil
+	case 10:
+		return SetBytes, nil
+	case 11:
+		return SetTimestamp, nil
+	case 12:
+		return SetInterval, nil
+	case 13:
+		return BulkLoad, nil
+	case 14:
+		return SetFloat, nil
+	case 15:
+		return DeleteFile, nil
+	case 16:
+		return Truncate, nil
+	case 17:
+		return SetDecimal, nil
+	case 18:
+		return SetUUID, nil
 	default:
 		return -1, errors.New("unknown LogRecordType code")
 	}
@@ -92,15 +149,20 @@ type LogRecord interface {
 	String() string
 }
 
-// CreateLogRecord interprets the bytes to create the appropriate log record. This method assumes that the first 4 bytes
-// of the byte array represent the log record type.
+// CreateLogRecord interprets the bytes to create the appropriate log record. This method assumes that the first
+// utils.IntSize bytes of the byte array represent the log record type.
+// Every field is checked against the length of bytes, so truncated or corrupt input results in an error
+// wrapping ErrMalformedLogRecord instead of a panic.
 func CreateLogRecord(bytes []byte) (LogRecord, error) {
 	p := file.NewPageFromBytes(bytes)
-	code := p.GetInt(0)
-	recordType, err := FromCode(int(code))
+	code, err := newRecordReader(p, "log record").getInt("type", 0)
 	if err != nil {
 		return nil, err
 	}
+	recordType, err := FromCode(code)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v %d", ErrMalformedLogRecord, err, code)
+	}
 
 	switch recordType {
 	case Checkpoint:
@@ -123,7 +185,100 @@ func CreateLogRecord(bytes []byte) (LogRecord, error) {
 		return NewSetShortRecord(p)
 	case SetDate:
 		return NewSetDateRecord(p)
+	case SetBytes:
+		return NewSetBytesRecord(p)
+	case SetTimestamp:
+		return NewSetTimestampRecord(p)
+	case SetInterval:
+		return NewSetIntervalRecord(p)
+	case BulkLoad:
+		return NewBulkLoadRecord(p)
+	case SetFloat:
+		return NewSetFloatRecord(p)
+	case DeleteFile:
+		return NewDeleteFileRecord(p)
+	case Truncate:
+		return NewTruncateRecord(p)
+	case SetDecimal:
+		return NewSetDecimalRecord(p)
+	case SetUUID:
+		return NewSetUUIDRecord(p)
 	default:
 		return nil, errors.New("unexpected LogRecordType")
 	}
 }
+
+// recordReader reads the fields of an encoded log record, checking that every field lies within the record.
+type recordReader struct {
+	page   *file.Page
+	size   int
+	record string
+}
+
+func newRecordReader(page *file.Page, record string) *recordReader {
+	return &recordReader{page: page, size: len(page.Contents()), record: record}
+}
+
+func (r *recordReader) errorf(field string, offset int, format string, args ...any) error {
+	return fmt.Errorf("%w: %s %s at offset %d: %s", ErrMalformedLogRecord, r.record, field, offset, fmt.Sprintf(format, args...))
+}
+
+// require checks that n bytes starting at offset are part of the record.
+func (r *recordReader) require(field string, offset, n int) error {
+	if offset < 0 || n < 0 || offset > r.size-n {
+		return r.errorf(field, offset, "need %d bytes, record is %d bytes long", n, r.size)
+	}
+	return nil
+}
+
+func (r *recordReader) getInt(field string, offset int) (int, error) {
+	if err := r.require(field, offset, utils.IntSize); err != nil {
+		return 0, err
+	}
+	return r.page.GetInt(offset), nil
+}
+
+// getNonNegativeInt reads an int that must not be negative, such as a block number or an offset.
+func (r *recordReader) getNonNegativeInt(field string, offset int) (int, error) {
+	n, err := r.getInt(field, offset)
+	if err != nil {
+		return 0, err
+	}
+	if n < 0 {
+		return 0, r.errorf(field, offset, "negative value %d", n)
+	}
+	return n, nil
+}
+
+func (r *recordReader) getString(field string, offset int) (string, error) {
+	length, err := r.getInt(field+" length", offset)
+	if err != nil {
+		return "", err
+	}
+	if length < 0 {
+		return "", r.errorf(field, offset, "negative length %d", length)
+	}
+	if err := r.require(field, offset+utils.IntSize, length); err != nil {
+		return "", err
+	}
+	b := r.page.GetBytes(offset)
+	if !utf8.Valid(b) {
+		return "", r.errorf(field, offset, "invalid UTF-8 encoding")
+	}
+	return string(b), nil
+}
+
+// getBytes reads a length-prefixed byte slice.
+func (r *recordReader) getBytes(field string, offset int) ([]byte, error) {
+	length, err := r.getInt(field+" length", offset)
+	if err != nil {
+		return nil, err
+	}
+	if length < 0 {
+		return nil, r.errorf(field, offset, "negative length %d", length)
+	}
+	if err := r.require(field, offset+utils.IntSize, length); err != nil {
+		return nil, err
+	}
+	return r.page.GetBytes(offset), nil
+}
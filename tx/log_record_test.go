@@ -0,0 +1,568 @@
+package tx_test
+
+import (
+	"fmt"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"mydb/utils"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// encodedLogRecords writes one record of every type to a fresh log and returns their bytes in the order written.
+func encodedLogRecords(tb testing.TB) [][]byte {
+	tb.Helper()
+	fm, err := file.NewManager(tb.TempDir(), 400)
+	require.NoError(tb, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(tb, err)
+
+	block := file.NewBlockId("data.tbl", 3)
+	writes := []func() (int, error){
+		func() (int, error) { return tx.WriteStartToLog(lm, 7) },
+		func() (int, error) { return tx.WriteSetIntToLog(lm, 7, block, 8, 42) },
+		func() (int, error) { return tx.WriteSetStringToLog(lm, 7, block, 16, "héllo") },
+		func() (int, error) { return tx.WriteSetBoolToLog(lm, 7, block, 24, true) },
+		func() (int, error) { return tx.WriteSetLongToLog(lm, 7, block, 32, -1<<40) },
+		func() (int, error) { return tx.WriteSetShortToLog(lm, 7, block, 40, -12) },
+		func() (int, error) { return tx.WriteSetDateToLog(lm, 7, block, 48, time.Unix(1700000000, 0)) },
+		func() (int, error) { return tx.WriteSetBytesToLog(lm, 7, block, 56, []byte{1, 2}, []byte{3, 4}) },
+		func() (int, error) {
+			return tx.WriteSetTimestampToLog(lm, 7, block, 64, time.Unix(1700000000, 123456789))
+		},
+		func() (int, error) {
+			return tx.WriteSetIntervalToLog(lm, 7, block, 80, file.Interval{Months: 1, Days: -2, Duration: time.Second})
+		},
+		func() (int, error) { return tx.WriteBulkLoadToLog(lm, 7, "data.tbl", 5) },
+		func() (int, error) { return tx.WriteSetFloatToLog(lm, 7, block, 96, -2.5) },
+		func() (int, error) { return tx.WriteDeleteFileToLog(lm, 7, "old.tbl") },
+		func() (int, error) { return tx.WriteTruncateToLog(lm, 7, "data.tbl", 2) },
+		func() (int, error) {
+			return tx.WriteSetDecimalToLog(lm, 7, block, 104, file.Decimal{Unscaled: -1250, Precision: 10, Scale: 2})
+		},
+		func() (int, error) {
+			return tx.WriteSetUUIDToLog(lm, 7, block, 120, file.UUID{0x12, 0x3e, 15: 0xff})
+		},
+		func() (int, error) { return tx.WriteCommitToLog(lm, 7) },
+		func() (int, error) { return tx.WriteRollbackToLog(lm, 8) },
+		func() (int, error) { return tx.WriteCheckpointToLog(lm) },
+	}
+	for _, write := range writes {
+		_, err := write()
+		require.NoError(tb, err)
+	}
+
+	iter, err := lm.Iterator()
+	require.NoError(tb, err)
+	records := make([][]byte, len(writes))
+	for i := len(records) - 1; i >= 0; i-- {
+		records[i], err = iter.Next()
+		require.NoError(tb, err)
+	}
+	return records
+}
+
+func TestCreateLogRecord(t *testing.T) {
+	records := encodedLogRecords(t)
+
+	expected := []string{
+		"<START 7>",
+		"<SETINT 7 [file data.tbl, block 3] 8 42>",
+		"<SETSTRING 7 [file data.tbl, block 3] 16 héllo>",
+		"<SETBOOL 7 [file data.tbl, block 3] 24 true>",
+		"<SETLONG 7 [file data.tbl, block 3] 32 -1099511627776>",
+		"<SETSHORT 7 [file data.tbl, block 3] 40 -12>",
+		"<SETDATE 7 [file data.tbl, block 3] 48 " + time.Unix(1700000000, 0).String() + ">",
+		"<SETBYTES 7 [file data.tbl, block 3] 56 0102 0304>",
+		"<SETTIMESTAMP 7 [file data.tbl, block 3] 64 2023-11-14T22:13:20.123456789Z>",
+		"<SETINTERVAL 7 [file data.tbl, block 3] 80 1 months -2 days 1s>",
+		"<BULKLOAD 7 data.tbl 5>",
+		"<SETFLOAT 7 [file data.tbl, block 3] 96 -2.5>",
+		"<DELETEFILE 7 old.tbl>",
+		"<TRUNCATE 7 data.tbl 2>",
+		"<SETDECIMAL 7 [file data.tbl, block 3] 104 -12.50>",
+		"<SETUUID 7 [file data.tbl, block 3] 120 123e0000-0000-0000-0000-0000000000ff>",
+		"<COMMIT 7>",
+		"<ROLLBACK 8>",
+		"<CHECKPOINT>",
+	}
+	for i, bytes := range records {
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		assert.Equal(t, expected[i], record.String())
+	}
+
+	t.Run("truncated records", func(t *testing.T) {
+		// Cutting into a field is an error. Cutting only the padding that follows a string value is harmless.
+		for i, bytes := range records {
+			for n := 0; n < len(bytes); n++ {
+				record, err := tx.CreateLogRecord(bytes[:n])
+				if err != nil {
+					assert.ErrorIs(t, err, tx.ErrMalformedLogRecord, "record %d truncated to %d bytes", i, n)
+				} else {
+					assert.Equal(t, expected[i], record.String(), "record %d truncated to %d bytes", i, n)
+				}
+			}
+		}
+		_, err := tx.CreateLogRecord([]byte{0, 0, 0})
+		assert.ErrorIs(t, err, tx.ErrMalformedLogRecord)
+	})
+
+	t.Run("unknown type", func(t *testing.T) {
+		bytes := append([]byte(nil), records[0]...)
+		file.NewPageFromBytes(bytes).SetInt(0, 1000)
+		_, err := tx.CreateLogRecord(bytes)
+		assert.ErrorIs(t, err, tx.ErrMalformedLogRecord)
+		assert.ErrorContains(t, err, "unknown LogRecordType")
+	})
+
+	t.Run("oversized string length", func(t *testing.T) {
+		bytes := append([]byte(nil), records[2]...)
+		file.NewPageFromBytes(bytes).SetInt(2*utils.IntSize, 1<<30)
+		_, err := tx.CreateLogRecord(bytes)
+		assert.ErrorIs(t, err, tx.ErrMalformedLogRecord)
+	})
+}
+
+// FuzzCreateLogRecord checks that no input makes CreateLogRecord panic. The seed corpus holds a record of
+// every type, every truncation of it, and every single-byte corruption of it.
+func FuzzCreateLogRecord(f *testing.F) {
+	for _, record := range encodedLogRecords(f) {
+		f.Add(record)
+		for n := 0; n < len(record); n++ {
+			f.Add(record[:n])
+		}
+		for i := range record {
+			mutated := append([]byte(nil), record...)
+			mutated[i] ^= 0xFF
+			f.Add(mutated)
+		}
+	}
+
+	f.Fuzz(func(t *testing.T, bytes []byte) {
+		record, err := tx.CreateLogRecord(bytes)
+		if err != nil {
+			assert.ErrorIs(t, err, tx.ErrMalformedLogRecord)
+			return
+		}
+		_ = record.String()
+	})
+}
+
+func TestSetBytes(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	block := file.NewBlockId("testfile", 0)
+
+	setup := tx.NewTransaction(fm, lm, bm, lt)
+	_, err = setup.Append("testfile")
+	require.NoError(t, err)
+	require.NoError(t, setup.Pin(block))
+	original := []byte("0123456789abcdefghij")
+	require.NoError(t, setup.SetBytes(block, 10, original, false))
+	require.NoError(t, setup.Commit())
+
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.Pin(block))
+	require.NoError(t, transaction.SetBytes(block, 10, []byte("0123XY6789abcdefgZij"), true))
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	bytes, err := iter.Next()
+	require.NoError(t, err)
+	record, err := tx.CreateLogRecord(bytes)
+	require.NoError(t, err)
+	assert.Equal(t, fmt.Sprintf("<SETBYTES %d [file testfile, block 0] 14 %x %x>",
+		transaction.TxNum(), "456789abcdefgh", "XY6789abcdefgZ"), record.String(), "only the changed range is logged")
+
+	require.NoError(t, transaction.Rollback())
+
+	check := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, check.Pin(block))
+	value, err := check.GetBytes(block, 10, len(original))
+	require.NoError(t, err)
+	assert.Equal(t, original, value)
+	assert.Error(t, check.SetBytes(block, 390, original, true), "range past the end of the block")
+	require.NoError(t, check.Commit())
+}
+
+func TestSetOutsideBlock(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	block, err := fm.Append("testfile")
+	require.NoError(t, err)
+
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.Pin(block))
+	for name, set := range map[string]func(offset int) error{
+		"float": func(offset int) error { return transaction.SetFloat(block, offset, 1.5, true) },
+		"timestamp": func(offset int) error {
+			return transaction.SetTimestamp(block, offset, time.Unix(1700000000, 0), true)
+		},
+		"interval": func(offset int) error {
+			return transaction.SetInterval(block, offset, file.Interval{Days: 1}, true)
+		},
+		"decimal": func(offset int) error {
+			return transaction.SetDecimal(block, offset, file.Decimal{Unscaled: 1, Precision: 1}, true)
+		},
+		"uuid": func(offset int) error { return transaction.SetUUID(block, offset, file.UUID{1}, true) },
+	} {
+		assert.ErrorContains(t, set(395), "outside block", name)
+		assert.ErrorContains(t, set(-1), "outside block", name)
+	}
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	assert.False(t, iter.HasNext(), "nothing is logged for a value that does not fit")
+	require.NoError(t, transaction.Rollback())
+}
+
+func TestSetBytesLargeChange(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	setup := tx.NewTransaction(fm, lm, bm, lt)
+	block, err := setup.Append("testfile")
+	require.NoError(t, err)
+	require.NoError(t, setup.Commit())
+
+	// Both the old and the new contents of the whole block cannot fit into one log block.
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, transaction.Pin(block))
+	changed := make([]byte, 400)
+	for i := range changed {
+		changed[i] = byte(i%255 + 1)
+	}
+	require.NoError(t, transaction.SetBytes(block, 0, changed, true))
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	records := 0
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		if record.Op() == tx.SetBytes {
+			records++
+		}
+	}
+	assert.Equal(t, 3, records, "the change is split across several records")
+
+	require.NoError(t, transaction.Rollback())
+	check := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, check.Pin(block))
+	value, err := check.GetBytes(block, 0, 400)
+	require.NoError(t, err)
+	assert.Equal(t, make([]byte, 400), value)
+	require.NoError(t, check.Commit())
+}
+
+func TestTransactionLog(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	block := file.NewBlockId("testfile", 0)
+	_, err = fm.Append("testfile")
+	require.NoError(t, err)
+
+	writer := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetInt(block, 0, 1, true))
+	require.NoError(t, writer.SetInt(block, 8, 2, true))
+	require.NoError(t, writer.Commit())
+	reader := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, reader.Rollback())
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	var records []string
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		records = append([]string{record.String()}, records...)
+	}
+	assert.Equal(t, []string{
+		fmt.Sprintf("<START %d>", writer.TxNum()),
+		fmt.Sprintf("<SETINT %d [file testfile, block 0] 0 0>", writer.TxNum()),
+		fmt.Sprintf("<SETINT %d [file testfile, block 0] 8 0>", writer.TxNum()),
+		fmt.Sprintf("<COMMIT %d>", writer.TxNum()),
+		fmt.Sprintf("<START %d>", reader.TxNum()),
+		fmt.Sprintf("<ROLLBACK %d>", reader.TxNum()),
+	}, records)
+}
+
+func TestRollbackAfterRestart(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	block, err := fm.Append("testfile")
+	require.NoError(t, err)
+
+	tx.ResetTxNumbers()
+	writer := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetInt(block, 0, 42, true))
+	require.NoError(t, writer.Commit())
+
+	// A transaction of the next run that only reads takes the same number and logs nothing to roll back.
+	tx.ResetTxNumbers()
+	reader := tx.NewTransaction(fm, lm, bm, lt)
+	require.Equal(t, writer.TxNum(), reader.TxNum())
+	require.NoError(t, reader.Pin(block))
+	_, err = reader.GetInt(block, 0)
+	require.NoError(t, err)
+	require.NoError(t, reader.Rollback())
+
+	check := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, check.Pin(block))
+	value, err := check.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, value, "the committed change of the previous run must survive")
+	require.NoError(t, check.Commit())
+
+	tx.ResetTxNumbers()
+	require.NoError(t, tx.ResumeTxNumbers(lm))
+	assert.Greater(t, tx.NewTransaction(fm, lm, bm, lt).TxNum(), check.TxNum())
+}
+
+func TestTempBlocksAreNotLogged(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	transaction := tx.NewTransaction(fm, lm, bm, lt)
+	block, err := transaction.Append("temp1")
+	require.NoError(t, err)
+	require.NoError(t, transaction.Pin(block))
+	require.NoError(t, transaction.SetInt(block, 0, 42, true))
+	require.NoError(t, transaction.SetString(block, 16, "scratch", true))
+	require.NoError(t, transaction.SetBytes(block, 40, []byte{1, 2, 3}, true))
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	assert.False(t, iter.HasNext(), "writes to temporary blocks must not be logged")
+
+	value, err := transaction.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 42, value)
+	require.NoError(t, transaction.Commit())
+}
+
+func TestRecoveryProgress(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	_, err = fm.Append("testfile")
+	require.NoError(t, err)
+
+	// Transaction 100 committed, transaction 101 was left unfinished with two changes
+	block := file.NewBlockId("testfile", 0)
+	writes := []func() (int, error){
+		func() (int, error) { return tx.WriteStartToLog(lm, 100) },
+		func() (int, error) { return tx.WriteSetIntToLog(lm, 100, block, 0, 0) },
+		func() (int, error) { return tx.WriteCommitToLog(lm, 100) },
+		func() (int, error) { return tx.WriteStartToLog(lm, 101) },
+		func() (int, error) { return tx.WriteSetIntToLog(lm, 101, block, 0, 0) },
+		func() (int, error) { return tx.WriteSetStringToLog(lm, 101, block, 8, "") },
+	}
+	for _, write := range writes {
+		_, err := write()
+		require.NoError(t, err)
+	}
+
+	var last tx.RecoveryProgress
+	recovery := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, recovery.RecoverWithProgress(func(p tx.RecoveryProgress) { last = p }))
+	require.NoError(t, recovery.Commit())
+
+	assert.Equal(t, tx.RecoveryDone, last.Phase)
+	assert.Equal(t, 6, last.RecordsScanned)
+	assert.Equal(t, 2, last.RecordsUndone, "start records are not counted as undone")
+	assert.Equal(t, 1, last.TransactionsUndone)
+}
+
+func TestBulkLoad(t *testing.T) {
+	backend := file.NewMemoryBackend()
+	fm, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	load := func(transaction *tx.Transaction, values ...int) {
+		loader, err := transaction.BulkLoad("bulk.tbl")
+		require.NoError(t, err)
+		for _, value := range values {
+			page := file.NewPage(400)
+			page.SetInt(0, value)
+			_, err := loader.Append(page)
+			require.NoError(t, err)
+		}
+	}
+	values := func(fm *file.Manager, lm *log.Manager, bm *buffer.Manager, lt *concurrency.LockTable) []int {
+		reader := tx.NewTransaction(fm, lm, bm, lt)
+		defer reader.Commit()
+		size, err := reader.Size("bulk.tbl")
+		require.NoError(t, err)
+		var values []int
+		for n := 0; n < size; n++ {
+			block := file.NewBlockId("bulk.tbl", n)
+			require.NoError(t, reader.Pin(block))
+			value, err := reader.GetInt(block, 0)
+			require.NoError(t, err)
+			values = append(values, value)
+		}
+		return values
+	}
+
+	committed := tx.NewTransaction(fm, lm, bm, lt)
+	load(committed, 1, 2, 3)
+	require.NoError(t, committed.Commit())
+	assert.Equal(t, []int{1, 2, 3}, values(fm, lm, bm, lt))
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	var ops []tx.LogRecordType
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		if op := record.Op(); op != tx.Start && op != tx.Commit {
+			ops = append(ops, op)
+		}
+	}
+	assert.Equal(t, []tx.LogRecordType{tx.BulkLoad}, ops, "the loaded blocks are not logged")
+
+	rolledBack := tx.NewTransaction(fm, lm, bm, lt)
+	load(rolledBack, 4, 5)
+	require.NoError(t, rolledBack.Rollback())
+	assert.Equal(t, []int{1, 2, 3, 0, 0}, values(fm, lm, bm, lt), "rolled back blocks are zeroed")
+
+	// A load interrupted by a crash is undone by recovery.
+	unfinished := tx.NewTransaction(fm, lm, bm, lt)
+	load(unfinished, 6)
+	fm2, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm2, err := log.NewManager(fm2, "logfile")
+	require.NoError(t, err)
+	bm2 := buffer.NewManager(fm2, lm2, 8)
+	lt2 := concurrency.NewLockTable()
+	recovery := tx.NewTransaction(fm2, lm2, bm2, lt2)
+	require.NoError(t, recovery.Recover())
+	require.NoError(t, recovery.Commit())
+	assert.Equal(t, []int{1, 2, 3, 0, 0, 0}, values(fm2, lm2, bm2, lt2))
+}
+
+func TestDeleteFileAndTruncate(t *testing.T) {
+	backend := file.NewMemoryBackend()
+	fm, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	fill := func(filename string, values ...int) {
+		writer := tx.NewTransaction(fm, lm, bm, lt)
+		for _, value := range values {
+			block, err := writer.Append(filename)
+			require.NoError(t, err)
+			require.NoError(t, writer.Pin(block))
+			require.NoError(t, writer.SetInt(block, 0, value, true))
+		}
+		require.NoError(t, writer.Commit())
+	}
+	fill("a.tbl", 1, 2, 3)
+	fill("b.tbl", 4)
+	files := func() []string {
+		names, err := backend.List()
+		require.NoError(t, err)
+		return names
+	}
+
+	rolledBack := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, rolledBack.DeleteFile("b.tbl"))
+	require.NoError(t, rolledBack.Truncate("a.tbl", 1))
+	assert.Error(t, rolledBack.Truncate("a.tbl", 4), "files cannot grow")
+	require.NoError(t, rolledBack.Rollback())
+	assert.Contains(t, files(), "b.tbl")
+	length, err := fm.Length("a.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 3, length)
+
+	committed := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, committed.DeleteFile("b.tbl"))
+	require.NoError(t, committed.Truncate("a.tbl", 1))
+	require.NoError(t, committed.Commit())
+	assert.NotContains(t, files(), "b.tbl")
+	length, err = fm.Length("a.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 1, length)
+
+	// The cached blocks are gone as well, so a file that grows back reads as zeros.
+	grower := tx.NewTransaction(fm, lm, bm, lt)
+	block, err := grower.Append("a.tbl")
+	require.NoError(t, err)
+	require.NoError(t, grower.Pin(block))
+	value, err := grower.GetInt(block, 0)
+	require.NoError(t, err)
+	assert.Equal(t, 0, value)
+	require.NoError(t, grower.Commit())
+
+	// Recovery completes a deletion the crash interrupted, but leaves a file recreated since alone.
+	fill("c.tbl", 5)
+	fill("d.tbl", 6)
+	_, err = tx.WriteDeleteFileToLog(lm, 1000, "c.tbl")
+	require.NoError(t, err)
+	_, err = tx.WriteDeleteFileToLog(lm, 1000, "d.tbl")
+	require.NoError(t, err)
+	_, err = tx.WriteCommitToLog(lm, 1000)
+	require.NoError(t, err)
+	fill("d.tbl", 7)
+	fm2, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm2, err := log.NewManager(fm2, "logfile")
+	require.NoError(t, err)
+	recovery := tx.NewTransaction(fm2, lm2, buffer.NewManager(fm2, lm2, 8), concurrency.NewLockTable())
+	require.NoError(t, recovery.Recover())
+	require.NoError(t, recovery.Commit())
+	assert.NotContains(t, files(), "c.tbl")
+	length, err = fm2.Length("d.tbl")
+	require.NoError(t, err)
+	assert.Equal(t, 2, length)
+}
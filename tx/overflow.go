@@ -0,0 +1,174 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+)
+
+// OverflowSuffix is appended to the name of a file to name its overflow file, which holds the chains of blocks
+// storing the large values of the file, see SetLargeBytes.
+const OverflowSuffix = ".overflow"
+
+// LargeBytesSize is the number of bytes a large value takes in the block it is stored in: its length and the number
+// of the first block of its chain in the overflow file.
+const LargeBytesSize = 2 * utils.IntSize
+
+// Layout of an overflow block: a page header of type file.PageTypeOverflow, the number of the next block of the
+// chain, or -1 in the last block, and then as much of the value as fits.
+const (
+	overflowNextPos = file.PageHeaderSize
+	overflowDataPos = overflowNextPos + utils.IntSize
+)
+
+// GetLargeBytes returns the value stored with SetLargeBytes at the specified offset of the specified block.
+// The method first obtains an SLock on the block, then on each block of the chain holding the value.
+func (tx *Transaction) GetLargeBytes(block *file.BlockId, offset int) ([]byte, error) {
+	length, next, err := tx.largeBytesRef(block, offset)
+	if err != nil {
+		return nil, err
+	}
+	val := make([]byte, 0, length)
+	overflow := block.Filename() + OverflowSuffix
+	for len(val) < length {
+		if next < 0 {
+			return nil, fmt.Errorf("chain of the value at offset %d of block %s ends after %d of %d bytes", offset,
+				block, len(val), length)
+		}
+		chunk, after, err := tx.readOverflowBlock(file.NewBlockId(overflow, next), length-len(val))
+		if err != nil {
+			return nil, err
+		}
+		val = append(val, chunk...)
+		next = after
+	}
+	return val, nil
+}
+
+// SetLargeBytes stores val at the specified offset of the specified block, however large it is. The block only holds
+// LargeBytesSize bytes; the value itself goes to a chain of blocks appended to the overflow file of the block's file,
+// named with OverflowSuffix. The chain of the value previously stored at the offset, if any, is freed with
+// FreeBlock, so its blocks are reused by later chains. Storing an empty value frees the chain and stores nothing
+// else: a slot holding a large value must be set to empty before it is discarded, or the chain is never reused.
+// The reference in the block and the contents of the chain are logged if logIt is set, like SetBytes; freeing and
+// appending blocks is always logged.
+func (tx *Transaction) SetLargeBytes(block *file.BlockId, offset int, val []byte, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+	length, next, err := tx.largeBytesRef(block, offset)
+	if err != nil {
+		return err
+	}
+	overflow := block.Filename() + OverflowSuffix
+	if err := tx.freeOverflowChain(overflow, length, next); err != nil {
+		return err
+	}
+
+	first, err := tx.writeOverflowChain(overflow, val, logIt)
+	if err != nil {
+		return err
+	}
+	ref := file.NewPage(LargeBytesSize)
+	ref.SetInt(0, len(val))
+	ref.SetInt(utils.IntSize, first)
+	return tx.SetBytes(block, offset, ref.Contents(), logIt)
+}
+
+// largeBytesRef returns the length of the large value at the specified offset of the block, and the number of the
+// first block of its chain.
+func (tx *Transaction) largeBytesRef(block *file.BlockId, offset int) (length, first int, err error) {
+	ref, err := tx.GetBytes(block, offset, LargeBytesSize)
+	if err != nil {
+		return 0, 0, err
+	}
+	page := file.NewPageFromBytes(ref)
+	length, first = page.GetInt(0), page.GetInt(utils.IntSize)
+	if length < 0 {
+		return 0, 0, fmt.Errorf("invalid length %d of the value at offset %d of block %s", length, offset, block)
+	}
+	return length, first, nil
+}
+
+// readOverflowBlock returns at most limit bytes of the value held by the overflow block, and the number of the next
+// block of the chain.
+func (tx *Transaction) readOverflowBlock(block *file.BlockId, limit int) ([]byte, int, error) {
+	if err := tx.PinWithType(block, file.PageTypeOverflow); err != nil {
+		return nil, 0, err
+	}
+	defer tx.Unpin(block)
+	next, err := tx.GetInt(block, overflowNextPos)
+	if err != nil {
+		return nil, 0, err
+	}
+	chunk, err := tx.GetBytes(block, overflowDataPos, min(limit, tx.overflowCapacity()))
+	return chunk, next, err
+}
+
+// writeOverflowChain writes val to a chain of blocks appended to the overflow file, and returns the number of the
+// first block, or -1 if val is empty.
+func (tx *Transaction) writeOverflowChain(overflow string, val []byte, logIt bool) (int, error) {
+	capacity := tx.overflowCapacity()
+	blocks := make([]*file.BlockId, (len(val)+capacity-1)/capacity)
+	for i := range blocks {
+		var err error
+		if blocks[i], err = tx.Append(overflow); err != nil {
+			return -1, err
+		}
+	}
+	for i, block := range blocks {
+		next := -1
+		if i+1 < len(blocks) {
+			next = blocks[i+1].Number()
+		}
+		if err := tx.writeOverflowBlock(block, next, val[i*capacity:min((i+1)*capacity, len(val))], logIt); err != nil {
+			return -1, err
+		}
+	}
+	if len(blocks) == 0 {
+		return -1, nil
+	}
+	return blocks[0].Number(), nil
+}
+
+// writeOverflowBlock writes the header, the number of the next block and chunk to a block of an overflow chain.
+func (tx *Transaction) writeOverflowBlock(block *file.BlockId, next int, chunk []byte, logIt bool) error {
+	if err := tx.Pin(block); err != nil {
+		return err
+	}
+	defer tx.Unpin(block)
+	if err := tx.SetPageType(block, file.PageTypeOverflow, logIt); err != nil {
+		return err
+	}
+	if err := tx.SetInt(block, overflowNextPos, next, logIt); err != nil {
+		return err
+	}
+	return tx.SetBytes(block, overflowDataPos, chunk, logIt)
+}
+
+// freeOverflowChain frees the blocks of the chain of a value of the given length starting at block first.
+func (tx *Transaction) freeOverflowChain(overflow string, length, first int) error {
+	capacity := tx.overflowCapacity()
+	for next := first; length > 0; length -= capacity {
+		if next < 0 {
+			return fmt.Errorf("chain starting at block %d of %s is too short", first, overflow)
+		}
+		_, after, err := tx.readOverflowBlock(file.NewBlockId(overflow, next), 0)
+		if err != nil {
+			return err
+		}
+		if err := tx.FreeBlock(file.NewBlockId(overflow, next)); err != nil {
+			return err
+		}
+		next = after
+	}
+	return nil
+}
+
+// overflowCapacity returns the number of bytes of a value an overflow block holds.
+func (tx *Transaction) overflowCapacity() int {
+	return tx.fileManager.BlockSize() - overflowDataPos
+}
@@ -0,0 +1,93 @@
+package tx_test
+
+import (
+	"bytes"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLargeBytes(t *testing.T) {
+	backend := file.NewMemoryBackend()
+	fm, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	large := bytes.Repeat([]byte("0123456789"), 140)
+	setup := tx.NewTransaction(fm, lm, bm, lt)
+	block, err := setup.Append("data.tbl")
+	require.NoError(t, err)
+	require.NoError(t, setup.Pin(block))
+	require.NoError(t, setup.SetInt(block, 0, 7, true))
+	require.NoError(t, setup.SetLargeBytes(block, 8, large, true))
+	require.NoError(t, setup.SetInt(block, 8+tx.LargeBytesSize, 9, true))
+	assert.Error(t, setup.SetString(block, 100, string(large), true), "the string does not fit in the block")
+	require.NoError(t, setup.Commit())
+
+	overflowBlocks := func() int {
+		length, err := fm.Length("data.tbl" + tx.OverflowSuffix)
+		require.NoError(t, err)
+		return length
+	}
+	assert.Equal(t, 4, overflowBlocks())
+
+	read := func() []byte {
+		reader := tx.NewTransaction(fm, lm, bm, lt)
+		defer reader.Commit()
+		require.NoError(t, reader.Pin(block))
+		val, err := reader.GetLargeBytes(block, 8)
+		require.NoError(t, err)
+		for offset, want := range map[int]int{0: 7, 8 + tx.LargeBytesSize: 9} {
+			got, err := reader.GetInt(block, offset)
+			require.NoError(t, err)
+			assert.Equal(t, want, got, "the values around the large value are intact")
+		}
+		return val
+	}
+	assert.Equal(t, large, read())
+
+	// Rolling back a replacement restores the old value and its chain.
+	writer := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetLargeBytes(block, 8, []byte("short"), true))
+	got, err := writer.GetLargeBytes(block, 8)
+	require.NoError(t, err)
+	assert.Equal(t, []byte("short"), got)
+	require.NoError(t, writer.Rollback())
+	assert.Equal(t, large, read())
+
+	// Once a replacement commits, the blocks of the old chain are reused.
+	writer = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetLargeBytes(block, 8, []byte("short"), true))
+	require.NoError(t, writer.Commit())
+	assert.Equal(t, []byte("short"), read())
+	writer = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetLargeBytes(block, 8, large[:500], true))
+	require.NoError(t, writer.Commit())
+	assert.Equal(t, large[:500], read())
+	assert.Equal(t, 4, overflowBlocks(), "the file did not grow")
+
+	writer = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(block))
+	require.NoError(t, writer.SetLargeBytes(block, 8, nil, true))
+	require.NoError(t, writer.Commit())
+	assert.Empty(t, read())
+
+	writer = tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.DeleteFile("data.tbl"))
+	require.NoError(t, writer.Commit())
+	names, err := backend.List()
+	require.NoError(t, err)
+	assert.NotContains(t, names, "data.tbl"+tx.OverflowSuffix, "the overflow file is deleted with its file")
+}
@@ -0,0 +1,44 @@
+package tx_test
+
+import (
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPageType(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	_, err = lm.Append([]byte("record"))
+	require.NoError(t, err)
+	require.NoError(t, lm.Flush(0))
+	lt := concurrency.NewLockTable()
+
+	writer := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), lt)
+	block, err := writer.Append("index.idx")
+	require.NoError(t, err)
+	require.NoError(t, writer.PinWithType(block, file.PageTypeIndex), "a new block has no header yet")
+	require.NoError(t, writer.SetPageType(block, file.PageTypeIndex, true))
+	assert.Error(t, writer.SetPageType(block, file.PageTypeAny, true))
+	require.NoError(t, writer.Commit())
+
+	// A fresh buffer pool reads the blocks from disk again.
+	reader := tx.NewTransaction(fm, lm, buffer.NewManager(fm, lm, 8), lt)
+	require.NoError(t, reader.PinWithType(block, file.PageTypeIndex))
+	pageType, err := reader.GetPageType(block)
+	require.NoError(t, err)
+	assert.Equal(t, file.PageTypeIndex, pageType)
+
+	assert.ErrorIs(t, reader.PinWithType(file.NewBlockId("logfile", 0), file.PageTypeIndex), file.ErrPageHeader,
+		"a log block is not an index block")
+	require.NoError(t, reader.Pin(file.NewBlockId("logfile", 0)), "untyped pins are not checked")
+	require.NoError(t, reader.Commit())
+}
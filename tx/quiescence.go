@@ -0,0 +1,147 @@
+package tx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mydb/log"
+	"sort"
+	"sync"
+	"time"
+)
+
+// ErrActiveTransactions is returned by Quiesce when transactions are still unfinished after the wait.
+var ErrActiveTransactions = errors.New("transactions are active")
+
+// quiescence tracks, for every log, the transactions that have written to it and are neither committed nor rolled
+// back. Every transaction writing to a log is counted, whoever created it. A transaction that has not written
+// anything yet is not counted: recovery only ever sees its records if they come after the checkpoint.
+// Each active transaction is recorded with the time of its first record. Logs without active transactions or a
+// running checkpoint have no entry.
+var quiescence = struct {
+	mu        sync.Mutex
+	changed   *sync.Cond
+	active    map[*log.Manager]map[int]time.Time
+	quiescing map[*log.Manager]bool
+}{
+	active:    make(map[*log.Manager]map[int]time.Time),
+	quiescing: make(map[*log.Manager]bool),
+}
+
+func init() {
+	quiescence.changed = sync.NewCond(&quiescence.mu)
+}
+
+// beginLogging counts a transaction as active in logManager before it writes its first record. It waits while a
+// checkpoint of the log is in progress.
+func beginLogging(logManager *log.Manager, txNum int) {
+	quiescence.mu.Lock()
+	defer quiescence.mu.Unlock()
+	for quiescence.quiescing[logManager] {
+		quiescence.changed.Wait()
+	}
+	if quiescence.active[logManager] == nil {
+		quiescence.active[logManager] = make(map[int]time.Time)
+	}
+	quiescence.active[logManager][txNum] = time.Now()
+}
+
+// endLogging stops counting a transaction that has finished, or failed to write its first record.
+func endLogging(logManager *log.Manager, txNum int) {
+	quiescence.mu.Lock()
+	defer quiescence.mu.Unlock()
+	delete(quiescence.active[logManager], txNum)
+	if len(quiescence.active[logManager]) == 0 {
+		delete(quiescence.active, logManager)
+	}
+	quiescence.changed.Broadcast()
+}
+
+// ActiveTransactions returns the number of transactions that have written to logManager and are neither committed
+// nor rolled back.
+func ActiveTransactions(logManager *log.Manager) int {
+	quiescence.mu.Lock()
+	defer quiescence.mu.Unlock()
+	return len(quiescence.active[logManager])
+}
+
+// ActiveTransaction describes a transaction that has written to a log and is neither committed nor rolled back.
+type ActiveTransaction struct {
+	TxNum int
+	// Since is when the transaction wrote its first record.
+	Since time.Time
+}
+
+// ListActiveTransactions returns the transactions counted by ActiveTransactions, ordered by transaction number.
+func ListActiveTransactions(logManager *log.Manager) []ActiveTransaction {
+	quiescence.mu.Lock()
+	defer quiescence.mu.Unlock()
+
+	active := make([]ActiveTransaction, 0, len(quiescence.active[logManager]))
+	for txNum, since := range quiescence.active[logManager] {
+		active = append(active, ActiveTransaction{TxNum: txNum, Since: since})
+	}
+	sort.Slice(active, func(i, j int) bool { return active[i].TxNum < active[j].TxNum })
+	return active
+}
+
+// QuiescentCheckpoint writes a checkpoint record to logManager and flushes it. Recovery stops reading the log at the
+// most recent checkpoint, which is only correct if no transaction with records before it is unfinished, so the
+// record is written while the log is quiescent; see Quiesce.
+func QuiescentCheckpoint(logManager *log.Manager, maxWait time.Duration) error {
+	resume, err := Quiesce(logManager, maxWait)
+	if err != nil {
+		return err
+	}
+	defer resume()
+	return writeCheckpoint(logManager)
+}
+
+// Quiesce holds back transactions that are about to write their first record to logManager, and waits up to
+// maxWait for the active ones to finish. Until resume is called, every transaction that wrote to the log is
+// finished, and its changes are on disk because commit and rollback force them there.
+// If transactions are still active after maxWait, Quiesce lets the held back ones proceed and returns
+// ErrActiveTransactions. A transaction that stays active longer than maxWait thus prevents quiescing, and every
+// attempt delays new writers by up to maxWait.
+func Quiesce(logManager *log.Manager, maxWait time.Duration) (resume func(), err error) {
+	quiescence.mu.Lock()
+	defer quiescence.mu.Unlock()
+	for quiescence.quiescing[logManager] {
+		quiescence.changed.Wait()
+	}
+	quiescence.quiescing[logManager] = true
+	resume = func() {
+		quiescence.mu.Lock()
+		defer quiescence.mu.Unlock()
+		delete(quiescence.quiescing, logManager)
+		quiescence.changed.Broadcast()
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), maxWait)
+	defer cancel()
+	stop := context.AfterFunc(ctx, func() {
+		quiescence.mu.Lock()
+		quiescence.changed.Broadcast()
+		quiescence.mu.Unlock()
+	})
+	defer stop()
+
+	for len(quiescence.active[logManager]) > 0 && ctx.Err() == nil {
+		quiescence.changed.Wait()
+	}
+	if active := len(quiescence.active[logManager]); active > 0 {
+		delete(quiescence.quiescing, logManager)
+		quiescence.changed.Broadcast()
+		return nil, fmt.Errorf("%w: %d still running after %v", ErrActiveTransactions, active, maxWait)
+	}
+	return resume, nil
+}
+
+// writeCheckpoint writes a checkpoint record to logManager and flushes it. The log must be quiescent.
+func writeCheckpoint(logManager *log.Manager) error {
+	lsn, err := WriteCheckpointToLog(logManager)
+	if err != nil {
+		return fmt.Errorf("failed to write checkpoint: %v", err)
+	}
+	return logManager.Flush(lsn)
+}
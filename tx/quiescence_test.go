@@ -0,0 +1,72 @@
+package tx_test
+
+import (
+	"fmt"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestQuiescentCheckpoint(t *testing.T) {
+	fm, err := file.NewManagerWithBackend(file.NewMemoryBackend(), 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+	first, err := fm.Append("testfile")
+	require.NoError(t, err)
+	second, err := fm.Append("testfile")
+	require.NoError(t, err)
+
+	active := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, active.Pin(first))
+	require.NoError(t, active.SetInt(first, 0, 1, true))
+	writer := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, writer.Pin(second))
+	assert.Equal(t, 1, tx.ActiveTransactions(lm))
+
+	checkpointed := make(chan error, 1)
+	go func() { checkpointed <- tx.QuiescentCheckpoint(lm, 10*time.Second) }()
+	require.Eventually(t, func() bool { return tx.CheckpointInProgress(lm) }, time.Second, time.Millisecond)
+
+	// The first write of a new transaction waits for the checkpoint, which waits for the active transaction.
+	written := make(chan error, 1)
+	go func() { written <- writer.SetInt(second, 0, 2, true) }()
+	select {
+	case err := <-written:
+		t.Fatalf("first write of a new transaction finished during the checkpoint: %v", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	require.NoError(t, active.Commit())
+	require.NoError(t, <-checkpointed)
+	require.NoError(t, <-written)
+	assert.Equal(t, 1, tx.ActiveTransactions(lm))
+	require.NoError(t, writer.Commit())
+	assert.Equal(t, 0, tx.ActiveTransactions(lm))
+
+	iter, err := lm.Iterator()
+	require.NoError(t, err)
+	var records []string
+	for i := 0; i < 4; i++ {
+		bytes, err := iter.Next()
+		require.NoError(t, err)
+		record, err := tx.CreateLogRecord(bytes)
+		require.NoError(t, err)
+		records = append([]string{record.String()}, records...)
+	}
+	assert.Equal(t, []string{
+		"<CHECKPOINT>",
+		fmt.Sprintf("<START %d>", writer.TxNum()),
+		fmt.Sprintf("<SETINT %d [file testfile, block 1] 0 0>", writer.TxNum()),
+		fmt.Sprintf("<COMMIT %d>", writer.TxNum()),
+	}, records)
+}
@@ -1,9 +1,14 @@
 package tx
 
 import (
+	"fmt"
 	"mydb/buffer"
+	"mydb/file"
 	"mydb/log"
+	"mydb/utils"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // RecoveryManager is responsible for recovering transactions from the log. It provides methods for committing,
@@ -13,35 +18,125 @@ import (
 // Recover recovers uncompleted transactions from the log, and then writes a quiescent checkpoint record to the log, and flushes it.
 type RecoveryManager struct {
 	logManager    *log.Manager
+	txLog         *transactionLog
+	unloggedUndo  *memoryLog
 	bufferManager *buffer.Manager
 	transaction   *Transaction
 	txNum         int
 }
 
+// transactionLog appends the log records of one transaction. The first record is appended in one batch with the
+// transaction's start record, so that the start record is logged only by transactions that log anything, and it
+// always comes right before the transaction's first record. From then until it finishes, the transaction counts
+// as active for QuiescentCheckpoint.
+type transactionLog struct {
+	logManager *log.Manager
+	txNum      int
+	started    bool
+}
+
+// Append appends logRecord to the log, preceded by the start record if it is the transaction's first record.
+func (l *transactionLog) Append(logRecord []byte) (int, error) {
+	if l.started {
+		return l.logManager.Append(logRecord)
+	}
+	beginLogging(l.logManager, l.txNum)
+	lsn, err := l.logManager.AppendBatch([][]byte{startRecordBytes(l.txNum), logRecord})
+	if err != nil {
+		endLogging(l.logManager, l.txNum)
+		return -1, err
+	}
+	l.started = true
+	return lsn, nil
+}
+
+// start appends the transaction's start record on its own, unless it was already appended.
+func (l *transactionLog) start() error {
+	if l.started {
+		return nil
+	}
+	beginLogging(l.logManager, l.txNum)
+	if _, err := l.logManager.Append(startRecordBytes(l.txNum)); err != nil {
+		endLogging(l.logManager, l.txNum)
+		return err
+	}
+	l.started = true
+	return nil
+}
+
+// finish stops counting the transaction as active, once its commit or rollback record is on disk.
+func (l *transactionLog) finish() {
+	if l.started {
+		endLogging(l.logManager, l.txNum)
+		l.started = false
+	}
+}
+
+// memoryLog keeps the undo records of a transaction's changes to unlogged files in memory. Those files are removed
+// by crash recovery, so the records are only needed to roll the transaction back. The transaction still logs its
+// start record, so that it counts as active and a crash before it finishes is detected.
+type memoryLog struct {
+	txLog   *transactionLog
+	records [][]byte
+}
+
+// Append keeps a copy of logRecord. It returns -1, as the record never reaches the log.
+func (l *memoryLog) Append(logRecord []byte) (int, error) {
+	if err := l.txLog.start(); err != nil {
+		return -1, err
+	}
+	l.records = append(l.records, append([]byte(nil), logRecord...))
+	return -1, nil
+}
+
+// logFor returns where the undo records of changes to the named file go: to memory for unlogged files, otherwise to
+// the log.
+func (rm *RecoveryManager) logFor(filename string) LogAppender {
+	if file.IsUnloggedFile(filename) {
+		return rm.unloggedUndo
+	}
+	return rm.txLog
+}
+
 // NewRecoveryManager creates a new RecoveryManager.
 func NewRecoveryManager(tx *Transaction, txNum int, logManager *log.Manager, bufferManager *buffer.Manager) *RecoveryManager {
+	txLog := &transactionLog{logManager: logManager, txNum: txNum}
 	return &RecoveryManager{
 		logManager:    logManager,
+		txLog:         txLog,
+		unloggedUndo:  &memoryLog{txLog: txLog},
 		bufferManager: bufferManager,
 		transaction:   tx,
 		txNum:         txNum,
 	}
 }
 
-// Commit writes a commit record to the log, and flushes it to disk.
+// Commit writes a commit record to the log, and flushes it to disk. The transaction still counts as active for
+// QuiescentCheckpoint until finishLogging is called.
 func (rm *RecoveryManager) Commit() error {
 	// This flushes all the changes to the buffers for this transaction. Internally, it first flushes all the
 	// respective log records, and then the actual buffers to the disk blocks.
-	if err := rm.bufferManager.FlushAll(rm.txNum); err != nil {
+	if err := rm.flushBuffers(); err != nil {
 		return err
 	}
 	// Creates a commit record, and flushes it to the disk.
-	lsn, err := WriteCommitToLog(rm.logManager, rm.txNum)
+	lsn, err := WriteCommitToLog(rm.txLog, rm.txNum)
 	if err != nil {
 		return err
 	}
 	// Flushes the commit log record to disk.
-	return rm.logManager.Flush(lsn)
+	if err := rm.flushLog(lsn); err != nil {
+		return err
+	}
+	rm.unloggedUndo.records = nil
+	return nil
+}
+
+// finishLogging stops counting a committed transaction as active. The files it deleted or truncated only shrink
+// after its commit record is on disk, and recovery redoes that only for commit records after the last checkpoint,
+// so no checkpoint may be written until they have.
+func (rm *RecoveryManager) finishLogging() {
+	rm.txLog.finish()
 }
 
 // Rollback rolls back the transaction, writes a rollback record to the log, and flushes it to the disk.
@@ -49,37 +144,57 @@ func (rm *RecoveryManager) Rollback() error {
 	if err := rm.doRollback(); err != nil {
 		return err
 	}
-	if err := rm.bufferManager.FlushAll(rm.txNum); err != nil {
+	if err := rm.flushBuffers(); err != nil {
 		return err
 	}
-	lsn, err := WriteRollbackToLog(rm.logManager, rm.txNum)
+	lsn, err := WriteRollbackToLog(rm.txLog, rm.txNum)
 	if err != nil {
 		return err
 	}
-	return rm.logManager.Flush(lsn)
+	if err := rm.flushLog(lsn); err != nil {
+		return err
+	}
+	rm.txLog.finish()
+	return nil
 }
 
 // Recover recovers uncompleted transactions from the log,
 // and then writes a quiescent checkpoint record to the log, and flushes it.
-func (rm *RecoveryManager) Recover() error {
-	if err := rm.doRecover(); err != nil {
-		return err
+// Progress is reported to progress, which may be nil, as described for RecoveryProgress.
+func (rm *RecoveryManager) Recover(progress func(RecoveryProgress)) (RecoveryProgress, error) {
+	state := RecoveryProgress{Phase: RecoveryUndo, LogBlocks: rm.logManager.Size()}
+	report := func(phase RecoveryPhase) {
+		state.Phase = phase
+		if progress != nil {
+			progress(state)
+		}
 	}
-	if err := rm.bufferManager.FlushAll(rm.txNum); err != nil {
-		return err
+
+	report(RecoveryUndo)
+	if err := rm.doRecover(&state, func() { report(RecoveryUndo) }); err != nil {
+		return state, err
 	}
+	report(RecoveryFlush)
+	if err := rm.flushBuffers(); err != nil {
+		return state, err
+	}
+	report(RecoveryCheckpoint)
 	lsn, err := WriteCheckpointToLog(rm.logManager)
 	if err != nil {
-		return err
+		return state, err
 	}
-	return rm.logManager.Flush(lsn)
+	if err := rm.flushLog(lsn); err != nil {
+		return state, err
+	}
+	report(RecoveryDone)
+	return state, nil
 }
 
 // SetInt writes a SetInt record to the log and returns its lsn.
 func (rm *RecoveryManager) SetInt(buffer *buffer.Buffer, offset int, newVal int) (int, error) {
 	oldVal := buffer.Contents().GetInt(offset)
 	block := buffer.Block()
-	return WriteSetIntToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return WriteSetIntToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
 }
 
 // SetString writes a SetString record to the log and returns its lsn.
@@ -89,41 +204,159 @@ func (rm *RecoveryManager) SetString(buffer *buffer.Buffer, offset int, newVal s
 		return -1, err
 	}
 	block := buffer.Block()
-	return WriteSetStringToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return WriteSetStringToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
 }
 
 // SetBool writes a SetBool record to the log and returns its lsn.
 func (rm *RecoveryManager) SetBool(buffer *buffer.Buffer, offset int, newVal bool) (int, error) {
 	oldVal := buffer.Contents().GetBool(offset)
 	block := buffer.Block()
-	return WriteSetBoolToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return WriteSetBoolToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
 }
 
 // SetLong writes a SetLong record to the log and returns its lsn.
 func (rm *RecoveryManager) SetLong(buffer *buffer.Buffer, offset int, newVal int64) (int, error) {
 	oldVal := buffer.Contents().GetLong(offset)
 	block := buffer.Block()
-	return WriteSetLongToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return WriteSetLongToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
+}
+
+// SetFloat writes a SetFloat record to the log and returns its lsn.
+func (rm *RecoveryManager) SetFloat(buffer *buffer.Buffer, offset int, newVal float64) (int, error) {
+	oldVal := buffer.Contents().GetFloat(offset)
+	block := buffer.Block()
+	return WriteSetFloatToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
 }
 
 // SetShort writes a SetShort record to the log and returns its lsn.
 func (rm *RecoveryManager) SetShort(buffer *buffer.Buffer, offset int, newVal int16) (int, error) {
 	oldVal := buffer.Contents().GetShort(offset)
 	block := buffer.Block()
-	return WriteSetShortToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return WriteSetShortToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
 }
 
 // SetDate writes a SetDate record to the log and returns its lsn.
 func (rm *RecoveryManager) SetDate(buffer *buffer.Buffer, offset int, newVal time.Time) (int, error) {
 	oldVal := buffer.Contents().GetDate(offset)
 	block := buffer.Block()
-	return WriteSetDateToLog(rm.logManager, rm.txNum, block, offset, oldVal)
+	return WriteSetDateToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
+}
+
+// SetTimestamp writes a SetTimestamp record to the log and returns its lsn.
+func (rm *RecoveryManager) SetTimestamp(buffer *buffer.Buffer, offset int, newVal time.Time) (int, error) {
+	oldVal := buffer.Contents().GetTimestamp(offset)
+	block := buffer.Block()
+	return WriteSetTimestampToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
+}
+
+// SetInterval writes a SetInterval record to the log and returns its lsn.
+func (rm *RecoveryManager) SetInterval(buffer *buffer.Buffer, offset int, newVal file.Interval) (int, error) {
+	oldVal := buffer.Contents().GetInterval(offset)
+	block := buffer.Block()
+	return WriteSetIntervalToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
+}
+
+// SetDecimal writes a SetDecimal record to the log and returns its lsn.
+func (rm *RecoveryManager) SetDecimal(buffer *buffer.Buffer, offset int, newVal file.Decimal) (int, error) {
+	oldVal := buffer.Contents().GetDecimal(offset)
+	block := buffer.Block()
+	return WriteSetDecimalToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
+}
+
+// SetUUID writes a SetUUID record to the log and returns its lsn.
+func (rm *RecoveryManager) SetUUID(buffer *buffer.Buffer, offset int, newVal file.UUID) (int, error) {
+	oldVal := buffer.Contents().GetUUID(offset)
+	block := buffer.Block()
+	return WriteSetUUIDToLog(rm.logFor(block.Filename()), rm.txNum, block, offset, oldVal)
+}
+
+// BulkLoad writes a BulkLoad record to the log and flushes it. The loaded blocks bypass the buffer pool, so nothing
+// else would force the record to disk before them.
+func (rm *RecoveryManager) BulkLoad(fileName string, blocks int) error {
+	lsn, err := WriteBulkLoadToLog(rm.logFor(fileName), rm.txNum, fileName, blocks)
+	if err != nil || file.IsUnloggedFile(fileName) {
+		return err
+	}
+	return rm.flushLog(lsn)
+}
+
+// DeleteFile writes a DeleteFile record to the log. It is forced to disk with the commit record, before the file is
+// removed.
+func (rm *RecoveryManager) DeleteFile(fileName string) error {
+	_, err := WriteDeleteFileToLog(rm.logFor(fileName), rm.txNum, fileName)
+	return err
+}
+
+// Truncate writes a Truncate record to the log, see DeleteFile.
+func (rm *RecoveryManager) Truncate(fileName string, blocks int) error {
+	_, err := WriteTruncateToLog(rm.logFor(fileName), rm.txNum, fileName, blocks)
+	return err
+}
+
+// SetBytes writes SetBytes records covering only the bytes that change to the log and returns the lsn of the last.
+// No record is written if nothing changes, in which case the returned lsn is -1.
+func (rm *RecoveryManager) SetBytes(buffer *buffer.Buffer, offset int, newVal []byte) (int, error) {
+	oldVal := buffer.Contents().GetRawBytes(offset, len(newVal))
+	start, end := changedRange(oldVal, newVal)
+	if start == end {
+		return -1, nil
+	}
+	block := buffer.Block()
+	chunk := maxSetBytesChunk(rm.transaction.fileManager.BlockSize(), block.Filename())
+	if chunk < 1 {
+		return -1, fmt.Errorf("file name %q is too long to log changes to block %s", block.Filename(), block)
+	}
+	// A change too large for one log block is logged as several records, each covering part of the range.
+	lsn := -1
+	for ; start < end; start += chunk {
+		stop := min(start+chunk, end)
+		var err error
+		lsn, err = WriteSetBytesToLog(rm.logFor(block.Filename()), rm.txNum, block, offset+start, oldVal[start:stop],
+			newVal[start:stop])
+		if err != nil {
+			return -1, err
+		}
+	}
+	return lsn, nil
+}
+
+// flushBuffers writes the buffers modified by the transaction to disk.
+func (rm *RecoveryManager) flushBuffers() error {
+	_, span := rm.transaction.startSpan("buffer.flush")
+	err := rm.bufferManager.FlushAll(rm.txNum)
+	utils.EndSpan(span, err)
+	return err
+}
+
+// flushLog forces the log to disk up to and including the record with the specified LSN.
+func (rm *RecoveryManager) flushLog(lsn int) error {
+	_, span := rm.transaction.startSpan("log.flush", attribute.Int("mydb.log.lsn", lsn))
+	err := rm.logManager.Flush(lsn)
+	utils.EndSpan(span, err)
+	return err
 }
 
 // doRollback rolls back the transaction,
 // by iterating through the log records until it finds the transaction's Start record,
 // calling Undo() for each of the transaction's log records.
+// A transaction that logged nothing has no Start record and nothing to undo, so the log is not scanned at all. The
+// scan also stops at a Checkpoint record, since no transaction active at a checkpoint can still be running.
 func (rm *RecoveryManager) doRollback() error {
+	// Changes to unlogged files touch other blocks than the logged ones, so they can be undone first.
+	for i := len(rm.unloggedUndo.records) - 1; i >= 0; i-- {
+		logRecord, err := CreateLogRecord(rm.unloggedUndo.records[i])
+		if err != nil {
+			return err
+		}
+		if err := logRecord.Undo(rm.transaction); err != nil {
+			return err
+		}
+	}
+	rm.unloggedUndo.records = nil
+	if !rm.txLog.started {
+		return nil
+	}
+
 	iter, err := rm.logManager.Iterator()
 	if err != nil {
 		return err
@@ -142,6 +375,10 @@ func (rm *RecoveryManager) doRollback() error {
 			return err
 		}
 
+		if logRecord.Op() == Checkpoint {
+			break
+		}
+
 		// if this log record is related to the transaction, undo it.
 		if logRecord.TxNumber() == rm.txNum {
 			// if this is the start record, break the loop.
@@ -157,23 +394,55 @@ func (rm *RecoveryManager) doRollback() error {
 	return nil
 }
 
+// fileRecord is implemented by the log records of changes to a file.
+type fileRecord interface {
+	changedFile() string
+}
+
+// redoRecord is implemented by the log records of changes that a transaction only makes after it has committed.
+type redoRecord interface {
+	redo(tx *Transaction) error
+}
+
 // doRecovers performs a complete database recovery.
 // The method iterates through the log records.
 // Whenever it finds a log record for an unfinished transaction,
 // it calls Undo() on that record.
+// Files deleted or truncated by a committed transaction are deleted or truncated again, in case the crash came
+// before the transaction could. That is skipped if a later record of another transaction changes the file: the
+// transaction held the file locked until it was done, so the file was recreated or extended since.
 // The method stops when it encounters a Checkpoint record or the end of the log.
-func (rm *RecoveryManager) doRecover() error {
+// The counters of state are kept up to date, and blockDone is called whenever a log block has been processed.
+func (rm *RecoveryManager) doRecover(state *RecoveryProgress, blockDone func()) error {
 	finishedTransactions := make([]int, 0, 10)
+	committedTransactions := make(map[int]struct{})
+	undoneTransactions := make(map[int]struct{})
+	// changers holds, for each file, the transactions that changed it in the records scanned so far.
+	changers := make(map[string]map[int]struct{})
+	changedByOthers := func(fileName string, txNum int) bool {
+		for changer := range changers[fileName] {
+			if changer != txNum {
+				return true
+			}
+		}
+		return false
+	}
 	iter, err := rm.logManager.Iterator()
 	if err != nil {
 		return err
 	}
 
 	for iter.HasNext() {
+		previousBlock := iter.BlockNumber()
 		bytes, err := iter.Next()
 		if err != nil {
-			return nil
+			return err
+		}
+		if iter.BlockNumber() != previousBlock {
+			blockDone()
 		}
+		state.BlocksScanned = state.LogBlocks - iter.BlockNumber()
+		state.RecordsScanned++
 
 		logRecord, err := CreateLogRecord(bytes)
 		if err != nil {
@@ -184,12 +453,35 @@ func (rm *RecoveryManager) doRecover() error {
 			return nil
 		}
 
+		if r, ok := logRecord.(fileRecord); ok {
+			fileName, txNum := r.changedFile(), logRecord.TxNumber()
+			_, committed := committedTransactions[txNum]
+			if r, ok := logRecord.(redoRecord); ok && committed && !changedByOthers(fileName, txNum) {
+				if err := r.redo(rm.transaction); err != nil {
+					return err
+				}
+			}
+			if changers[fileName] == nil {
+				changers[fileName] = make(map[int]struct{})
+			}
+			changers[fileName][txNum] = struct{}{}
+		}
+
 		if logRecord.Op() == Commit || logRecord.Op() == Rollback {
 			finishedTransactions = append(finishedTransactions, logRecord.TxNumber())
+			if logRecord.Op() == Commit {
+				committedTransactions[logRecord.TxNumber()] = struct{}{}
+			}
 		} else if !contains(finishedTransactions, logRecord.TxNumber()) {
 			if err := logRecord.Undo(rm.transaction); err != nil {
 				return err
 			}
+			// A start record marks where the transaction began; there is nothing to undo.
+			if logRecord.Op() != Start {
+				state.RecordsUndone++
+			}
+			undoneTransactions[logRecord.TxNumber()] = struct{}{}
+			state.TransactionsUndone = len(undoneTransactions)
 		}
 	}
 	return nil
@@ -0,0 +1,55 @@
+package tx
+
+// RecoveryPhase is a step of recovery.
+type RecoveryPhase int
+
+const (
+	// RecoveryUndo scans the log backwards, undoing the changes of unfinished transactions.
+	RecoveryUndo RecoveryPhase = iota
+	// RecoveryFlush writes the restored blocks to disk.
+	RecoveryFlush
+	// RecoveryCheckpoint writes the checkpoint record that ends recovery.
+	RecoveryCheckpoint
+	// RecoveryDone means recovery has finished.
+	RecoveryDone
+)
+
+func (p RecoveryPhase) String() string {
+	switch p {
+	case RecoveryUndo:
+		return "undo"
+	case RecoveryFlush:
+		return "flush"
+	case RecoveryCheckpoint:
+		return "checkpoint"
+	case RecoveryDone:
+		return "done"
+	default:
+		return "unknown"
+	}
+}
+
+// RecoveryProgress is a snapshot of a running recovery. It is reported when recovery enters a phase, and during
+// the undo phase after every log block.
+type RecoveryProgress struct {
+	Phase RecoveryPhase
+	// RecordsScanned is the number of log records read so far.
+	RecordsScanned int
+	// RecordsUndone is the number of changes made by unfinished transactions that were undone. Start records of
+	// unfinished transactions are scanned but not counted, because they change nothing.
+	RecordsUndone int
+	// TransactionsUndone is the number of unfinished transactions found so far.
+	TransactionsUndone int
+	// BlocksScanned is the number of log blocks read so far, out of LogBlocks.
+	BlocksScanned int
+	LogBlocks     int
+}
+
+// Percent estimates how much of the log has been processed, from 0 to 100. Recovery usually stops at a checkpoint
+// before reaching the start of the log, in which case it jumps to 100 when recovery is done.
+func (p RecoveryProgress) Percent() float64 {
+	if p.Phase == RecoveryDone || p.LogBlocks == 0 {
+		return 100
+	}
+	return 100 * float64(p.BlocksScanned) / float64(p.LogBlocks)
+}
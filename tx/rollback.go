@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -14,8 +13,11 @@ type RollbackRecord struct {
 
 func NewRollbackRecord(page *file.Page) (*RollbackRecord, error) {
 	operationPos := 0
-	txNumPos := operationPos * utils.IntSize
-	txNum := int(page.GetInt(txNumPos))
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := newRecordReader(page, "Rollback").getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 	return &RollbackRecord{
 		txNum: txNum,
 	}, nil
@@ -44,12 +46,12 @@ func (r *RollbackRecord) String() string {
 // WriteRollbackToLog writes a rollback record to the log. This log record contains the Rollback operator,
 // followed by the transaction id.
 // The method returns the LSN of the new log record.
-func WriteRollbackToLog(logManager *log.Manager, txNum int) (int, error) {
+func WriteRollbackToLog(logManager LogAppender, txNum int) (int, error) {
 	record := make([]byte, 2*utils.IntSize)
 
 	page := file.NewPageFromBytes(record)
 	page.SetInt(0, int(Rollback))
-	page.SetInt(4, txNum)
+	page.SetInt(utils.IntSize, txNum)
 
 	return logManager.Append(record)
 }
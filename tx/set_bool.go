@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -16,24 +15,37 @@ type SetBoolRecord struct {
 }
 
 func NewSetBoolRecord(page *file.Page) (*SetBoolRecord, error) {
+	reader := newRecordReader(page, "SetBool")
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 
 	fileNamePos := txNumPos + utils.IntSize
-	fileName, err := page.GetString(fileNamePos)
+	fileName, err := reader.getString("fileName", fileNamePos)
 	if err != nil {
 		return nil, err
 	}
 
 	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
-	blockNum := page.GetInt(blockNumPos)
-	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
 
 	offsetPos := blockNumPos + utils.IntSize
-	offset := page.GetInt(offsetPos)
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
 
 	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, 1); err != nil {
+		return nil, err
+	}
 	val := page.GetBool(valuePos)
 
 	return &SetBoolRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
@@ -47,6 +59,10 @@ func (r *SetBoolRecord) TxNumber() int {
 	return r.txNum
 }
 
+func (r *SetBoolRecord) changedFile() string {
+	return r.block.Filename()
+}
+
 func (r *SetBoolRecord) String() string {
 	return fmt.Sprintf("<SETBOOL %d %s %d %t>", r.txNum, r.block, r.offset, r.value)
 }
@@ -59,7 +75,7 @@ func (r *SetBoolRecord) Undo(tx *Transaction) error {
 	return tx.SetBool(r.block, r.offset, r.value, false)
 }
 
-func WriteSetBoolToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val bool) (int, error) {
+func WriteSetBoolToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val bool) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
 	fileNamePos := txNumPos + utils.IntSize
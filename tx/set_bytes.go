@@ -0,0 +1,157 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+)
+
+// SetBytesRecord logs a change to a range of bytes in a block. Unlike the typed records it stores both the old and
+// the new contents of the range, so it can be undone without knowing what the bytes represent.
+type SetBytesRecord struct {
+	LogRecord
+	txNum    int
+	offset   int
+	oldValue []byte
+	newValue []byte
+	block    *file.BlockId
+}
+
+// NewSetBytesRecord creates a new SetBytesRecord from a Page.
+func NewSetBytesRecord(page *file.Page) (*SetBytesRecord, error) {
+	reader := newRecordReader(page, "SetBytes")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
+
+	offsetPos := blockNumPos + utils.IntSize
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
+
+	oldValuePos := offsetPos + utils.IntSize
+	oldValue, err := reader.getBytes("oldValue", oldValuePos)
+	if err != nil {
+		return nil, err
+	}
+
+	newValuePos := oldValuePos + utils.IntSize + len(oldValue)
+	newValue, err := reader.getBytes("newValue", newValuePos)
+	if err != nil {
+		return nil, err
+	}
+	if len(newValue) != len(oldValue) {
+		return nil, reader.errorf("newValue", newValuePos, "length %d differs from old length %d", len(newValue), len(oldValue))
+	}
+
+	return &SetBytesRecord{txNum: txNum, offset: offset, oldValue: oldValue, newValue: newValue, block: block}, nil
+}
+
+// Op returns the type of the log record.
+func (r *SetBytesRecord) Op() LogRecordType {
+	return SetBytes
+}
+
+// TxNumber returns the transaction number stored in the log record.
+func (r *SetBytesRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetBytesRecord) changedFile() string {
+	return r.block.Filename()
+}
+
+// String returns a string representation of the log record.
+func (r *SetBytesRecord) String() string {
+	return fmt.Sprintf("<SETBYTES %d %s %d %x %x>", r.txNum, r.block, r.offset, r.oldValue, r.newValue)
+}
+
+// Undo restores the old contents of the byte range.
+func (r *SetBytesRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetBytes(r.block, r.offset, r.oldValue, false) // Don't log the undo
+}
+
+// WriteSetBytesToLog writes a set bytes record to the log. The record contains the specified transaction number,
+// the filename and block number of the block, the offset of the changed range in the block, and the contents of the
+// range before and after the change, which must have the same length.
+// The method returns the LSN of the new log record.
+func WriteSetBytesToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, oldValue, newValue []byte) (int, error) {
+	if len(oldValue) != len(newValue) {
+		return -1, fmt.Errorf("old and new values differ in length: %d and %d", len(oldValue), len(newValue))
+	}
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + utils.IntSize
+	oldValuePos := offsetPos + utils.IntSize
+	newValuePos := oldValuePos + utils.IntSize + len(oldValue)
+
+	recordBytes := make([]byte, setBytesRecordSize(fileName, len(newValue)))
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetBytes))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetBytes(oldValuePos, oldValue)
+	page.SetBytes(newValuePos, newValue)
+
+	return logManager.Append(recordBytes)
+}
+
+// setBytesRecordSize returns the size of a set bytes record for a block of the named file whose old and new values
+// are valueLen bytes long.
+func setBytesRecordSize(fileName string, valueLen int) int {
+	return 6*utils.IntSize + file.MaxLength(len(fileName)) + 2*valueLen
+}
+
+// maxSetBytesChunk returns the longest range of a block of the named file that one set bytes record can hold, given
+// that a log record must fit into a single log block of blockSize bytes together with the start record of its
+// transaction.
+func maxSetBytesChunk(blockSize int, fileName string) int {
+	// The log block starts with its boundary, and each record is preceded by its length.
+	available := blockSize - utils.IntSize - (utils.IntSize + len(startRecordBytes(0))) - utils.IntSize
+	return (available - setBytesRecordSize(fileName, 0)) / 2
+}
+
+// changedRange returns the smallest range [start, end) outside which old and new are equal. The slices must have
+// the same length; start equals end if they are identical.
+func changedRange(old, new []byte) (start, end int) {
+	end = len(new)
+	for start < end && old[start] == new[start] {
+		start++
+	}
+	for end > start && old[end-1] == new[end-1] {
+		end--
+	}
+	return start, end
+}
@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 	"time"
 )
@@ -17,24 +16,37 @@ type SetDateRecord struct {
 }
 
 func NewSetDateRecord(page *file.Page) (*SetDateRecord, error) {
+	reader := newRecordReader(page, "SetDate")
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 
 	fileNamePos := txNumPos + utils.IntSize
-	fileName, err := page.GetString(fileNamePos)
+	fileName, err := reader.getString("fileName", fileNamePos)
 	if err != nil {
 		return nil, err
 	}
 
 	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
-	blockNum := page.GetInt(blockNumPos)
-	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
 
 	offsetPos := blockNumPos + utils.IntSize
-	offset := page.GetInt(offsetPos)
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
 
 	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, 8); err != nil {
+		return nil, err
+	}
 	val := page.GetDate(valuePos)
 
 	return &SetDateRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
@@ -48,6 +60,10 @@ func (r *SetDateRecord) TxNumber() int {
 	return r.txNum
 }
 
+func (r *SetDateRecord) changedFile() string {
+	return r.block.Filename()
+}
+
 func (r *SetDateRecord) String() string {
 	return fmt.Sprintf("<SETDATE %d %s %d %s>", r.txNum, r.block, r.offset, r.value.String())
 }
@@ -60,7 +76,7 @@ func (r *SetDateRecord) Undo(tx *Transaction) error {
 	return tx.SetDate(r.block, r.offset, r.value, false)
 }
 
-func WriteSetDateToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val time.Time) (int, error) {
+func WriteSetDateToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val time.Time) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
 	fileNamePos := txNumPos + utils.IntSize
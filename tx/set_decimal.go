@@ -0,0 +1,104 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+)
+
+type SetDecimalRecord struct {
+	LogRecord
+	txNum  int
+	offset int
+	value  file.Decimal
+	block  *file.BlockId
+}
+
+func NewSetDecimalRecord(page *file.Page) (*SetDecimalRecord, error) {
+	reader := newRecordReader(page, "SetDecimal")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
+
+	offsetPos := blockNumPos + utils.IntSize
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
+
+	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, file.DecimalSize); err != nil {
+		return nil, err
+	}
+	val := page.GetDecimal(valuePos)
+
+	return &SetDecimalRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+}
+
+func (r *SetDecimalRecord) Op() LogRecordType {
+	return SetDecimal
+}
+
+func (r *SetDecimalRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetDecimalRecord) changedFile() string {
+	return r.block.Filename()
+}
+
+func (r *SetDecimalRecord) String() string {
+	return fmt.Sprintf("<SETDECIMAL %d %s %d %s>", r.txNum, r.block, r.offset, r.value.String())
+}
+
+func (r *SetDecimalRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetDecimal(r.block, r.offset, r.value, false)
+}
+
+func WriteSetDecimalToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val file.Decimal) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + utils.IntSize
+	valuePos := offsetPos + utils.IntSize
+	recordLen := valuePos + file.DecimalSize
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetDecimal))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetDecimal(valuePos, val)
+
+	return logManager.Append(recordBytes)
+}
@@ -0,0 +1,105 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+)
+
+type SetFloatRecord struct {
+	LogRecord
+	txNum  int
+	offset int
+	value  float64
+	block  *file.BlockId
+}
+
+func NewSetFloatRecord(page *file.Page) (*SetFloatRecord, error) {
+	reader := newRecordReader(page, "SetFloat")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
+
+	offsetPos := blockNumPos + utils.IntSize
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
+
+	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, 8); err != nil {
+		return nil, err
+	}
+	val := page.GetFloat(valuePos) // 8 bytes
+
+	return &SetFloatRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+}
+
+func (r *SetFloatRecord) Op() LogRecordType {
+	return SetFloat
+}
+
+func (r *SetFloatRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetFloatRecord) changedFile() string {
+	return r.block.Filename()
+}
+
+func (r *SetFloatRecord) String() string {
+	return fmt.Sprintf("<SETFLOAT %d %s %d %v>", r.txNum, r.block, r.offset, r.value)
+}
+
+func (r *SetFloatRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetFloat(r.block, r.offset, r.value, false)
+}
+
+func WriteSetFloatToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val float64) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + utils.IntSize
+	valuePos := offsetPos + utils.IntSize
+	// float64 is 8 bytes
+	recordLen := valuePos + 8
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetFloat))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetFloat(valuePos, val)
+
+	return logManager.Append(recordBytes)
+}
@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -17,25 +16,38 @@ type SetIntRecord struct {
 
 // NewSetIntRecord creates a new SetIntRecord from a Page.
 func NewSetIntRecord(page *file.Page) (*SetIntRecord, error) {
+	reader := newRecordReader(page, "SetInt")
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 
 	fileNamePos := txNumPos + utils.IntSize
-	fileName, err := page.GetString(fileNamePos)
+	fileName, err := reader.getString("fileName", fileNamePos)
 	if err != nil {
 		return nil, err
 	}
 
 	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
-	blockNum := page.GetInt(blockNumPos)
-	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
 
 	offsetPos := blockNumPos + utils.IntSize
-	offset := page.GetInt(offsetPos)
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
 
 	valuePos := offsetPos + utils.IntSize
-	value := page.GetInt(valuePos)
+	value, err := reader.getInt("value", valuePos)
+	if err != nil {
+		return nil, err
+	}
 
 	return &SetIntRecord{txNum: txNum, offset: offset, value: value, block: block}, nil
 }
@@ -50,6 +62,10 @@ func (r *SetIntRecord) TxNumber() int {
 	return r.txNum
 }
 
+func (r *SetIntRecord) changedFile() string {
+	return r.block.Filename()
+}
+
 // String returns a string representation of the log record.
 func (r *SetIntRecord) String() string {
 	return fmt.Sprintf("<SETINT %d %s %d %d>", r.txNum, r.block, r.offset, r.value)
@@ -71,7 +87,7 @@ func (r *SetIntRecord) Undo(tx *Transaction) error {
 // filename and block number of the block containing the int, the offset of the int in the block, and the new value
 // of the int.
 // The method returns the LSN of the new log record.
-func WriteSetIntToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset, val int) (int, error) {
+func WriteSetIntToLog(logManager LogAppender, txNum int, block *file.BlockId, offset, val int) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
 	fileNamePos := txNumPos + utils.IntSize
@@ -0,0 +1,104 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+)
+
+type SetIntervalRecord struct {
+	LogRecord
+	txNum  int
+	offset int
+	value  file.Interval
+	block  *file.BlockId
+}
+
+func NewSetIntervalRecord(page *file.Page) (*SetIntervalRecord, error) {
+	reader := newRecordReader(page, "SetInterval")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
+
+	offsetPos := blockNumPos + utils.IntSize
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
+
+	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, file.IntervalSize); err != nil {
+		return nil, err
+	}
+	val := page.GetInterval(valuePos)
+
+	return &SetIntervalRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+}
+
+func (r *SetIntervalRecord) Op() LogRecordType {
+	return SetInterval
+}
+
+func (r *SetIntervalRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetIntervalRecord) changedFile() string {
+	return r.block.Filename()
+}
+
+func (r *SetIntervalRecord) String() string {
+	return fmt.Sprintf("<SETINTERVAL %d %s %d %s>", r.txNum, r.block, r.offset, r.value.String())
+}
+
+func (r *SetIntervalRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetInterval(r.block, r.offset, r.value, false)
+}
+
+func WriteSetIntervalToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val file.Interval) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + utils.IntSize
+	valuePos := offsetPos + utils.IntSize
+	recordLen := valuePos + file.IntervalSize
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetInterval))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetInterval(valuePos, val)
+
+	return logManager.Append(recordBytes)
+}
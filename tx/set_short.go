@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -16,24 +15,37 @@ type SetShortRecord struct {
 }
 
 func NewSetShortRecord(page *file.Page) (*SetShortRecord, error) {
+	reader := newRecordReader(page, "SetShort")
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 
 	fileNamePos := txNumPos + utils.IntSize
-	fileName, err := page.GetString(fileNamePos)
+	fileName, err := reader.getString("fileName", fileNamePos)
 	if err != nil {
 		return nil, err
 	}
 
 	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
-	blockNum := page.GetInt(blockNumPos)
-	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
 
 	offsetPos := blockNumPos + utils.IntSize
-	offset := page.GetInt(offsetPos)
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
 
 	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, 2); err != nil {
+		return nil, err
+	}
 	val := page.GetShort(valuePos)
 
 	return &SetShortRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
@@ -47,6 +59,10 @@ func (r *SetShortRecord) TxNumber() int {
 	return r.txNum
 }
 
+func (r *SetShortRecord) changedFile() string {
+	return r.block.Filename()
+}
+
 func (r *SetShortRecord) String() string {
 	return fmt.Sprintf("<SETSHORT %d %s %d %d>", r.txNum, r.block, r.offset, r.value)
 }
@@ -59,7 +75,7 @@ func (r *SetShortRecord) Undo(tx *Transaction) error {
 	return tx.SetShort(r.block, r.offset, r.value, false)
 }
 
-func WriteSetShortToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, val int16) (int, error) {
+func WriteSetShortToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val int16) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
 	fileNamePos := txNumPos + utils.IntSize
@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -17,25 +16,35 @@ type SetStringRecord struct {
 
 // NewSetStringRecord creates a new SetStringRecord from a Page.
 func NewSetStringRecord(page *file.Page) (*SetStringRecord, error) {
+	reader := newRecordReader(page, "SetString")
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 
 	fileNamePos := txNumPos + utils.IntSize
-	fileName, err := page.GetString(fileNamePos)
+	fileName, err := reader.getString("fileName", fileNamePos)
 	if err != nil {
 		return nil, err
 	}
 
 	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
-	blockNum := page.GetInt(blockNumPos)
-	block := &file.BlockId{File: fileName, BlockNumber: int(blockNum)}
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
 
 	offsetPos := blockNumPos + utils.IntSize
-	offset := page.GetInt(offsetPos)
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
 
 	valuePos := offsetPos + utils.IntSize
-	value, err := page.GetString(valuePos)
+	value, err := reader.getString("value", valuePos)
 	if err != nil {
 		return nil, err
 	}
@@ -53,6 +62,10 @@ func (r *SetStringRecord) TxNumber() int {
 	return r.txNum
 }
 
+func (r *SetStringRecord) changedFile() string {
+	return r.block.Filename()
+}
+
 // String returns a string representation of the log record.
 func (r *SetStringRecord) String() string {
 	return fmt.Sprintf("<SETSTRING %d %s %d %s>", r.txNum, r.block, r.offset, r.value)
@@ -73,7 +86,7 @@ func (r *SetStringRecord) Undo(tx *Transaction) error {
 // filename and block number of the block containing the string, the offset of the string in the block, and the new value
 // of the string.
 // The method returns the LSN of the new log record.
-func WriteSetStringToLog(logManager *log.Manager, txNum int, block *file.BlockId, offset int, value string) (int, error) {
+func WriteSetStringToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, value string) (int, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
 	fileNamePos := txNumPos + utils.IntSize
@@ -0,0 +1,105 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+	"time"
+)
+
+type SetTimestampRecord struct {
+	LogRecord
+	txNum  int
+	offset int
+	value  time.Time
+	block  *file.BlockId
+}
+
+func NewSetTimestampRecord(page *file.Page) (*SetTimestampRecord, error) {
+	reader := newRecordReader(page, "SetTimestamp")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
+
+	offsetPos := blockNumPos + utils.IntSize
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
+
+	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, file.TimestampSize); err != nil {
+		return nil, err
+	}
+	val := page.GetTimestamp(valuePos)
+
+	return &SetTimestampRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+}
+
+func (r *SetTimestampRecord) Op() LogRecordType {
+	return SetTimestamp
+}
+
+func (r *SetTimestampRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetTimestampRecord) changedFile() string {
+	return r.block.Filename()
+}
+
+func (r *SetTimestampRecord) String() string {
+	return fmt.Sprintf("<SETTIMESTAMP %d %s %d %s>", r.txNum, r.block, r.offset, r.value.Format(time.RFC3339Nano))
+}
+
+func (r *SetTimestampRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetTimestamp(r.block, r.offset, r.value, false)
+}
+
+func WriteSetTimestampToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val time.Time) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + utils.IntSize
+	valuePos := offsetPos + utils.IntSize
+	recordLen := valuePos + file.TimestampSize
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetTimestamp))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetTimestamp(valuePos, val)
+
+	return logManager.Append(recordBytes)
+}
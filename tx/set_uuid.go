@@ -0,0 +1,116 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/utils"
+)
+
+type SetUUIDRecord struct {
+	LogRecord
+	txNum  int
+	offset int
+	value  file.UUID
+	block  *file.BlockId
+}
+
+// NewSetUUIDRecord creates a new SetUUIDRecord from a Page.
+func NewSetUUIDRecord(page *file.Page) (*SetUUIDRecord, error) {
+	reader := newRecordReader(page, "SetUUID")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum, err := reader.getNonNegativeInt("blockNum", blockNumPos)
+	if err != nil {
+		return nil, err
+	}
+	block := &file.BlockId{File: fileName, BlockNumber: blockNum}
+
+	offsetPos := blockNumPos + utils.IntSize
+	offset, err := reader.getNonNegativeInt("offset", offsetPos)
+	if err != nil {
+		return nil, err
+	}
+
+	valuePos := offsetPos + utils.IntSize
+	if err := reader.require("value", valuePos, file.UUIDSize); err != nil {
+		return nil, err
+	}
+	val := page.GetUUID(valuePos)
+
+	return &SetUUIDRecord{txNum: txNum, offset: offset, value: val, block: block}, nil
+}
+
+// Op returns the type of the log record.
+func (r *SetUUIDRecord) Op() LogRecordType {
+	return SetUUID
+}
+
+// TxNumber returns the transaction number stored in the log record.
+func (r *SetUUIDRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *SetUUIDRecord) changedFile() string {
+	return r.block.Filename()
+}
+
+// String returns a string representation of the log record.
+func (r *SetUUIDRecord) String() string {
+	return fmt.Sprintf("<SETUUID %d %s %d %s>", r.txNum, r.block, r.offset, r.value.String())
+}
+
+// Undo replaces the specified data value with the value saved in the log record.
+// The method pins a buffer to the specified block,
+// calls SetUUID to restore the saved value,
+// and unpins the buffer.
+func (r *SetUUIDRecord) Undo(tx *Transaction) error {
+	if err := tx.Pin(r.block); err != nil {
+		return err
+	}
+	defer tx.Unpin(r.block)
+	return tx.SetUUID(r.block, r.offset, r.value, false)
+}
+
+// WriteSetUUIDToLog writes a SetUUID record to the log. The record contains the specified transaction number, the
+// filename and block number of the block containing the UUID, the offset of the UUID in the block, and the new value
+// of the UUID.
+// The method returns the LSN of the new log record.
+func WriteSetUUIDToLog(logManager LogAppender, txNum int, block *file.BlockId, offset int, val file.UUID) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	fileName := block.Filename()
+
+	blockNumPos := fileNamePos + file.MaxLength(len(fileName))
+	blockNum := block.Number()
+
+	offsetPos := blockNumPos + utils.IntSize
+	valuePos := offsetPos + utils.IntSize
+	recordLen := valuePos + file.UUIDSize
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(SetUUID))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blockNumPos, blockNum)
+	page.SetInt(offsetPos, offset)
+	page.SetUUID(valuePos, val)
+
+	return logManager.Append(recordBytes)
+}
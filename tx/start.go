@@ -3,7 +3,6 @@ package tx
 import (
 	"fmt"
 	"mydb/file"
-	"mydb/log"
 	"mydb/utils"
 )
 
@@ -15,7 +14,10 @@ type StartRecord struct {
 func NewStartRecord(page *file.Page) (*StartRecord, error) {
 	operationPos := 0
 	txNumPos := operationPos + utils.IntSize
-	txNum := page.GetInt(txNumPos)
+	txNum, err := newRecordReader(page, "Start").getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
 	return &StartRecord{txNum: txNum}, nil
 }
 
@@ -39,12 +41,19 @@ func (r *StartRecord) String() string {
 	return fmt.Sprintf("<START %d>", r.txNum)
 }
 
-func WriteStartToLog(logManager *log.Manager, txNum int) (int, error) {
+// WriteStartToLog writes a start record to the log. This log record contains the Start operator,
+// followed by the transaction id.
+// The method returns the LSN of the new log record.
+func WriteStartToLog(logManager LogAppender, txNum int) (int, error) {
+	return logManager.Append(startRecordBytes(txNum))
+}
+
+// startRecordBytes encodes the start record of transaction txNum.
+func startRecordBytes(txNum int) []byte {
 	record := make([]byte, 2*utils.IntSize)
 
 	page := file.NewPageFromBytes(record)
 	page.SetInt(0, int(Start))
-	page.SetInt(4, txNum)
-
-	return logManager.Append(record)
+	page.SetInt(utils.IntSize, txNum)
+	return record
 }
@@ -0,0 +1,75 @@
+package tx
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+// TempFileManager creates the temporary files of a transaction, such as the runs of an external sort or a
+// materialized intermediate result, and deletes them when the transaction commits or rolls back. Temporary files are
+// never logged or locked, see file.IsTempFile; their names are unique, so no other transaction uses them.
+type TempFileManager struct {
+	tx    *Transaction
+	names []string
+}
+
+// TempFiles returns the manager of the transaction's temporary files.
+func (tx *Transaction) TempFiles() *TempFileManager {
+	return &tx.tempFiles
+}
+
+// New creates an empty temporary file for the transaction and returns its name.
+func (m *TempFileManager) New() (string, error) {
+	if m.tx.finished.Load() {
+		return "", ErrTxDone
+	}
+	name, err := m.tx.fileManager.NewTempFile()
+	if err != nil {
+		return "", err
+	}
+	m.names = append(m.names, name)
+	return name, nil
+}
+
+// Files returns the names of the temporary files the transaction holds, in the order they were created.
+func (m *TempFileManager) Files() []string {
+	return slices.Clone(m.names)
+}
+
+// Delete deletes a temporary file of the transaction before it finishes, to free its space early. Its blocks must
+// not be pinned.
+func (m *TempFileManager) Delete(name string) error {
+	i := slices.Index(m.names, name)
+	if i < 0 {
+		return fmt.Errorf("%s is not a temporary file of transaction %d", name, m.tx.txNum)
+	}
+	if err := m.delete(name); err != nil {
+		return err
+	}
+	m.names = slices.Delete(m.names, i, i+1)
+	return nil
+}
+
+// deleteAll deletes every temporary file of the transaction, once it has unpinned its buffers.
+func (m *TempFileManager) deleteAll() error {
+	var errs []error
+	for _, name := range m.names {
+		if err := m.delete(name); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	m.names = nil
+	return errors.Join(errs...)
+}
+
+// delete deletes the named temporary file and its free map and overflow file, if any.
+func (m *TempFileManager) delete(name string) error {
+	overflow := name + OverflowSuffix
+	for _, filename := range []string{name, name + FreeMapSuffix, overflow, overflow + FreeMapSuffix} {
+		if err := m.tx.shrinkFile(filename, deletedFile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,60 @@
+package tx_test
+
+import (
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestTempFiles(t *testing.T) {
+	backend := file.NewMemoryBackend()
+	fm, err := file.NewManagerWithBackend(backend, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8, buffer.WithTempPool(4))
+	lt := concurrency.NewLockTable()
+	files := func() []string {
+		names, err := backend.List()
+		require.NoError(t, err)
+		return names
+	}
+
+	for _, commit := range []bool{true, false} {
+		transaction := tx.NewTransaction(fm, lm, bm, lt)
+		temps := transaction.TempFiles()
+		first, err := temps.New()
+		require.NoError(t, err)
+		second, err := temps.New()
+		require.NoError(t, err)
+		assert.NotEqual(t, first, second)
+		assert.True(t, file.IsTempFile(first))
+		assert.Equal(t, []string{first, second}, temps.Files())
+		assert.Subset(t, files(), []string{first, second})
+
+		block, err := transaction.Append(first)
+		require.NoError(t, err)
+		require.NoError(t, transaction.Pin(block))
+		require.NoError(t, transaction.SetInt(block, 0, 42, true))
+
+		require.NoError(t, temps.Delete(second))
+		assert.NotContains(t, files(), second)
+		assert.Error(t, temps.Delete(second))
+
+		if commit {
+			require.NoError(t, transaction.Commit())
+		} else {
+			require.NoError(t, transaction.Rollback())
+		}
+		assert.NotContains(t, files(), first)
+		assert.Empty(t, temps.Files())
+		_, err = temps.New()
+		assert.ErrorIs(t, err, tx.ErrTxDone)
+	}
+}
@@ -0,0 +1,19 @@
+package tx
+
+import (
+	"context"
+	"mydb/utils"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// tracer emits the spans of the transaction layer. Without a registered TracerProvider the spans are no-ops.
+var tracer = otel.Tracer("mydb/tx")
+
+// startSpan starts a child span of the transaction's span, tagged with the transaction number.
+func (tx *Transaction) startSpan(name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	attrs = append(attrs, utils.TxNumAttribute(tx.txNum))
+	return tracer.Start(tx.ctx, name, trace.WithAttributes(attrs...))
+}
@@ -0,0 +1,93 @@
+package tx_test
+
+import (
+	"context"
+	"mydb/buffer"
+	"mydb/file"
+	"mydb/log"
+	"mydb/tx"
+	"mydb/tx/concurrency"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTransactionSpans(t *testing.T) {
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+	previous := otel.GetTracerProvider()
+	otel.SetTracerProvider(provider)
+	defer otel.SetTracerProvider(previous)
+
+	dir, err := os.MkdirTemp("", "tracing_test")
+	require.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	fm, err := file.NewManager(dir, 400)
+	require.NoError(t, err)
+	lm, err := log.NewManager(fm, "logfile")
+	require.NoError(t, err)
+	bm := buffer.NewManager(fm, lm, 8)
+	lt := concurrency.NewLockTable()
+
+	ctx, parent := otel.Tracer("test").Start(context.Background(), "request")
+	transaction := tx.NewTransactionWithContext(ctx, fm, lm, bm, lt)
+	block := file.NewBlockId("testfile", 1)
+	require.NoError(t, transaction.Pin(block))
+	require.NoError(t, transaction.SetInt(block, 0, 7, true))
+	require.NoError(t, transaction.Commit())
+	parent.End()
+
+	spans := make(map[string]sdktrace.ReadOnlySpan)
+	for _, span := range recorder.Ended() {
+		spans[span.Name()] = span
+	}
+	for _, name := range []string{"tx", "tx.commit", "buffer.pin", "lock.slock", "lock.xlock", "buffer.flush", "log.flush"} {
+		assert.Contains(t, spans, name, "expected a %s span", name)
+	}
+
+	// The transaction span belongs to the caller's trace, and every operation span hangs off the transaction.
+	txSpan := spans["tx"]
+	assert.Equal(t, parent.SpanContext().SpanID(), txSpan.Parent().SpanID())
+	for _, name := range []string{"buffer.pin", "lock.xlock", "log.flush"} {
+		assert.Equal(t, txSpan.SpanContext().SpanID(), spans[name].Parent().SpanID(), "%s should be a child of tx", name)
+	}
+
+	attrs := make(map[string]interface{})
+	for _, kv := range spans["buffer.pin"].Attributes() {
+		attrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	assert.Equal(t, "testfile", attrs["mydb.block.file"])
+	assert.Equal(t, int64(1), attrs["mydb.block.number"])
+	assert.Equal(t, int64(transaction.TxNum()), attrs["mydb.tx.num"])
+
+	lockAttrs := make(map[string]interface{})
+	for _, kv := range spans["lock.xlock"].Attributes() {
+		lockAttrs[string(kv.Key)] = kv.Value.AsInterface()
+	}
+	assert.Equal(t, int64(transaction.TxNum()), lockAttrs["mydb.tx.num"])
+
+	// A failed commit still ends the transaction span, with the error recorded.
+	failing := tx.NewTransaction(fm, lm, bm, lt)
+	require.NoError(t, failing.Pin(block))
+	require.NoError(t, failing.SetInt(block, 0, 8, true))
+	require.NoError(t, fm.Close())
+	require.Error(t, failing.Commit())
+
+	var failedSpan sdktrace.ReadOnlySpan
+	for _, span := range recorder.Ended() {
+		for _, kv := range span.Attributes() {
+			if span.Name() == "tx" && kv.Key == "mydb.tx.num" && kv.Value.AsInt64() == int64(failing.TxNum()) {
+				failedSpan = span
+			}
+		}
+	}
+	require.NotNil(t, failedSpan, "the transaction span must end when the commit fails")
+	assert.Equal(t, codes.Error, failedSpan.Status().Code)
+}
@@ -1,18 +1,29 @@
 package tx
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"math"
 	"mydb/buffer"
 	"mydb/file"
 	"mydb/log"
 	"mydb/tx/concurrency"
+	"mydb/utils"
 	"sync"
+	"sync/atomic"
 	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 )
 
 const EndOfFile = -1
 
+// ErrTxDone is returned by Commit and Rollback when the transaction has already been committed or rolled back,
+// for instance because its context was cancelled.
+var ErrTxDone = errors.New("transaction has already been committed or rolled back")
+
 var (
 	nextTxNum   = 0
 	nextTxNumMu sync.Mutex
@@ -25,6 +36,37 @@ func nextTxNumber() int {
 	return nextTxNum
 }
 
+// ResumeTxNumbers makes the transactions created from now on take numbers above those of every transaction in the
+// log since its last checkpoint. Transaction numbers restart at 1 in every process, so without it a transaction
+// could share its number with one of a previous run, and rollback or recovery would mistake the records of one for
+// those of the other. It must be called before the log is used by any transaction.
+func ResumeTxNumbers(logManager *log.Manager) error {
+	iter, err := logManager.Iterator()
+	if err != nil {
+		return err
+	}
+	highest := 0
+	for iter.HasNext() {
+		bytes, err := iter.Next()
+		if err != nil {
+			return err
+		}
+		logRecord, err := CreateLogRecord(bytes)
+		if err != nil {
+			return err
+		}
+		if logRecord.Op() == Checkpoint {
+			break
+		}
+		highest = max(highest, logRecord.TxNumber())
+	}
+
+	nextTxNumMu.Lock()
+	defer nextTxNumMu.Unlock()
+	nextTxNum = max(nextTxNum, highest)
+	return nil
+}
+
 type Transaction struct {
 	recoveryManager    *RecoveryManager
 	concurrencyManager *concurrency.Manager
@@ -32,20 +74,49 @@ type Transaction struct {
 	fileManager        *file.Manager
 	txNum              int
 	myBuffers          *BufferList
+	ctx                context.Context
+	span               trace.Span
+	finished           atomic.Bool
+	// ending is set while Commit or Rollback runs, so that the undo done by a rollback is not cancelled.
+	ending   bool
+	location *time.Location
+	// bulkFiles holds the files the transaction loaded blocks into with BulkLoad.
+	bulkFiles map[string]bool
+	// freed holds the blocks the transaction freed with FreeBlock.
+	freed map[file.BlockId]bool
+	// shrinks maps the files the transaction deleted or truncated to the number of blocks they keep once it
+	// commits, deletedFile for deleted files.
+	shrinks   map[string]int
+	tempFiles TempFileManager
 }
 
 // This method depends on the file, log, and buffer managers which it receives from the instantiating class.
 // These objects are usually created during system initialization. Thus, this constructor cannot be called until either
 // the DropDB#Init or DropDB#InitFileLogAndBufferManager methods are called.
 func NewTransaction(fileManager *file.Manager, logManager *log.Manager, bufferManager *buffer.Manager, lockTable *concurrency.LockTable) *Transaction {
+	return NewTransactionWithContext(context.Background(), fileManager, logManager, bufferManager, lockTable)
+}
+
+// NewTransactionWithContext creates a transaction whose trace span is a child of the span carried by ctx.
+// The transaction span lasts until Commit or Rollback; lock acquisitions, buffer pins and log flushes
+// made by the transaction are recorded as its children.
+// Once ctx is done, the transaction is rolled back the next time it pins or locks a block, or while it waits for a
+// lock, and that operation returns an error wrapping ctx.Err(). A wait for a free buffer is not interrupted.
+func NewTransactionWithContext(ctx context.Context, fileManager *file.Manager, logManager *log.Manager, bufferManager *buffer.Manager, lockTable *concurrency.LockTable) *Transaction {
+	txNum := nextTxNumber()
+	ctx, span := tracer.Start(ctx, "tx", trace.WithAttributes(utils.TxNumAttribute(txNum)))
 	tx := &Transaction{
 		fileManager:        fileManager,
 		bufferManager:      bufferManager,
-		txNum:              nextTxNumber(),
-		concurrencyManager: concurrency.NewManager(lockTable),
+		txNum:              txNum,
+		concurrencyManager: concurrency.NewManagerWithContext(ctx, lockTable, txNum),
 		myBuffers:          NewBufferList(bufferManager),
+		ctx:                ctx,
+		span:               span,
+		location:           time.UTC,
 	}
 	tx.recoveryManager = NewRecoveryManager(tx, tx.txNum, logManager, bufferManager)
+	tx.tempFiles.tx = tx
 	return tx
 }
 
@@ -53,14 +124,39 @@ func NewTransaction(fileManager *file.Manager, logManager *log.Manager, bufferMa
 // Flushes all modified buffers (and their log records),
 // Writes and flushes a commit record to the log,
 // Releases all the locks, and unpins any pinned buffers.
-func (tx *Transaction) Commit() error {
-	if err := tx.recoveryManager.Commit(); err != nil {
-		return err
+// The transaction span ends with the commit, recording the error if it fails.
+func (tx *Transaction) Commit() (err error) {
+	if tx.finished.Load() {
+		return ErrTxDone
+	}
+	tx.ending = true
+	defer func() { tx.ending = false }()
+	_, span := tx.startSpan("tx.commit")
+	defer func() {
+		utils.EndSpan(span, err)
+		utils.EndSpan(tx.span, err)
+	}()
+
+	// A transaction on a read-only database has nothing to flush or log.
+	if !tx.fileManager.ReadOnly() {
+		for filename := range tx.bulkFiles {
+			if err := tx.fileManager.Sync(filename); err != nil {
+				return err
+			}
+		}
+		if err := tx.recoveryManager.Commit(); err != nil {
+			return err
+		}
 	}
 	fmt.Printf("Transaction %d committed\n", tx.txNum)
-	tx.concurrencyManager.Release()
+	// Deleted and truncated files shrink now that the commit record is on disk, but before their locks are released.
 	tx.myBuffers.UnpinAll()
-	return nil
+	err = tx.shrinkFiles()
+	tx.recoveryManager.finishLogging()
+	if err != nil {
+		return errors.Join(err, tx.finish())
+	}
+	return tx.finish()
 }
 
 // Rollback rolls back the current transaction.
@@ -68,33 +164,135 @@ func (tx *Transaction) Commit() error {
 // Flushes those buffers,
 // Writes and flushes a rollback record to the log,
 // Releases all the locks, and unpins any pinned buffers.
-func (tx *Transaction) Rollback() error {
-	if err := tx.recoveryManager.Rollback(); err != nil {
-		return err
+// The transaction span ends with the rollback, recording the error if it fails.
+func (tx *Transaction) Rollback() (err error) {
+	if tx.finished.Load() {
+		return ErrTxDone
+	}
+	tx.ending = true
+	defer func() { tx.ending = false }()
+	_, span := tx.startSpan("tx.rollback")
+	defer func() {
+		utils.EndSpan(span, err)
+		utils.EndSpan(tx.span, err)
+	}()
+
+	if !tx.fileManager.ReadOnly() {
+		if err := tx.recoveryManager.Rollback(); err != nil {
+			return err
+		}
 	}
 	fmt.Printf("Transaction %d rolled back\n", tx.txNum)
-	tx.concurrencyManager.Release()
+	return tx.finish()
+}
+
+// finish releases the locks and buffers of a committed or rolled back transaction, and deletes its temporary files.
+// Failing to release a lock does not undo the commit or rollback, but it is reported because the lock table no longer
+// matches the transaction.
+func (tx *Transaction) finish() error {
+	err := tx.concurrencyManager.Release()
 	tx.myBuffers.UnpinAll()
+	tempErr := tx.tempFiles.deleteAll()
+	tx.finished.Store(true)
+	if err != nil {
+		return errors.Join(fmt.Errorf("transaction %d cannot release its locks: %w", tx.txNum, err), tempErr)
+	}
+	return tempErr
+}
+
+// sLock obtains a shared lock on block, see guard.
+func (tx *Transaction) sLock(block *file.BlockId) error {
+	return tx.guard(func() error { return tx.concurrencyManager.SLock(block) })
+}
+
+// xLock obtains an exclusive lock on block, see guard.
+func (tx *Transaction) xLock(block *file.BlockId) error {
+	return tx.guard(func() error { return tx.concurrencyManager.XLock(block) })
+}
+
+// guard runs op, which locks or pins on behalf of the transaction, unless the context of the transaction is done.
+// Once it is, whether before op or while op waits for a lock, the transaction is rolled back, releasing its pins and
+// locks, and guard returns an error wrapping the context's error. A cancelled client request thus stops at the
+// next block the transaction touches instead of running to completion.
+func (tx *Transaction) guard(op func() error) error {
+	if err := tx.cancelled(); err != nil {
+		return err
+	}
+	if err := op(); err != nil {
+		if cancelErr := tx.cancelled(); cancelErr != nil {
+			return cancelErr
+		}
+		return err
+	}
 	return nil
 }
 
+// cancelled rolls the transaction back and returns an error if its context is done; otherwise it returns nil.
+func (tx *Transaction) cancelled() error {
+	ctxErr := tx.ctx.Err()
+	if ctxErr == nil || tx.ending {
+		return nil
+	}
+	err := fmt.Errorf("transaction %d cancelled: %w", tx.txNum, ctxErr)
+	if tx.finished.Load() {
+		return err
+	}
+	return errors.Join(err, tx.Rollback())
+}
+
 // Recover flushes all modified buffers to disk, then goes through the log, rolling back all uncommitted transactions.
 // Finally, writes a quiescent checkpoint record to the log. This method is called during system startup, before any
 // user transactions begin.
 func (tx *Transaction) Recover() error {
-	if err := tx.bufferManager.FlushAll(tx.txNum); err != nil {
-		return err
+	return tx.RecoverWithProgress(nil)
+}
+
+// RecoverWithProgress is like Recover, but reports its progress to progress, if not nil. The final counts are also
+// recorded on the recovery span.
+func (tx *Transaction) RecoverWithProgress(progress func(RecoveryProgress)) (err error) {
+	_, span := tx.startSpan("tx.recover")
+	defer func() { utils.EndSpan(span, err) }()
+
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
 	}
-	if err := tx.recoveryManager.Recover(); err != nil {
+	if err := tx.bufferManager.FlushAll(tx.txNum); err != nil {
 		return err
 	}
-	return nil
+	state, err := tx.recoveryManager.Recover(progress)
+	span.SetAttributes(
+		attribute.Int("mydb.recovery.records_scanned", state.RecordsScanned),
+		attribute.Int("mydb.recovery.records_undone", state.RecordsUndone),
+		attribute.Int("mydb.recovery.transactions_undone", state.TransactionsUndone),
+	)
+	return err
 }
 
 // Pin pins the specified block.
 // The transaction manages the buffer for the client.
 func (tx *Transaction) Pin(block *file.BlockId) error {
-	return tx.myBuffers.Pin(block)
+	_, span := tx.startSpan("buffer.pin", utils.BlockAttributes(block.Filename(), block.Number())...)
+	err := tx.guard(func() error { return tx.myBuffers.Pin(block) })
+	utils.EndSpan(span, err)
+	return err
+}
+
+// PinWithPriority pins the specified block like Pin, tagging the buffer with priority so that the replacement
+// strategy evicts it only after buffers of lower priority. Catalog and index root pages should be pinned this way.
+func (tx *Transaction) PinWithPriority(block *file.BlockId, priority buffer.Priority) error {
+	_, span := tx.startSpan("buffer.pin", utils.BlockAttributes(block.Filename(), block.Number())...)
+	err := tx.guard(func() error { return tx.myBuffers.PinWithPriority(block, priority) })
+	utils.EndSpan(span, err)
+	return err
+}
+
+// PinWithType pins the specified block like Pin. If the block is read into a buffer, its page header must be of
+// type pageType, or the block must have no header yet; see buffer.Manager.PinWithType.
+func (tx *Transaction) PinWithType(block *file.BlockId, pageType file.PageType) error {
+	_, span := tx.startSpan("buffer.pin", utils.BlockAttributes(block.Filename(), block.Number())...)
+	err := tx.guard(func() error { return tx.myBuffers.PinWithType(block, pageType) })
+	utils.EndSpan(span, err)
+	return err
 }
 
 // Unpin unpins the specified block.
@@ -107,7 +305,7 @@ func (tx *Transaction) Unpin(block *file.BlockId) {
 // The method first obtains an SLock on the block,
 // then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetInt(block *file.BlockId, offset int) (int, error) {
-	if err := tx.concurrencyManager.SLock(block); err != nil {
+	if err := tx.sLock(block); err != nil {
 		return math.MinInt, err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -121,7 +319,7 @@ func (tx *Transaction) GetInt(block *file.BlockId, offset int) (int, error) {
 // The method first obtains an SLock on the block,
 // then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetString(block *file.BlockId, offset int) (string, error) {
-	if err := tx.concurrencyManager.SLock(block); err != nil {
+	if err := tx.sLock(block); err != nil {
 		return "", err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -131,6 +329,14 @@ func (tx *Transaction) GetString(block *file.BlockId, offset int) (string, error
 	return buff.Contents().GetString(offset)
 }
 
+// logged returns true if a write to block should be logged. Temporary files are deleted when the database is
+// opened, so recovery never needs their old values, and writes to them are not logged even if logIt is set.
+// As with any unlogged write, rolling back does not restore the old contents of a temporary block. Writes to
+// unlogged files do count as logged here: the recovery manager keeps their undo records in memory instead.
+func logged(block *file.BlockId, logIt bool) bool {
+	return logIt && !file.IsTempFile(block.Filename())
+}
+
 // SetInt stores an integer at the specified offset of the specified block.
 // The method first obtains an XLock on the block.
 // It then reads the current value at that offset,
@@ -138,8 +344,11 @@ func (tx *Transaction) GetString(block *file.BlockId, offset int) (string, error
 // Finally, it calls the buffer to store the value,
 // passing in the LSN of the log record and the transaction's ID.
 func (tx *Transaction) SetInt(block *file.BlockId, offset int, val int, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
 	var err error
-	if err = tx.concurrencyManager.XLock(block); err != nil {
+	if err = tx.xLock(block); err != nil {
 		return err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -148,7 +357,7 @@ func (tx *Transaction) SetInt(block *file.BlockId, offset int, val int, logIt bo
 	}
 
 	lsn := -1
-	if logIt {
+	if logged(block, logIt) {
 		if lsn, err = tx.recoveryManager.SetInt(buff, offset, val); err != nil {
 			return err
 		}
@@ -167,17 +376,23 @@ func (tx *Transaction) SetInt(block *file.BlockId, offset int, val int, logIt bo
 // Finally, it calls the buffer to store the value,
 // passing in the LSN of the log record and the transaction's ID.
 func (tx *Transaction) SetString(block *file.BlockId, offset int, val string, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
 	var err error
-	if err = tx.concurrencyManager.XLock(block); err != nil {
+	if err = tx.xLock(block); err != nil {
 		return err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
 	if buff == nil {
 		return fmt.Errorf("buffer for block %s not found", block)
 	}
+	if offset < 0 || offset+utils.IntSize+len(val) > tx.fileManager.BlockSize() {
+		return fmt.Errorf("string of %d bytes does not fit at offset %d of block %s", len(val), offset, block)
+	}
 
 	lsn := -1
-	if logIt {
+	if logged(block, logIt) {
 		if lsn, err = tx.recoveryManager.SetString(buff, offset, val); err != nil {
 			return err
 		}
@@ -194,7 +409,7 @@ func (tx *Transaction) SetString(block *file.BlockId, offset int, val string, lo
 // GetBool returns the boolean value stored at the specified offset of the specified block.
 // The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetBool(block *file.BlockId, offset int) (bool, error) {
-	if err := tx.concurrencyManager.SLock(block); err != nil {
+	if err := tx.sLock(block); err != nil {
 		return false, err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -207,7 +422,10 @@ func (tx *Transaction) GetBool(block *file.BlockId, offset int) (bool, error) {
 // SetBool stores a boolean value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetBool(block *file.BlockId, offset int, val bool, logIt bool) error {
-	if err := tx.concurrencyManager.XLock(block); err != nil {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
 		return err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -216,7 +434,7 @@ func (tx *Transaction) SetBool(block *file.BlockId, offset int, val bool, logIt
 	}
 
 	lsn := -1
-	if logIt {
+	if logged(block, logIt) {
 		var err error
 		if lsn, err = tx.recoveryManager.SetBool(buff, offset, val); err != nil {
 			return err
@@ -232,7 +450,7 @@ func (tx *Transaction) SetBool(block *file.BlockId, offset int, val bool, logIt
 // GetLong returns the int64 value stored at the specified offset of the specified block.
 // The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetLong(block *file.BlockId, offset int) (int64, error) {
-	if err := tx.concurrencyManager.SLock(block); err != nil {
+	if err := tx.sLock(block); err != nil {
 		return 0, err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -245,7 +463,10 @@ func (tx *Transaction) GetLong(block *file.BlockId, offset int) (int64, error) {
 // SetLong stores an int64 value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetLong(block *file.BlockId, offset int, val int64, logIt bool) error {
-	if err := tx.concurrencyManager.XLock(block); err != nil {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
 		return err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -254,7 +475,7 @@ func (tx *Transaction) SetLong(block *file.BlockId, offset int, val int64, logIt
 	}
 
 	lsn := -1
-	if logIt {
+	if logged(block, logIt) {
 		var err error
 		if lsn, err = tx.recoveryManager.SetLong(buff, offset, val); err != nil {
 			return err
@@ -267,10 +488,54 @@ func (tx *Transaction) SetLong(block *file.BlockId, offset int, val int64, logIt
 	return nil
 }
 
+// GetFloat returns the float64 value stored at the specified offset of the specified block.
+// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+func (tx *Transaction) GetFloat(block *file.BlockId, offset int) (float64, error) {
+	if err := tx.sLock(block); err != nil {
+		return 0, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return 0, fmt.Errorf("buffer for block %s not found", block)
+	}
+	return buff.Contents().GetFloat(offset), nil
+}
+
+// SetFloat stores a float64 value at the specified offset of the specified block.
+// The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
+func (tx *Transaction) SetFloat(block *file.BlockId, offset int, val float64, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := tx.checkRange(block, offset, 8); err != nil { // a float64 takes 8 bytes
+		return err
+	}
+
+	lsn := -1
+	if logged(block, logIt) {
+		var err error
+		if lsn, err = tx.recoveryManager.SetFloat(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetFloat(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
 // GetShort returns the int16 value stored at the specified offset of the specified block.
 // The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetShort(block *file.BlockId, offset int) (int16, error) {
-	if err := tx.concurrencyManager.SLock(block); err != nil {
+	if err := tx.sLock(block); err != nil {
 		return 0, err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -283,7 +548,10 @@ func (tx *Transaction) GetShort(block *file.BlockId, offset int) (int16, error)
 // SetShort stores an int16 value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetShort(block *file.BlockId, offset int, val int16, logIt bool) error {
-	if err := tx.concurrencyManager.XLock(block); err != nil {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
 		return err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -292,7 +560,7 @@ func (tx *Transaction) SetShort(block *file.BlockId, offset int, val int16, logI
 	}
 
 	lsn := -1
-	if logIt {
+	if logged(block, logIt) {
 		var err error
 		if lsn, err = tx.recoveryManager.SetShort(buff, offset, val); err != nil {
 			return err
@@ -305,23 +573,26 @@ func (tx *Transaction) SetShort(block *file.BlockId, offset int, val int16, logI
 	return nil
 }
 
-// GetDate returns the time.Time value stored at the specified offset of the specified block.
-// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+// GetDate returns the time.Time value stored at the specified offset of the specified block, in the location of the
+// transaction. The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
 func (tx *Transaction) GetDate(block *file.BlockId, offset int) (time.Time, error) {
-	if err := tx.concurrencyManager.SLock(block); err != nil {
+	if err := tx.sLock(block); err != nil {
 		return time.Time{}, err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
 	if buff == nil {
 		return time.Time{}, fmt.Errorf("buffer for block %s not found", block)
 	}
-	return buff.Contents().GetDate(offset), nil
+	return buff.Contents().GetDate(offset).In(tx.location), nil
 }
 
 // SetDate stores a time.Time value at the specified offset of the specified block.
 // The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
 func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, logIt bool) error {
-	if err := tx.concurrencyManager.XLock(block); err != nil {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
 		return err
 	}
 	buff := tx.myBuffers.GetBuffer(block)
@@ -330,7 +601,7 @@ func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, l
 	}
 
 	lsn := -1
-	if logIt {
+	if logged(block, logIt) {
 		var err error
 		if lsn, err = tx.recoveryManager.SetDate(buff, offset, val); err != nil {
 			return err
@@ -343,6 +614,272 @@ func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, l
 	return nil
 }
 
+// GetTimestamp returns the timestamp stored at the specified offset of the specified block, in the location of the
+// transaction.
+// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+func (tx *Transaction) GetTimestamp(block *file.BlockId, offset int) (time.Time, error) {
+	if err := tx.sLock(block); err != nil {
+		return time.Time{}, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return time.Time{}, fmt.Errorf("buffer for block %s not found", block)
+	}
+	return buff.Contents().GetTimestamp(offset).In(tx.location), nil
+}
+
+// SetTimestamp stores a timestamp with nanosecond precision at the specified offset of the specified block.
+// The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
+func (tx *Transaction) SetTimestamp(block *file.BlockId, offset int, val time.Time, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := tx.checkRange(block, offset, file.TimestampSize); err != nil {
+		return err
+	}
+
+	lsn := -1
+	if logged(block, logIt) {
+		var err error
+		if lsn, err = tx.recoveryManager.SetTimestamp(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetTimestamp(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
+// GetInterval returns the interval stored at the specified offset of the specified block.
+// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+func (tx *Transaction) GetInterval(block *file.BlockId, offset int) (file.Interval, error) {
+	if err := tx.sLock(block); err != nil {
+		return file.Interval{}, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return file.Interval{}, fmt.Errorf("buffer for block %s not found", block)
+	}
+	return buff.Contents().GetInterval(offset), nil
+}
+
+// SetInterval stores an interval at the specified offset of the specified block.
+// The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
+func (tx *Transaction) SetInterval(block *file.BlockId, offset int, val file.Interval, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := tx.checkRange(block, offset, file.IntervalSize); err != nil {
+		return err
+	}
+
+	lsn := -1
+	if logged(block, logIt) {
+		var err error
+		if lsn, err = tx.recoveryManager.SetInterval(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetInterval(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
+// GetDecimal returns the decimal stored at the specified offset of the specified block.
+// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+func (tx *Transaction) GetDecimal(block *file.BlockId, offset int) (file.Decimal, error) {
+	if err := tx.sLock(block); err != nil {
+		return file.Decimal{}, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return file.Decimal{}, fmt.Errorf("buffer for block %s not found", block)
+	}
+	return buff.Contents().GetDecimal(offset), nil
+}
+
+// SetDecimal stores a decimal at the specified offset of the specified block. A decimal with more digits than its
+// precision is rejected. The method first obtains an XLock on the block, writes an update log record, and then
+// updates the buffer.
+func (tx *Transaction) SetDecimal(block *file.BlockId, offset int, val file.Decimal, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := tx.checkRange(block, offset, file.DecimalSize); err != nil {
+		return err
+	}
+	if err := val.Validate(); err != nil {
+		return err
+	}
+
+	lsn := -1
+	if logged(block, logIt) {
+		var err error
+		if lsn, err = tx.recoveryManager.SetDecimal(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetDecimal(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
+// GetUUID returns the UUID stored at the specified offset of the specified block.
+// The method first obtains an SLock on the block, then it calls the buffer to retrieve the value.
+func (tx *Transaction) GetUUID(block *file.BlockId, offset int) (file.UUID, error) {
+	if err := tx.sLock(block); err != nil {
+		return file.UUID{}, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return file.UUID{}, fmt.Errorf("buffer for block %s not found", block)
+	}
+	return buff.Contents().GetUUID(offset), nil
+}
+
+// SetUUID stores a UUID at the specified offset of the specified block.
+// The method first obtains an XLock on the block, writes an update log record, and then updates the buffer.
+func (tx *Transaction) SetUUID(block *file.BlockId, offset int, val file.UUID, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.xLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err := tx.checkRange(block, offset, file.UUIDSize); err != nil {
+		return err
+	}
+
+	lsn := -1
+	if logged(block, logIt) {
+		var err error
+		if lsn, err = tx.recoveryManager.SetUUID(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetUUID(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
+// LockFile locks the whole file in mode concurrency.Shared or concurrency.Exclusive until the transaction finishes.
+// Bulk loads and maintenance jobs can take one such lock up front instead of locking every block they touch.
+func (tx *Transaction) LockFile(filename string, mode concurrency.LockMode) error {
+	if mode == concurrency.Exclusive && tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	return tx.guard(func() error { return tx.concurrencyManager.LockFile(filename, mode) })
+}
+
+// GetBytes returns a copy of the length bytes at the specified offset of the specified block.
+// The method first obtains an SLock on the block.
+func (tx *Transaction) GetBytes(block *file.BlockId, offset, length int) ([]byte, error) {
+	if err := tx.sLock(block); err != nil {
+		return nil, err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return nil, fmt.Errorf("buffer for block %s not found", block)
+	}
+	if offset < 0 || length < 0 || offset+length > tx.fileManager.BlockSize() {
+		return nil, fmt.Errorf("byte range [%d, %d) is outside block %s", offset, offset+length, block)
+	}
+	return buff.Contents().GetRawBytes(offset, length), nil
+}
+
+// SetBytes overwrites the bytes at the specified offset of the specified block with val.
+// Rather than a typed value, the log record holds the old and new contents of the part of the range that actually
+// changes, which keeps the log small when a wide record is rewritten with few differences.
+func (tx *Transaction) SetBytes(block *file.BlockId, offset int, val []byte, logIt bool) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	var err error
+	if err = tx.xLock(block); err != nil {
+		return err
+	}
+	buff := tx.myBuffers.GetBuffer(block)
+	if buff == nil {
+		return fmt.Errorf("buffer for block %s not found", block)
+	}
+	if err = tx.checkRange(block, offset, len(val)); err != nil {
+		return err
+	}
+
+	lsn := -1
+	if logged(block, logIt) {
+		if lsn, err = tx.recoveryManager.SetBytes(buff, offset, val); err != nil {
+			return err
+		}
+	}
+
+	page := buff.Contents()
+	page.SetRawBytes(offset, val)
+	buff.SetModified(tx.txNum, lsn)
+	return nil
+}
+
+// checkRange returns an error unless the length bytes at offset lie inside block. Setters of fixed-size values call
+// it before logging anything, so that a bad offset fails cleanly instead of panicking in the page.
+func (tx *Transaction) checkRange(block *file.BlockId, offset, length int) error {
+	if offset < 0 || offset+length > tx.fileManager.BlockSize() {
+		return fmt.Errorf("byte range [%d, %d) is outside block %s", offset, offset+length, block)
+	}
+	return nil
+}
+
+// GetPageType returns the type recorded in the page header of the specified block, or file.PageTypeAny if the block
+// has no header. The method first obtains an SLock on the block.
+func (tx *Transaction) GetPageType(block *file.BlockId) (file.PageType, error) {
+	header, err := tx.GetBytes(block, 0, file.PageHeaderSize)
+	if err != nil {
+		return file.PageTypeAny, err
+	}
+	return file.NewPageFromBytes(header).Type()
+}
+
+// SetPageType writes a page header of type pageType at the start of the specified block, see file.Page.SetHeader.
+// The contents of the block then start at offset file.PageHeaderSize.
+func (tx *Transaction) SetPageType(block *file.BlockId, pageType file.PageType, logIt bool) error {
+	if pageType == file.PageTypeAny {
+		return fmt.Errorf("cannot give block %s a header of page type %s", block, pageType)
+	}
+	return tx.SetBytes(block, 0, file.PageHeader(pageType), logIt)
+}
+
 // Size returns the number of blocks in the specified file.
 // This method first obtains an SLock on the "end of file" marker,
 // before asking the file manager to return the file size.
@@ -350,7 +887,7 @@ func (tx *Transaction) SetDate(block *file.BlockId, offset int, val time.Time, l
 // while this transaction is counting the blocks and causing phantom reads.
 func (tx *Transaction) Size(filename string) (int, error) {
 	dummyBlock := file.NewBlockId(filename, EndOfFile)
-	if err := tx.concurrencyManager.SLock(dummyBlock); err != nil {
+	if err := tx.sLock(dummyBlock); err != nil {
 		return -1, err
 	}
 	return tx.fileManager.Length(filename)
@@ -360,14 +897,35 @@ func (tx *Transaction) Size(filename string) (int, error) {
 // This method first obtains an XLock on the "end of file" marker, before performing the append operation.
 // This is necessary to prevent another transaction from reading the size of the file while this append is in progress.
 // This helps prevent phantom reads.
+// If a block of the file was freed with FreeBlock, that block is zeroed and returned instead, and the file does not
+// grow.
 func (tx *Transaction) Append(filename string) (*file.BlockId, error) {
+	if tx.fileManager.ReadOnly() {
+		return nil, file.ErrReadOnly
+	}
 	dummyBlock := file.NewBlockId(filename, EndOfFile)
-	if err := tx.concurrencyManager.XLock(dummyBlock); err != nil {
+	if err := tx.xLock(dummyBlock); err != nil {
 		return nil, err
 	}
+	block, err := tx.reuseFreeBlock(filename)
+	if err != nil || block != nil {
+		return block, err
+	}
 	return tx.fileManager.Append(filename)
 }
 
+// SetLocation sets the time zone in which GetDate and GetTimestamp return their values. It defaults to UTC, so that
+// values read back do not depend on the time zone of the machine. Dates and timestamps are always stored as
+// instants, whatever the location of the value they were set from.
+func (tx *Transaction) SetLocation(loc *time.Location) {
+	tx.location = loc
+}
+
+// Location returns the time zone in which GetDate and GetTimestamp return their values.
+func (tx *Transaction) Location() *time.Location {
+	return tx.location
+}
+
 // BlockSize returns the size of a block in the database.
 func (tx *Transaction) BlockSize() int {
 	return tx.fileManager.BlockSize()
@@ -378,6 +936,22 @@ func (tx *Transaction) AvailableBuffers() int {
 	return tx.bufferManager.Available()
 }
 
+// SetLockWaitPriority declares how long the transaction is prepared to wait for locks, relative to the wait time
+// of the lock table: interactive transactions give up sooner, batch transactions later.
+func (tx *Transaction) SetLockWaitPriority(priority concurrency.WaitPriority) {
+	tx.concurrencyManager.SetWaitPriority(priority)
+}
+
+// SetLockWaitTime overrides how long the transaction waits for a lock before aborting. Zero restores the default.
+func (tx *Transaction) SetLockWaitTime(d time.Duration) {
+	tx.concurrencyManager.SetWaitTime(d)
+}
+
+// Finished returns true once the transaction has been committed or rolled back.
+func (tx *Transaction) Finished() bool {
+	return tx.finished.Load()
+}
+
 // nextTxNumber increments the transaction number and returns the new value.
 func (tx *Transaction) TxNum() int {
 	return tx.txNum
@@ -0,0 +1,114 @@
+package tx
+
+import (
+	"fmt"
+	"mydb/file"
+	"mydb/tx/concurrency"
+	"mydb/utils"
+)
+
+// TruncateRecord records that a transaction truncated a file to its first blocks blocks. Like a deletion, the
+// truncation only happens once the transaction has committed, see DeleteFileRecord.
+type TruncateRecord struct {
+	LogRecord
+	txNum    int
+	fileName string
+	blocks   int
+}
+
+func NewTruncateRecord(page *file.Page) (*TruncateRecord, error) {
+	reader := newRecordReader(page, "Truncate")
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	txNum, err := reader.getInt("txNum", txNumPos)
+	if err != nil {
+		return nil, err
+	}
+
+	fileNamePos := txNumPos + utils.IntSize
+	fileName, err := reader.getString("fileName", fileNamePos)
+	if err != nil {
+		return nil, err
+	}
+
+	blocksPos := fileNamePos + file.MaxLength(len(fileName))
+	blocks, err := reader.getNonNegativeInt("blocks", blocksPos)
+	if err != nil {
+		return nil, err
+	}
+
+	return &TruncateRecord{txNum: txNum, fileName: fileName, blocks: blocks}, nil
+}
+
+func (r *TruncateRecord) Op() LogRecordType {
+	return Truncate
+}
+
+func (r *TruncateRecord) TxNumber() int {
+	return r.txNum
+}
+
+func (r *TruncateRecord) changedFile() string {
+	return r.fileName
+}
+
+func (r *TruncateRecord) String() string {
+	return fmt.Sprintf("<TRUNCATE %d %s %d>", r.txNum, r.fileName, r.blocks)
+}
+
+// Undo does nothing, as the file is only truncated after the transaction commits.
+func (r *TruncateRecord) Undo(tx *Transaction) error {
+	return nil
+}
+
+// redo truncates the file, for a committed transaction that crashed before it could.
+func (r *TruncateRecord) redo(tx *Transaction) error {
+	return tx.shrinkFile(r.fileName, r.blocks)
+}
+
+// WriteTruncateToLog writes a Truncate record for the truncation of fileName to its first blocks blocks.
+func WriteTruncateToLog(logManager LogAppender, txNum int, fileName string, blocks int) (int, error) {
+	operationPos := 0
+	txNumPos := operationPos + utils.IntSize
+	fileNamePos := txNumPos + utils.IntSize
+	blocksPos := fileNamePos + file.MaxLength(len(fileName))
+	recordLen := blocksPos + utils.IntSize
+
+	recordBytes := make([]byte, recordLen)
+	page := file.NewPageFromBytes(recordBytes)
+
+	page.SetInt(operationPos, int(Truncate))
+	page.SetInt(txNumPos, txNum)
+	if err := page.SetString(fileNamePos, fileName); err != nil {
+		return -1, err
+	}
+	page.SetInt(blocksPos, blocks)
+
+	return logManager.Append(recordBytes)
+}
+
+// Truncate shortens filename to its first numBlocks blocks when the transaction commits. It locks the file and logs
+// a Truncate record as DeleteFile does, and like it leaves the file unchanged until the commit. numBlocks cannot
+// exceed the current length of the file.
+func (tx *Transaction) Truncate(filename string, numBlocks int) error {
+	if tx.fileManager.ReadOnly() {
+		return file.ErrReadOnly
+	}
+	if err := tx.LockFile(filename, concurrency.Exclusive); err != nil {
+		return err
+	}
+	length, err := tx.fileManager.Length(filename)
+	if err != nil {
+		return err
+	}
+	if numBlocks < 0 || numBlocks > length {
+		return fmt.Errorf("cannot truncate %s of %d blocks to %d blocks", filename, length, numBlocks)
+	}
+	if !file.IsTempFile(filename) {
+		if err := tx.recoveryManager.Truncate(filename, numBlocks); err != nil {
+			return err
+		}
+	}
+	tx.shrink(filename, numBlocks)
+	return nil
+}
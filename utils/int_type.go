@@ -1,11 +1,6 @@
 package utils
 
-import "runtime"
-
-var IntSize = 8
-
-func init() {
-	if runtime.GOARCH == "386" || runtime.GOARCH == "arm" {
-		IntSize = 4
-	}
-}
+// IntSize is the number of bytes an int occupies on disk. It is fixed by the database format rather than taken
+// from the host architecture, so that a database written on a 64-bit machine can be read on a 32-bit one and vice
+// versa.
+const IntSize = 8
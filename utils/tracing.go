@@ -0,0 +1,29 @@
+package utils
+
+import (
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// TxNumAttribute returns the span attribute identifying a transaction.
+func TxNumAttribute(txNum int) attribute.KeyValue {
+	return attribute.Int("mydb.tx.num", txNum)
+}
+
+// BlockAttributes returns the span attributes identifying block number of filename.
+func BlockAttributes(filename string, number int) []attribute.KeyValue {
+	return []attribute.KeyValue{
+		attribute.String("mydb.block.file", filename),
+		attribute.Int("mydb.block.number", number),
+	}
+}
+
+// EndSpan records err on the span, if any, and ends it.
+func EndSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
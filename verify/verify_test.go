@@ -0,0 +1,122 @@
+package verify
+
+import (
+	"mydb"
+	"mydb/file"
+	"mydb/utils"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func createDatabase(t *testing.T) string {
+	t.Helper()
+	dir := filepath.Join(t.TempDir(), "db")
+
+	db, err := mydb.Open(dir)
+	require.NoError(t, err)
+	defer db.Close()
+
+	block, err := db.FileManager().Append("data.tbl")
+	require.NoError(t, err)
+	for i := 0; i < 20; i++ {
+		transaction, err := db.NewTx()
+		require.NoError(t, err)
+		require.NoError(t, transaction.Pin(block))
+		require.NoError(t, transaction.SetInt(block, 0, i, true))
+		require.NoError(t, transaction.SetString(block, 40, "some value", true))
+		require.NoError(t, transaction.Commit())
+	}
+	return dir
+}
+
+func TestVerify(t *testing.T) {
+	t.Run("healthy database", func(t *testing.T) {
+		dir := createDatabase(t)
+
+		report, err := Run(dir, mydb.DefaultBlockSize, mydb.DefaultLogFile)
+		require.NoError(t, err)
+		assert.True(t, report.OK(), "unexpected problems: %v", report.Problems)
+		assert.Equal(t, 3, report.FilesChecked) // data, log and superblock
+		assert.Equal(t, 80, report.LogRecords)  // a start record, two updates and a commit per transaction
+	})
+
+	t.Run("partial data block", func(t *testing.T) {
+		dir := createDatabase(t)
+		f, err := os.OpenFile(filepath.Join(dir, "data.tbl"), os.O_APPEND|os.O_WRONLY, 0666)
+		require.NoError(t, err)
+		_, err = f.Write([]byte{1, 2, 3})
+		require.NoError(t, err)
+		require.NoError(t, f.Close())
+
+		report, err := Run(dir, mydb.DefaultBlockSize, mydb.DefaultLogFile)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		assert.Equal(t, "data.tbl", report.Problems[0].File)
+		assert.Contains(t, report.Problems[0].Description, "partial block")
+	})
+
+	t.Run("corrupt log record", func(t *testing.T) {
+		dir := createDatabase(t)
+		logPath := filepath.Join(dir, mydb.DefaultLogFile)
+		contents, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+
+		// Give the newest record of the first block an impossible length
+		page := file.NewPageFromBytes(contents[:mydb.DefaultBlockSize])
+		page.SetInt(page.GetInt(0), mydb.DefaultBlockSize)
+		require.NoError(t, os.WriteFile(logPath, contents, 0666))
+
+		report, err := Run(dir, mydb.DefaultBlockSize, mydb.DefaultLogFile)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		assert.Equal(t, mydb.DefaultLogFile, report.Problems[0].File)
+		assert.Equal(t, 0, report.Problems[0].Block)
+		assert.Contains(t, report.Problems[0].Description, "invalid length")
+	})
+
+	t.Run("corrupt superblock", func(t *testing.T) {
+		dir := createDatabase(t)
+		superblockPath := filepath.Join(dir, mydb.SuperblockFile)
+		contents, err := os.ReadFile(superblockPath)
+		require.NoError(t, err)
+
+		contents[mydb.DefaultBlockSize+20]++
+		require.NoError(t, os.WriteFile(superblockPath, contents, 0666))
+
+		report, err := Run(dir, mydb.DefaultBlockSize, mydb.DefaultLogFile)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		assert.Equal(t, mydb.SuperblockFile, report.Problems[0].File)
+		assert.Equal(t, 1, report.Problems[0].Block)
+		assert.Contains(t, report.Problems[0].Description, "checksum mismatch")
+	})
+
+	t.Run("unknown record type", func(t *testing.T) {
+		dir := createDatabase(t)
+		logPath := filepath.Join(dir, mydb.DefaultLogFile)
+		contents, err := os.ReadFile(logPath)
+		require.NoError(t, err)
+
+		page := file.NewPageFromBytes(contents[:mydb.DefaultBlockSize])
+		page.SetInt(page.GetInt(0)+utils.IntSize, 999)
+		require.NoError(t, os.WriteFile(logPath, contents, 0666))
+
+		report, err := Run(dir, mydb.DefaultBlockSize, mydb.DefaultLogFile)
+		require.NoError(t, err)
+		require.Len(t, report.Problems, 1)
+		assert.Contains(t, report.Problems[0].Description, "unknown LogRecordType")
+	})
+
+	t.Run("wrong block size", func(t *testing.T) {
+		dir := createDatabase(t)
+
+		report, err := Run(dir, 2*mydb.DefaultBlockSize, mydb.DefaultLogFile)
+		require.NoError(t, err)
+		assert.Contains(t, report.Problems, Problem{File: mydb.SuperblockFile, Block: 0,
+			Description: "database was created with block size 400, not 800"})
+	})
+}